@@ -7,29 +7,80 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
+const getPage = `-- name: GetPage :one
+SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash FROM data WHERE url = ?
+`
+
+func (q *Queries) GetPage(ctx context.Context, url string) (Datum, error) {
+	row := q.db.QueryRowContext(ctx, getPage, url)
+	var i Datum
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Content,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Normalized,
+		&i.Simhash,
+		&i.DuplicateOf,
+		&i.Language,
+		&i.ContentType,
+		&i.RawHash,
+	)
+	return i, err
+}
+
 const insertData = `-- name: InsertData :one
-INSERT OR REPLACE INTO data (url, content, created_at, updated_at) VALUES (
+INSERT INTO data (url, content, content_type, normalized, language, simhash, duplicate_of, raw_hash, created_at, updated_at) VALUES (
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
 	?,
 	?,
 	?,
 	?
-) RETURNING url
+) ON CONFLICT (url) DO UPDATE SET
+	content = excluded.content,
+	content_type = excluded.content_type,
+	normalized = excluded.normalized,
+	language = excluded.language,
+	simhash = excluded.simhash,
+	duplicate_of = excluded.duplicate_of,
+	raw_hash = excluded.raw_hash,
+	updated_at = excluded.updated_at
+RETURNING url
 `
 
 type InsertDataParams struct {
-	Url       string
-	Content   string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	Url         string
+	Content     string
+	ContentType string
+	Normalized  string
+	Language    string
+	Simhash     int64
+	DuplicateOf sql.NullString
+	RawHash     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 func (q *Queries) InsertData(ctx context.Context, arg InsertDataParams) (string, error) {
 	row := q.db.QueryRowContext(ctx, insertData,
 		arg.Url,
 		arg.Content,
+		arg.ContentType,
+		arg.Normalized,
+		arg.Language,
+		arg.Simhash,
+		arg.DuplicateOf,
+		arg.RawHash,
 		arg.CreatedAt,
 		arg.UpdatedAt,
 	)
@@ -37,3 +88,204 @@ func (q *Queries) InsertData(ctx context.Context, arg InsertDataParams) (string,
 	err := row.Scan(&url)
 	return url, err
 }
+
+const listDuplicateClusters = `-- name: ListDuplicateClusters :many
+SELECT duplicate_of, url FROM data WHERE duplicate_of IS NOT NULL ORDER BY duplicate_of, url
+`
+
+type ListDuplicateClustersRow struct {
+	DuplicateOf sql.NullString
+	Url         string
+}
+
+func (q *Queries) ListDuplicateClusters(ctx context.Context) ([]ListDuplicateClustersRow, error) {
+	rows, err := q.db.QueryContext(ctx, listDuplicateClusters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDuplicateClustersRow
+	for rows.Next() {
+		var i ListDuplicateClustersRow
+		if err := rows.Scan(&i.DuplicateOf, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFingerprints = `-- name: ListFingerprints :many
+SELECT url, simhash FROM data WHERE duplicate_of IS NULL
+`
+
+type ListFingerprintsRow struct {
+	Url     string
+	Simhash int64
+}
+
+func (q *Queries) ListFingerprints(ctx context.Context) ([]ListFingerprintsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFingerprints)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFingerprintsRow
+	for rows.Next() {
+		var i ListFingerprintsRow
+		if err := rows.Scan(&i.Url, &i.Simhash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPagesAfter = `-- name: ListPagesAfter :many
+SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash FROM data WHERE url > ? ORDER BY url LIMIT ?
+`
+
+type ListPagesAfterParams struct {
+	Url   string
+	Limit int64
+}
+
+func (q *Queries) ListPagesAfter(ctx context.Context, arg ListPagesAfterParams) ([]Datum, error) {
+	rows, err := q.db.QueryContext(ctx, listPagesAfter, arg.Url, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Datum
+	for rows.Next() {
+		var i Datum
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Content,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Normalized,
+			&i.Simhash,
+			&i.DuplicateOf,
+			&i.Language,
+			&i.ContentType,
+			&i.RawHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPagesMatching = `-- name: ListPagesMatching :many
+SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash FROM data WHERE url > ?1
+	AND (?2 = '' OR url LIKE 'http://' || ?2 || '/%' OR url LIKE 'https://' || ?2 || '/%' OR url LIKE 'http://' || ?2 || ':%' OR url LIKE 'https://' || ?2 || ':%')
+	AND (?3 = '' OR language = ?3)
+	AND (?4 IS NULL OR julianday(updated_at) >= julianday(?4))
+	AND (?5 IS NULL OR julianday(updated_at) < julianday(?5))
+ORDER BY url LIMIT ?6
+`
+
+type ListPagesMatchingParams struct {
+	Url      string
+	Host     string
+	Language string
+	Since    sql.NullTime
+	Until    sql.NullTime
+	Limit    int64
+}
+
+func (q *Queries) ListPagesMatching(ctx context.Context, arg ListPagesMatchingParams) ([]Datum, error) {
+	rows, err := q.db.QueryContext(ctx, listPagesMatching,
+		arg.Url,
+		arg.Host,
+		arg.Language,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Datum
+	for rows.Next() {
+		var i Datum
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Content,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Normalized,
+			&i.Simhash,
+			&i.DuplicateOf,
+			&i.Language,
+			&i.ContentType,
+			&i.RawHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRawPages = `-- name: ListRawPages :many
+SELECT url, content_type, raw_hash FROM data WHERE raw_hash != '' ORDER BY url
+`
+
+type ListRawPagesRow struct {
+	Url         string
+	ContentType string
+	RawHash     string
+}
+
+func (q *Queries) ListRawPages(ctx context.Context) ([]ListRawPagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listRawPages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRawPagesRow
+	for rows.Next() {
+		var i ListRawPagesRow
+		if err := rows.Scan(&i.Url, &i.ContentType, &i.RawHash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -5,13 +5,67 @@
 package database
 
 import (
+	"database/sql"
 	"time"
 )
 
+type CrawlRun struct {
+	ID         int64
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+	ConfigHash string
+	Status     string
+}
+
 type Datum struct {
-	ID        int64
-	Url       string
-	Content   string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int64
+	Url         string
+	Content     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Normalized  string
+	Simhash     int64
+	DuplicateOf sql.NullString
+	Language    string
+	ContentType string
+	RawHash     string
+}
+
+type FetchLog struct {
+	ID          int64
+	RunID       int64
+	Url         string
+	Host        string
+	Seed        string
+	Status      int64
+	ContentType string
+	Size        int64
+	LatencyMs   int64
+	Outcome     string
+	Error       string
+	CreatedAt   time.Time
+}
+
+type Frontier struct {
+	ID          int64
+	Queue       string
+	Url         string
+	Depth       int64
+	Priority    float64
+	LeaseOwner  string
+	LeasedUntil int64
+}
+
+type SeedStat struct {
+	RunID        int64
+	Seed         string
+	PagesFetched int64
+	PagesStored  int64
+	PagesFailed  int64
+	Bytes        int64
+	DurationMs   int64
+}
+
+type Visited struct {
+	Url string
 }
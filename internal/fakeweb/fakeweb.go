@@ -0,0 +1,251 @@
+// Package fakeweb serves synthetic sites described by a Site so crawls can
+// be tested end to end without touching the network.
+package fakeweb
+
+import (
+	"fmt"
+	"hash/fnv"
+	"html"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWords is how many words of filler a generated page has, comfortably
+// more than the crawler needs to store it.
+const DefaultWords = 200
+
+// Site is a declarative description of a website: its robots.txt and the
+// pages it serves by path. Paths not listed are 404s.
+type Site struct {
+	// Robots is served as /robots.txt with a text/plain content type. The
+	// site has no robots.txt when it's empty.
+	Robots string
+	Pages  map[string]Page
+}
+
+// Page is one response. Unless Body is set, an HTML page is generated with
+// Title as its heading, Words of filler text and a link to each of Links,
+// which may be paths or absolute URLs.
+type Page struct {
+	Title string
+	Words int
+	Links []string
+	Lang  string
+
+	// Body is served as is instead of a generated page.
+	Body        string
+	ContentType string
+	Status      int
+	Header      map[string]string
+
+	// Redirect answers with Status, 302 by default, and a Location of
+	// Redirect instead of a page.
+	Redirect string
+
+	// Delay holds the response back, for slow servers.
+	Delay time.Duration
+}
+
+// Server serves a Site until it's closed, counting the requests for each
+// path.
+type Server struct {
+	*httptest.Server
+	site Site
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// New serves site on 127.0.0.1.
+func New(site Site) *Server {
+	s, err := NewAt("127.0.0.1", site)
+	if err != nil {
+		panic(fmt.Sprintf("fakeweb: %v", err))
+	}
+
+	return s
+}
+
+// NewAt serves site on the loopback address ip, e.g. 127.0.0.2, so several
+// sites can run at once as different hosts. The crawler scopes by hostname,
+// so sites on different ports of one address would count as one host.
+func NewAt(ip string, site Site) (*Server, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	return Serve(listener, site), nil
+}
+
+// Serve serves site on listener, for sites that link to each other and so
+// need their addresses before either is described.
+func Serve(listener net.Listener, site Site) *Server {
+	s := &Server{site: site, hits: map[string]int{}}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	s.Server.Listener.Close()
+	s.Server.Listener = listener
+	s.Start()
+
+	return s
+}
+
+// Seed returns the site's root URL, as a crawl seed.
+func (s *Server) Seed() string {
+	return s.URL + "/"
+}
+
+// Page returns the absolute URL of a path on the site.
+func (s *Server) Page(path string) string {
+	return s.URL + path
+}
+
+// Hits returns how many times path has been requested.
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hits[path]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	s.mu.Unlock()
+
+	if r.URL.Path == "/robots.txt" {
+		if s.site.Robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, s.site.Robots)
+		return
+	}
+
+	page, ok := s.site.Pages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if page.Delay > 0 {
+		select {
+		case <-time.After(page.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	for key, value := range page.Header {
+		w.Header().Set(key, value)
+	}
+
+	if page.Redirect != "" {
+		status := page.Status
+		if status == 0 {
+			status = http.StatusFound
+		}
+		http.Redirect(w, r, page.Redirect, status)
+		return
+	}
+
+	contentType := page.ContentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	status := page.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+
+	if page.Body != "" {
+		fmt.Fprint(w, page.Body)
+		return
+	}
+	fmt.Fprint(w, Render(r.URL.Path, page))
+}
+
+// Render generates the HTML served for a page without a Body. The filler is
+// derived from path, so every page reads differently but the same each run.
+func Render(path string, page Page) string {
+	title := page.Title
+	if title == "" {
+		title = path
+	}
+	lang := page.Lang
+	if lang == "" {
+		lang = "en"
+	}
+	words := page.Words
+	if words == 0 {
+		words = DefaultWords
+	}
+
+	doc := &strings.Builder{}
+	fmt.Fprintf(doc, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", lang, html.EscapeString(title))
+	fmt.Fprintf(doc, "<h1>%s</h1>\n<p>%s</p>\n", html.EscapeString(title), Filler(path, words))
+	if len(page.Links) > 0 {
+		doc.WriteString("<ul>\n")
+		for _, link := range page.Links {
+			fmt.Fprintf(doc, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link), html.EscapeString(link))
+		}
+		doc.WriteString("</ul>\n")
+	}
+	doc.WriteString("</body>\n</html>\n")
+
+	return doc.String()
+}
+
+var vocabulary = strings.Fields(`
+	the crawler reads every page on a site and keeps the text worth storing
+	while following links from one document to the next across the web
+	each request waits politely for the server before asking again because
+	good robots respect rules written by people who run busy websites
+	search engines build large indexes from pages like these so readers can
+	find useful answers about history science music travel food and weather
+`)
+
+// Filler returns n words of English-looking text, the same for the same key.
+func Filler(key string, n int) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	random := rand.New(rand.NewPCG(hash.Sum64(), 0))
+
+	words := make([]string, n)
+	for i := range words {
+		words[i] = vocabulary[random.IntN(len(vocabulary))]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// Tree returns the pages of a site where every page links to fanout children
+// down to depth levels below the root, at paths like /1/0.
+func Tree(depth, fanout int) map[string]Page {
+	pages := map[string]Page{}
+
+	var grow func(path string, level int)
+	grow = func(path string, level int) {
+		page := Page{Title: path}
+		if level < depth {
+			for i := range fanout {
+				child := fmt.Sprintf("%s/%d", strings.TrimSuffix(path, "/"), i)
+				page.Links = append(page.Links, child)
+				grow(child, level+1)
+			}
+		}
+		pages[path] = page
+	}
+	grow("/", 0)
+
+	return pages
+}
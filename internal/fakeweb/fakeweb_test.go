@@ -0,0 +1,142 @@
+package fakeweb
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	site := New(Site{
+		Robots: "User-agent: *\nDisallow: /private\n",
+		Pages: map[string]Page{
+			"/":         {Title: "Home", Links: []string{"/about", "https://example.com/"}},
+			"/notes":    {Body: "plain notes", ContentType: "text/plain; charset=utf-8"},
+			"/old":      {Redirect: "/new", Status: http.StatusMovedPermanently},
+			"/broken":   {Body: "oops", Status: http.StatusInternalServerError},
+			"/slow":     {Delay: 50 * time.Millisecond},
+			"/language": {Lang: "fr", Words: 3},
+		},
+	})
+	defer site.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	testCases := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		location    string
+		contains    []string
+	}{
+		{
+			name:        "F31: test case 1",
+			path:        "/robots.txt",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			contains:    []string{"Disallow: /private"},
+		},
+		{
+			name:        "F31: test case 2",
+			path:        "/",
+			status:      http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{`<html lang="en">`, "<title>Home</title>", `<a href="/about">`, `<a href="https://example.com/">`},
+		},
+		{
+			name:        "F31: test case 3",
+			path:        "/notes",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			contains:    []string{"plain notes"},
+		},
+		{
+			name:     "F31: test case 4",
+			path:     "/old",
+			status:   http.StatusMovedPermanently,
+			location: "/new",
+		},
+		{
+			name:     "F31: test case 5",
+			path:     "/broken",
+			status:   http.StatusInternalServerError,
+			contains: []string{"oops"},
+		},
+		{
+			name:   "F31: test case 6",
+			path:   "/missing",
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "F31: test case 7",
+			path:     "/slow",
+			status:   http.StatusOK,
+			contains: []string{"<title>/slow</title>"},
+		},
+		{
+			name:     "F31: test case 8",
+			path:     "/language",
+			status:   http.StatusOK,
+			contains: []string{`<html lang="fr">`, Filler("/language", 3)},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			res, err := client.Get(site.Page(testCase.path))
+			if err != nil {
+				t.Fatalf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+
+			if res.StatusCode != testCase.status {
+				t.Errorf("%s failed, %v != %v", testCase.name, res.StatusCode, testCase.status)
+			}
+			if testCase.contentType != "" && res.Header.Get("Content-Type") != testCase.contentType {
+				t.Errorf("%s failed, %v != %v", testCase.name, res.Header.Get("Content-Type"), testCase.contentType)
+			}
+			if res.Header.Get("Location") != testCase.location {
+				t.Errorf("%s failed, %v != %v", testCase.name, res.Header.Get("Location"), testCase.location)
+			}
+			for _, want := range testCase.contains {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("%s failed, %q isn't in %q", testCase.name, want, body)
+				}
+			}
+			if hits := site.Hits(testCase.path); hits != 1 {
+				t.Errorf("%s failed, %v != %v", testCase.name, hits, 1)
+			}
+		})
+	}
+
+	empty := New(Site{})
+	defer empty.Close()
+	res, err := http.Get(empty.Page("/robots.txt"))
+	if err != nil {
+		t.Fatalf("F31: test case 9 failed, unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("F31: test case 9 failed, %v != %v", res.StatusCode, http.StatusNotFound)
+	}
+
+	pages := Tree(2, 3)
+	if len(pages) != 13 {
+		t.Errorf("F31: test case 10 failed, %v != %v", len(pages), 13)
+	}
+	if links := pages["/1"].Links; len(links) != 3 || links[2] != "/1/2" {
+		t.Errorf("F31: test case 11 failed, %v != %v", links, []string{"/1/0", "/1/1", "/1/2"})
+	}
+	if Filler("/a", 50) == Filler("/b", 50) || Filler("/a", 50) != Filler("/a", 50) {
+		t.Errorf("F31: test case 12 failed, filler should differ by key and repeat for one")
+	}
+}
@@ -0,0 +1,868 @@
+package store
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+)
+
+const (
+	pagesFile     = "pages.jsonl"
+	visitedFile   = "visited.jsonl"
+	runsFile      = "runs.jsonl"
+	seedStatsFile = "seed_stats.jsonl"
+	fetchLogFile  = "fetch_log.jsonl"
+	frontierFile  = "frontier.jsonl"
+)
+
+type PageRecord struct {
+	URL         string    `json:"url"`
+	Content     string    `json:"content"`
+	ContentType string    `json:"content_type"`
+	Normalized  string    `json:"normalized"`
+	Language    string    `json:"language"`
+	Simhash     int64     `json:"simhash"`
+	DuplicateOf string    `json:"duplicate_of,omitempty"`
+	RawHash     string    `json:"raw_hash,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (p PageRecord) datum() database.Datum {
+	return database.Datum{
+		Url:         p.URL,
+		Content:     p.Content,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Normalized:  p.Normalized,
+		Simhash:     p.Simhash,
+		DuplicateOf: sql.NullString{String: p.DuplicateOf, Valid: p.DuplicateOf != ""},
+		Language:    p.Language,
+		ContentType: p.ContentType,
+		RawHash:     p.RawHash,
+	}
+}
+
+type visitedRecord struct {
+	URL string `json:"url"`
+}
+
+type runRecord struct {
+	ID         int64      `json:"id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ConfigHash string     `json:"config_hash"`
+	Status     string     `json:"status"`
+}
+
+type seedStatsRecord struct {
+	RunID        int64  `json:"run_id"`
+	Seed         string `json:"seed"`
+	PagesFetched int64  `json:"pages_fetched"`
+	PagesStored  int64  `json:"pages_stored"`
+	PagesFailed  int64  `json:"pages_failed"`
+	Bytes        int64  `json:"bytes"`
+	DurationMs   int64  `json:"duration_ms"`
+}
+
+type fetchLogRecord struct {
+	ID          int64     `json:"id"`
+	RunID       int64     `json:"run_id"`
+	URL         string    `json:"url"`
+	Host        string    `json:"host"`
+	Seed        string    `json:"seed"`
+	Status      int64     `json:"status"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	LatencyMs   int64     `json:"latency_ms"`
+	Outcome     string    `json:"outcome"`
+	Error       string    `json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// frontierRecord is one change to a frontier queue: a URL enqueued, leased
+// or acked.
+type frontierRecord struct {
+	Op          string  `json:"op"`
+	ID          int64   `json:"id,omitempty"`
+	Queue       string  `json:"queue"`
+	URL         string  `json:"url"`
+	Depth       int64   `json:"depth,omitempty"`
+	Priority    float64 `json:"priority,omitempty"`
+	LeaseOwner  string  `json:"lease_owner,omitempty"`
+	LeasedUntil int64   `json:"leased_until,omitempty"`
+}
+
+const (
+	frontierEnqueue = "enqueue"
+	frontierLease   = "lease"
+	frontierAck     = "ack"
+)
+
+// frontierQueue keeps a queue's URLs in the order they're leased, by
+// descending priority and then by when they were enqueued, and which of them
+// have been leased so counting doesn't walk the whole queue.
+type frontierQueue struct {
+	rows   []*database.Frontier
+	byURL  map[string]*database.Frontier
+	leased map[string]*database.Frontier
+}
+
+func compareFrontier(a, b *database.Frontier) int {
+	return cmp.Or(cmp.Compare(b.Priority, a.Priority), cmp.Compare(a.ID, b.ID))
+}
+
+// storedPage is what the store remembers about a page without rereading
+// pages.jsonl, plus where its latest line starts so it can be read alone.
+type storedPage struct {
+	simhash     int64
+	duplicateOf string
+	language    string
+	createdAt   time.Time
+	updatedAt   time.Time
+	offset      int64
+}
+
+// FileStore appends every write as a JSON line to one file per table in a
+// directory, so pages.jsonl doubles as a plain export of the crawl. Later
+// lines for the same key win. Pages and the fetch log are read back from
+// disk on demand, pages by the offset of their latest line; everything else
+// is also kept in memory.
+type FileStore struct {
+	mu         sync.Mutex
+	dir        string
+	files      map[string]*os.File
+	sizes      map[string]int64
+	pages      map[string]storedPage
+	urls       []string
+	visited    map[string]struct{}
+	runs       map[int64]runRecord
+	seedStats  map[int64]map[string]seedStatsRecord
+	fetchLogID int64
+	frontier   map[string]*frontierQueue
+	frontierID int64
+}
+
+var _ Store = (*FileStore)(nil)
+
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f := &FileStore{
+		dir:       dir,
+		files:     map[string]*os.File{},
+		sizes:     map[string]int64{},
+		pages:     map[string]storedPage{},
+		visited:   map[string]struct{}{},
+		runs:      map[int64]runRecord{},
+		seedStats: map[int64]map[string]seedStatsRecord{},
+		frontier:  map[string]*frontierQueue{},
+	}
+
+	err := errors.Join(
+		replayAt(dir, pagesFile, func(page PageRecord, offset int64) {
+			f.putPage(page.URL, storedPage{simhash: page.Simhash, duplicateOf: page.DuplicateOf, language: page.Language, createdAt: page.CreatedAt, updatedAt: page.UpdatedAt, offset: offset})
+		}),
+		replay(dir, visitedFile, func(visited visitedRecord) {
+			f.visited[visited.URL] = struct{}{}
+		}),
+		replay(dir, runsFile, func(run runRecord) {
+			f.runs[run.ID] = run
+		}),
+		replay(dir, seedStatsFile, f.putSeedStats),
+		replay(dir, fetchLogFile, func(attempt fetchLogRecord) {
+			f.fetchLogID = max(f.fetchLogID, attempt.ID)
+		}),
+		replay(dir, frontierFile, f.applyFrontier),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func replay[T any](dir, name string, apply func(T)) error {
+	return replayAt(dir, name, func(record T, _ int64) {
+		apply(record)
+	})
+}
+
+// replayAt is replay passing each record's offset in the file too.
+func replayAt[T any](dir, name string, apply func(T, int64)) error {
+	file, err := os.Open(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	offset := int64(0)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		start := offset
+		offset += int64(len(line)) + 1
+		if len(line) == 0 {
+			continue
+		}
+
+		var record T
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		apply(record, start)
+	}
+
+	return scanner.Err()
+}
+
+// write appends a record, returning the offset of its line. It must be
+// called with f.mu held.
+func (f *FileStore) write(name string, record any) (int64, error) {
+	file, ok := f.files[name]
+	if !ok {
+		var err error
+		file, err = os.OpenFile(filepath.Join(f.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return 0, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			return 0, errors.Join(err, file.Close())
+		}
+		f.files[name] = file
+		f.sizes[name] = info.Size()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+
+	offset := f.sizes[name]
+	n, err := file.Write(line)
+	f.sizes[name] += int64(n)
+
+	return offset, err
+}
+
+// putPage must be called with f.mu held, or before the store is shared.
+func (f *FileStore) putPage(url string, page storedPage) {
+	if _, ok := f.pages[url]; !ok {
+		i, _ := slices.BinarySearch(f.urls, url)
+		f.urls = slices.Insert(f.urls, i, url)
+	}
+	f.pages[url] = page
+}
+
+// readPages reads the latest line of each of urls from pages.jsonl. It must
+// be called with f.mu held.
+func (f *FileStore) readPages(urls []string) ([]database.Datum, error) {
+	rows := []database.Datum{}
+	if len(urls) == 0 {
+		return rows, nil
+	}
+
+	file, err := os.Open(filepath.Join(f.dir, pagesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	for _, url := range urls {
+		reader := bufio.NewReader(io.NewSectionReader(file, f.pages[url].offset, math.MaxInt64))
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		page := PageRecord{}
+		if err := json.Unmarshal(line, &page); err != nil {
+			return nil, err
+		}
+		rows = append(rows, page.datum())
+	}
+
+	return rows, nil
+}
+
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	errs := []error{}
+	for name, file := range f.files {
+		errs = append(errs, file.Close())
+		delete(f.files, name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *FileStore) InsertData(ctx context.Context, arg database.InsertDataParams) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// A page stored again keeps when it was first stored, as with the SQL
+	// stores' upserts.
+	if existing, ok := f.pages[arg.Url]; ok {
+		arg.CreatedAt = existing.createdAt
+	}
+
+	offset, err := f.write(pagesFile, PageRecord{
+		URL:         arg.Url,
+		Content:     arg.Content,
+		ContentType: arg.ContentType,
+		Normalized:  arg.Normalized,
+		Language:    arg.Language,
+		Simhash:     arg.Simhash,
+		DuplicateOf: arg.DuplicateOf.String,
+		RawHash:     arg.RawHash,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	f.putPage(arg.Url, storedPage{simhash: arg.Simhash, duplicateOf: arg.DuplicateOf.String, language: arg.Language, createdAt: arg.CreatedAt, updatedAt: arg.UpdatedAt, offset: offset})
+
+	return arg.Url, nil
+}
+
+func (f *FileStore) ListFingerprints(ctx context.Context) ([]database.ListFingerprintsRow, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rows := []database.ListFingerprintsRow{}
+	for url, page := range f.pages {
+		if page.duplicateOf == "" {
+			rows = append(rows, database.ListFingerprintsRow{Url: url, Simhash: page.simhash})
+		}
+	}
+
+	return rows, nil
+}
+
+func (f *FileStore) ListDuplicateClusters(ctx context.Context) ([]database.ListDuplicateClustersRow, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rows := []database.ListDuplicateClustersRow{}
+	for url, page := range f.pages {
+		if page.duplicateOf != "" {
+			rows = append(rows, database.ListDuplicateClustersRow{
+				DuplicateOf: sql.NullString{String: page.duplicateOf, Valid: true},
+				Url:         url,
+			})
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListDuplicateClustersRow) int {
+		return cmp.Or(cmp.Compare(a.DuplicateOf.String, b.DuplicateOf.String), cmp.Compare(a.Url, b.Url))
+	})
+
+	return rows, nil
+}
+
+// ListRawPages rereads pages.jsonl since content types and raw hashes aren't
+// kept in memory.
+func (f *FileStore) ListRawPages(ctx context.Context) ([]database.ListRawPagesRow, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	latest := map[string]database.ListRawPagesRow{}
+	err := replay(f.dir, pagesFile, func(page PageRecord) {
+		latest[page.URL] = database.ListRawPagesRow{Url: page.URL, ContentType: page.ContentType, RawHash: page.RawHash}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []database.ListRawPagesRow{}
+	for _, row := range latest {
+		if row.RawHash != "" {
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b database.ListRawPagesRow) int {
+		return cmp.Compare(a.Url, b.Url)
+	})
+
+	return rows, nil
+}
+
+// ListPagesAfter reads just the pages in the batch, each from the offset of
+// its latest line, so exporting from a file store doesn't hold every page or
+// reread the whole file per batch.
+func (f *FileStore) ListPagesAfter(ctx context.Context, arg database.ListPagesAfterParams) ([]database.Datum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, found := slices.BinarySearch(f.urls, arg.Url)
+	if found {
+		i++
+	}
+	end := i + int(min(max(arg.Limit, 0), int64(len(f.urls)-i)))
+
+	return f.readPages(f.urls[i:end])
+}
+
+// ListPagesMatching filters on what's kept in memory, so only the pages
+// returned are read.
+func (f *FileStore) ListPagesMatching(ctx context.Context, arg database.ListPagesMatchingParams) ([]database.Datum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, found := slices.BinarySearch(f.urls, arg.Url)
+	if found {
+		i++
+	}
+
+	urls := []string{}
+	for _, rawURL := range f.urls[i:] {
+		if int64(len(urls)) >= arg.Limit {
+			break
+		}
+		page := f.pages[rawURL]
+		if arg.Language != "" && page.language != arg.Language {
+			continue
+		}
+		if (arg.Since.Valid && page.updatedAt.Before(arg.Since.Time)) || (arg.Until.Valid && !page.updatedAt.Before(arg.Until.Time)) {
+			continue
+		}
+		if arg.Host != "" {
+			if parsed, err := url.Parse(rawURL); err != nil || parsed.Hostname() != arg.Host {
+				continue
+			}
+		}
+		urls = append(urls, rawURL)
+	}
+
+	return f.readPages(urls)
+}
+
+func (f *FileStore) GetPage(ctx context.Context, url string) (database.Datum, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.pages[url]; !ok {
+		return database.Datum{}, sql.ErrNoRows
+	}
+
+	rows, err := f.readPages([]string{url})
+	if err != nil {
+		return database.Datum{}, err
+	}
+
+	return rows[0], nil
+}
+
+// applyFrontier must be called with f.mu held, or before the store is shared.
+func (f *FileStore) applyFrontier(record frontierRecord) {
+	queue, ok := f.frontier[record.Queue]
+	if !ok {
+		queue = &frontierQueue{byURL: map[string]*database.Frontier{}, leased: map[string]*database.Frontier{}}
+		f.frontier[record.Queue] = queue
+	}
+
+	switch record.Op {
+	case frontierEnqueue:
+		row := &database.Frontier{ID: record.ID, Queue: record.Queue, Url: record.URL, Depth: record.Depth, Priority: record.Priority}
+		i, _ := slices.BinarySearchFunc(queue.rows, row, compareFrontier)
+		queue.rows = slices.Insert(queue.rows, i, row)
+		queue.byURL[row.Url] = row
+		f.frontierID = max(f.frontierID, record.ID)
+	case frontierLease:
+		if row, ok := queue.byURL[record.URL]; ok {
+			row.LeaseOwner = record.LeaseOwner
+			row.LeasedUntil = record.LeasedUntil
+			queue.leased[row.Url] = row
+		}
+	case frontierAck:
+		if row, ok := queue.byURL[record.URL]; ok {
+			i, _ := slices.BinarySearchFunc(queue.rows, row, compareFrontier)
+			queue.rows = slices.Delete(queue.rows, i, i+1)
+			delete(queue.byURL, row.Url)
+			delete(queue.leased, row.Url)
+		}
+	}
+}
+
+func (f *FileStore) EnqueueFrontier(ctx context.Context, arg database.EnqueueFrontierParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if queue, ok := f.frontier[arg.Queue]; ok && queue.byURL[arg.Url] != nil {
+		return nil
+	}
+
+	record := frontierRecord{Op: frontierEnqueue, ID: f.frontierID + 1, Queue: arg.Queue, URL: arg.Url, Depth: arg.Depth, Priority: arg.Priority}
+	if _, err := f.write(frontierFile, record); err != nil {
+		return err
+	}
+	f.applyFrontier(record)
+
+	return nil
+}
+
+// available returns up to limit rows of a queue that aren't leased at now.
+// The caller holds f.mu.
+func (f *FileStore) available(name string, now, limit int64) []*database.Frontier {
+	rows := []*database.Frontier{}
+	queue, ok := f.frontier[name]
+	if !ok {
+		return rows
+	}
+
+	for _, row := range queue.rows {
+		if int64(len(rows)) >= limit {
+			break
+		}
+		if row.LeasedUntil <= now {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+func (f *FileStore) LeaseFrontier(ctx context.Context, arg database.LeaseFrontierParams) ([]database.Frontier, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	leased := []database.Frontier{}
+	for _, row := range f.available(arg.Queue, arg.Now, arg.Limit) {
+		record := frontierRecord{Op: frontierLease, Queue: row.Queue, URL: row.Url, LeaseOwner: arg.LeaseOwner, LeasedUntil: arg.LeasedUntil}
+		if _, err := f.write(frontierFile, record); err != nil {
+			return nil, err
+		}
+		f.applyFrontier(record)
+		leased = append(leased, *row)
+	}
+
+	return leased, nil
+}
+
+func (f *FileStore) AckFrontier(ctx context.Context, arg database.AckFrontierParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	queue, ok := f.frontier[arg.Queue]
+	if !ok {
+		return nil
+	}
+	if row, ok := queue.byURL[arg.Url]; !ok || row.LeaseOwner != arg.LeaseOwner {
+		return nil
+	}
+
+	record := frontierRecord{Op: frontierAck, Queue: arg.Queue, URL: arg.Url}
+	if _, err := f.write(frontierFile, record); err != nil {
+		return err
+	}
+	f.applyFrontier(record)
+
+	return nil
+}
+
+func (f *FileStore) ListFrontier(ctx context.Context, arg database.ListFrontierParams) ([]database.Frontier, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rows := []database.Frontier{}
+	for _, row := range f.available(arg.Queue, arg.Now, arg.Limit) {
+		rows = append(rows, *row)
+	}
+
+	return rows, nil
+}
+
+func (f *FileStore) CountFrontier(ctx context.Context, arg database.CountFrontierParams) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	queue, ok := f.frontier[arg.Queue]
+	if !ok {
+		return 0, nil
+	}
+
+	count := int64(len(queue.rows))
+	for _, row := range queue.leased {
+		if row.LeasedUntil > arg.Now {
+			count--
+		}
+	}
+
+	return count, nil
+}
+
+func (f *FileStore) HasVisited(ctx context.Context, url string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.visited[url]; ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (f *FileStore) InsertVisited(ctx context.Context, url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.visited[url]; ok {
+		return nil
+	}
+	if _, err := f.write(visitedFile, visitedRecord{URL: url}); err != nil {
+		return err
+	}
+	f.visited[url] = struct{}{}
+
+	return nil
+}
+
+func (f *FileStore) StartRun(ctx context.Context, arg database.StartRunParams) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	run := runRecord{
+		ID:         int64(len(f.runs) + 1),
+		StartedAt:  arg.StartedAt,
+		ConfigHash: arg.ConfigHash,
+		Status:     "running",
+	}
+	if _, err := f.write(runsFile, run); err != nil {
+		return 0, err
+	}
+	f.runs[run.ID] = run
+
+	return run.ID, nil
+}
+
+func (f *FileStore) FinishRun(ctx context.Context, arg database.FinishRunParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	run, ok := f.runs[arg.ID]
+	if !ok {
+		return nil
+	}
+
+	run.Status = arg.Status
+	run.FinishedAt = nil
+	if arg.FinishedAt.Valid {
+		run.FinishedAt = &arg.FinishedAt.Time
+	}
+	if _, err := f.write(runsFile, run); err != nil {
+		return err
+	}
+	f.runs[run.ID] = run
+
+	return nil
+}
+
+// putSeedStats adds stats to what's recorded for the seed in the run, which
+// can crawl a seed more than once.
+func (f *FileStore) putSeedStats(stats seedStatsRecord) {
+	if _, ok := f.seedStats[stats.RunID]; !ok {
+		f.seedStats[stats.RunID] = map[string]seedStatsRecord{}
+	}
+	if recorded, ok := f.seedStats[stats.RunID][stats.Seed]; ok {
+		stats.PagesFetched += recorded.PagesFetched
+		stats.PagesStored += recorded.PagesStored
+		stats.PagesFailed += recorded.PagesFailed
+		stats.Bytes += recorded.Bytes
+		stats.DurationMs += recorded.DurationMs
+	}
+	f.seedStats[stats.RunID][stats.Seed] = stats
+}
+
+func (f *FileStore) InsertSeedStats(ctx context.Context, arg database.InsertSeedStatsParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := seedStatsRecord(arg)
+	if _, err := f.write(seedStatsFile, stats); err != nil {
+		return err
+	}
+	f.putSeedStats(stats)
+
+	return nil
+}
+
+func (f *FileStore) ListLatestRuns(ctx context.Context, limit int64) ([]database.CrawlRun, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	runs := []database.CrawlRun{}
+	for id := int64(len(f.runs)); id > 0 && int64(len(runs)) < limit; id-- {
+		run := f.runs[id]
+		row := database.CrawlRun{
+			ID:         run.ID,
+			StartedAt:  run.StartedAt,
+			ConfigHash: run.ConfigHash,
+			Status:     run.Status,
+		}
+		if run.FinishedAt != nil {
+			row.FinishedAt = sql.NullTime{Time: *run.FinishedAt, Valid: true}
+		}
+		runs = append(runs, row)
+	}
+
+	return runs, nil
+}
+
+func (f *FileStore) ListSeedStats(ctx context.Context, runID int64) ([]database.SeedStat, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	rows := []database.SeedStat{}
+	for _, stats := range f.seedStats[runID] {
+		rows = append(rows, database.SeedStat(stats))
+	}
+	slices.SortFunc(rows, func(a, b database.SeedStat) int {
+		return cmp.Compare(a.Seed, b.Seed)
+	})
+
+	return rows, nil
+}
+
+func (f *FileStore) InsertFetchLog(ctx context.Context, arg database.InsertFetchLogParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	attempt := fetchLogRecord{
+		ID:          f.fetchLogID + 1,
+		RunID:       arg.RunID,
+		URL:         arg.Url,
+		Host:        arg.Host,
+		Seed:        arg.Seed,
+		Status:      arg.Status,
+		ContentType: arg.ContentType,
+		Size:        arg.Size,
+		LatencyMs:   arg.LatencyMs,
+		Outcome:     arg.Outcome,
+		Error:       arg.Error,
+		CreatedAt:   arg.CreatedAt,
+	}
+	if _, err := f.write(fetchLogFile, attempt); err != nil {
+		return err
+	}
+	f.fetchLogID = attempt.ID
+
+	return nil
+}
+
+// fetchLog scans the fetch log file, which isn't kept in memory because it
+// grows with every dequeued URL.
+func (f *FileStore) fetchLog(apply func(fetchLogRecord)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return replay(f.dir, fetchLogFile, apply)
+}
+
+func (f *FileStore) ListFetchAttempts(ctx context.Context, url string) ([]database.FetchLog, error) {
+	rows := []database.FetchLog{}
+	err := f.fetchLog(func(attempt fetchLogRecord) {
+		if attempt.URL == url {
+			rows = append(rows, database.FetchLog{
+				ID:          attempt.ID,
+				RunID:       attempt.RunID,
+				Url:         attempt.URL,
+				Host:        attempt.Host,
+				Seed:        attempt.Seed,
+				Status:      attempt.Status,
+				ContentType: attempt.ContentType,
+				Size:        attempt.Size,
+				LatencyMs:   attempt.LatencyMs,
+				Outcome:     attempt.Outcome,
+				Error:       attempt.Error,
+				CreatedAt:   attempt.CreatedAt,
+			})
+		}
+	})
+
+	return rows, err
+}
+
+type hostKey[T comparable] struct {
+	host  string
+	value T
+}
+
+// countByHost tallies attempts per host and value, ordered by host and then
+// by descending count to match the SQL backends.
+func countByHost[T cmp.Ordered](f *FileStore, value func(fetchLogRecord) (T, bool)) ([]hostKey[T], map[hostKey[T]]int64, error) {
+	counts := map[hostKey[T]]int64{}
+	err := f.fetchLog(func(attempt fetchLogRecord) {
+		if v, ok := value(attempt); ok {
+			counts[hostKey[T]{attempt.Host, v}]++
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []hostKey[T]{}
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b hostKey[T]) int {
+		return cmp.Or(cmp.Compare(a.host, b.host), cmp.Compare(counts[b], counts[a]), cmp.Compare(a.value, b.value))
+	})
+
+	return keys, counts, nil
+}
+
+func (f *FileStore) ListHostFailures(ctx context.Context) ([]database.ListHostFailuresRow, error) {
+	keys, counts, err := countByHost(f, func(attempt fetchLogRecord) (string, bool) {
+		return attempt.Outcome, attempt.Outcome != "stored"
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []database.ListHostFailuresRow{}
+	for _, key := range keys {
+		rows = append(rows, database.ListHostFailuresRow{Host: key.host, Outcome: key.value, Attempts: counts[key]})
+	}
+
+	return rows, nil
+}
+
+func (f *FileStore) ListHostErrorStatuses(ctx context.Context) ([]database.ListHostErrorStatusesRow, error) {
+	keys, counts, err := countByHost(f, func(attempt fetchLogRecord) (int64, bool) {
+		return attempt.Status, attempt.Status >= 400
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []database.ListHostErrorStatusesRow{}
+	for _, key := range keys {
+		rows = append(rows, database.ListHostErrorStatusesRow{Host: key.host, Status: key.value, Attempts: counts[key]})
+	}
+
+	return rows, nil
+}
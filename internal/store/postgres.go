@@ -0,0 +1,259 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/junwei890/crawler/internal/database"
+)
+
+// Postgres runs the same queries as sql/queries, rewritten for PostgreSQL's
+// placeholders and upsert syntax.
+type Postgres struct {
+	db   database.DBTX
+	conn *sql.DB
+}
+
+var _ Store = (*Postgres)(nil)
+
+func (p *Postgres) Close() error {
+	return p.conn.Close()
+}
+
+func (p *Postgres) withTx(ctx context.Context, fn func(PageWriter) error) error {
+	return inTx(ctx, p.conn, func(tx *sql.Tx) error {
+		return fn(&Postgres{db: tx, conn: p.conn})
+	})
+}
+
+const pgInsertData = `INSERT INTO data (url, content, content_type, normalized, language, simhash, duplicate_of, raw_hash, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (url) DO UPDATE SET
+	content = EXCLUDED.content,
+	content_type = EXCLUDED.content_type,
+	normalized = EXCLUDED.normalized,
+	language = EXCLUDED.language,
+	simhash = EXCLUDED.simhash,
+	duplicate_of = EXCLUDED.duplicate_of,
+	raw_hash = EXCLUDED.raw_hash,
+	updated_at = EXCLUDED.updated_at
+RETURNING url`
+
+func (p *Postgres) InsertData(ctx context.Context, arg database.InsertDataParams) (string, error) {
+	url := ""
+	err := p.db.QueryRowContext(ctx, pgInsertData,
+		arg.Url,
+		arg.Content,
+		arg.ContentType,
+		arg.Normalized,
+		arg.Language,
+		arg.Simhash,
+		arg.DuplicateOf,
+		arg.RawHash,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+	).Scan(&url)
+	return url, err
+}
+
+func (p *Postgres) ListFingerprints(ctx context.Context) ([]database.ListFingerprintsRow, error) {
+	return query(ctx, p.db, `SELECT url, simhash FROM data WHERE duplicate_of IS NULL`, nil, func(rows *sql.Rows, i *database.ListFingerprintsRow) error {
+		return rows.Scan(&i.Url, &i.Simhash)
+	})
+}
+
+func (p *Postgres) ListDuplicateClusters(ctx context.Context) ([]database.ListDuplicateClustersRow, error) {
+	return query(ctx, p.db, `SELECT duplicate_of, url FROM data WHERE duplicate_of IS NOT NULL ORDER BY duplicate_of, url`, nil, func(rows *sql.Rows, i *database.ListDuplicateClustersRow) error {
+		return rows.Scan(&i.DuplicateOf, &i.Url)
+	})
+}
+
+func (p *Postgres) ListRawPages(ctx context.Context) ([]database.ListRawPagesRow, error) {
+	return query(ctx, p.db, `SELECT url, content_type, raw_hash FROM data WHERE raw_hash != '' ORDER BY url`, nil, func(rows *sql.Rows, i *database.ListRawPagesRow) error {
+		return rows.Scan(&i.Url, &i.ContentType, &i.RawHash)
+	})
+}
+
+const pgListPagesAfter = `SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash
+FROM data WHERE url > $1 ORDER BY url LIMIT $2`
+
+func (p *Postgres) ListPagesAfter(ctx context.Context, arg database.ListPagesAfterParams) ([]database.Datum, error) {
+	return query(ctx, p.db, pgListPagesAfter, []any{arg.Url, arg.Limit}, func(rows *sql.Rows, i *database.Datum) error {
+		return rows.Scan(&i.ID, &i.Url, &i.Content, &i.CreatedAt, &i.UpdatedAt, &i.Normalized, &i.Simhash, &i.DuplicateOf, &i.Language, &i.ContentType, &i.RawHash)
+	})
+}
+
+const pgListPagesMatching = `SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash
+FROM data WHERE url > $1
+	AND ($2 = '' OR url LIKE 'http://' || $2 || '/%' OR url LIKE 'https://' || $2 || '/%' OR url LIKE 'http://' || $2 || ':%' OR url LIKE 'https://' || $2 || ':%')
+	AND ($3 = '' OR language = $3)
+	AND ($5::timestamptz IS NULL OR updated_at >= $5)
+	AND ($6::timestamptz IS NULL OR updated_at < $6)
+ORDER BY url LIMIT $4`
+
+func (p *Postgres) ListPagesMatching(ctx context.Context, arg database.ListPagesMatchingParams) ([]database.Datum, error) {
+	return query(ctx, p.db, pgListPagesMatching, []any{arg.Url, arg.Host, arg.Language, arg.Limit, arg.Since, arg.Until}, func(rows *sql.Rows, i *database.Datum) error {
+		return rows.Scan(&i.ID, &i.Url, &i.Content, &i.CreatedAt, &i.UpdatedAt, &i.Normalized, &i.Simhash, &i.DuplicateOf, &i.Language, &i.ContentType, &i.RawHash)
+	})
+}
+
+func (p *Postgres) GetPage(ctx context.Context, url string) (database.Datum, error) {
+	i := database.Datum{}
+	err := p.db.QueryRowContext(ctx, `SELECT id, url, content, created_at, updated_at, normalized, simhash, duplicate_of, language, content_type, raw_hash FROM data WHERE url = $1`, url).Scan(
+		&i.ID, &i.Url, &i.Content, &i.CreatedAt, &i.UpdatedAt, &i.Normalized, &i.Simhash, &i.DuplicateOf, &i.Language, &i.ContentType, &i.RawHash,
+	)
+	return i, err
+}
+
+func (p *Postgres) EnqueueFrontier(ctx context.Context, arg database.EnqueueFrontierParams) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO frontier (queue, url, depth, priority) VALUES ($1, $2, $3, $4) ON CONFLICT (queue, url) DO NOTHING`, arg.Queue, arg.Url, arg.Depth, arg.Priority)
+	return err
+}
+
+// SKIP LOCKED lets concurrent leases of one queue take different rows rather
+// than wait on each other.
+const pgLeaseFrontier = `UPDATE frontier SET lease_owner = $1, leased_until = $2 WHERE id IN (
+	SELECT id FROM frontier WHERE queue = $3 AND leased_until <= $4 ORDER BY priority DESC, id LIMIT $5 FOR UPDATE SKIP LOCKED
+) RETURNING id, queue, url, depth, priority, lease_owner, leased_until`
+
+func (p *Postgres) LeaseFrontier(ctx context.Context, arg database.LeaseFrontierParams) ([]database.Frontier, error) {
+	return query(ctx, p.db, pgLeaseFrontier, []any{arg.LeaseOwner, arg.LeasedUntil, arg.Queue, arg.Now, arg.Limit}, scanFrontier)
+}
+
+func (p *Postgres) AckFrontier(ctx context.Context, arg database.AckFrontierParams) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM frontier WHERE queue = $1 AND url = $2 AND lease_owner = $3`, arg.Queue, arg.Url, arg.LeaseOwner)
+	return err
+}
+
+const pgListFrontier = `SELECT id, queue, url, depth, priority, lease_owner, leased_until
+FROM frontier WHERE queue = $1 AND leased_until <= $2 ORDER BY priority DESC, id LIMIT $3`
+
+func (p *Postgres) ListFrontier(ctx context.Context, arg database.ListFrontierParams) ([]database.Frontier, error) {
+	return query(ctx, p.db, pgListFrontier, []any{arg.Queue, arg.Now, arg.Limit}, scanFrontier)
+}
+
+func (p *Postgres) CountFrontier(ctx context.Context, arg database.CountFrontierParams) (int64, error) {
+	count := int64(0)
+	err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM frontier WHERE queue = $1 AND leased_until <= $2`, arg.Queue, arg.Now).Scan(&count)
+	return count, err
+}
+
+func scanFrontier(rows *sql.Rows, i *database.Frontier) error {
+	return rows.Scan(&i.ID, &i.Queue, &i.Url, &i.Depth, &i.Priority, &i.LeaseOwner, &i.LeasedUntil)
+}
+
+func (p *Postgres) HasVisited(ctx context.Context, url string) (int64, error) {
+	exists := false
+	err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM visited WHERE url = $1)`, url).Scan(&exists)
+	if exists {
+		return 1, err
+	}
+	return 0, err
+}
+
+func (p *Postgres) InsertVisited(ctx context.Context, url string) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO visited (url) VALUES ($1) ON CONFLICT DO NOTHING`, url)
+	return err
+}
+
+func (p *Postgres) StartRun(ctx context.Context, arg database.StartRunParams) (int64, error) {
+	id := int64(0)
+	err := p.db.QueryRowContext(ctx, `INSERT INTO crawl_runs (started_at, config_hash, status) VALUES ($1, $2, 'running') RETURNING id`, arg.StartedAt, arg.ConfigHash).Scan(&id)
+	return id, err
+}
+
+func (p *Postgres) FinishRun(ctx context.Context, arg database.FinishRunParams) error {
+	_, err := p.db.ExecContext(ctx, `UPDATE crawl_runs SET finished_at = $1, status = $2 WHERE id = $3`, arg.FinishedAt, arg.Status, arg.ID)
+	return err
+}
+
+const pgInsertSeedStats = `INSERT INTO seed_stats (run_id, seed, pages_fetched, pages_stored, pages_failed, bytes, duration_ms)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (run_id, seed) DO UPDATE SET
+	pages_fetched = seed_stats.pages_fetched + EXCLUDED.pages_fetched,
+	pages_stored = seed_stats.pages_stored + EXCLUDED.pages_stored,
+	pages_failed = seed_stats.pages_failed + EXCLUDED.pages_failed,
+	bytes = seed_stats.bytes + EXCLUDED.bytes,
+	duration_ms = seed_stats.duration_ms + EXCLUDED.duration_ms`
+
+func (p *Postgres) InsertSeedStats(ctx context.Context, arg database.InsertSeedStatsParams) error {
+	_, err := p.db.ExecContext(ctx, pgInsertSeedStats,
+		arg.RunID,
+		arg.Seed,
+		arg.PagesFetched,
+		arg.PagesStored,
+		arg.PagesFailed,
+		arg.Bytes,
+		arg.DurationMs,
+	)
+	return err
+}
+
+func (p *Postgres) ListLatestRuns(ctx context.Context, limit int64) ([]database.CrawlRun, error) {
+	return query(ctx, p.db, `SELECT id, started_at, finished_at, config_hash, status FROM crawl_runs ORDER BY id DESC LIMIT $1`, []any{limit}, func(rows *sql.Rows, i *database.CrawlRun) error {
+		return rows.Scan(&i.ID, &i.StartedAt, &i.FinishedAt, &i.ConfigHash, &i.Status)
+	})
+}
+
+func (p *Postgres) ListSeedStats(ctx context.Context, runID int64) ([]database.SeedStat, error) {
+	return query(ctx, p.db, `SELECT run_id, seed, pages_fetched, pages_stored, pages_failed, bytes, duration_ms FROM seed_stats WHERE run_id = $1 ORDER BY seed`, []any{runID}, func(rows *sql.Rows, i *database.SeedStat) error {
+		return rows.Scan(&i.RunID, &i.Seed, &i.PagesFetched, &i.PagesStored, &i.PagesFailed, &i.Bytes, &i.DurationMs)
+	})
+}
+
+const pgInsertFetchLog = `INSERT INTO fetch_log (run_id, url, host, seed, status, content_type, size, latency_ms, outcome, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+func (p *Postgres) InsertFetchLog(ctx context.Context, arg database.InsertFetchLogParams) error {
+	_, err := p.db.ExecContext(ctx, pgInsertFetchLog,
+		arg.RunID,
+		arg.Url,
+		arg.Host,
+		arg.Seed,
+		arg.Status,
+		arg.ContentType,
+		arg.Size,
+		arg.LatencyMs,
+		arg.Outcome,
+		arg.Error,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+func (p *Postgres) ListFetchAttempts(ctx context.Context, url string) ([]database.FetchLog, error) {
+	return query(ctx, p.db, `SELECT id, run_id, url, host, seed, status, content_type, size, latency_ms, outcome, error, created_at FROM fetch_log WHERE url = $1 ORDER BY id`, []any{url}, func(rows *sql.Rows, i *database.FetchLog) error {
+		return rows.Scan(&i.ID, &i.RunID, &i.Url, &i.Host, &i.Seed, &i.Status, &i.ContentType, &i.Size, &i.LatencyMs, &i.Outcome, &i.Error, &i.CreatedAt)
+	})
+}
+
+func (p *Postgres) ListHostFailures(ctx context.Context) ([]database.ListHostFailuresRow, error) {
+	return query(ctx, p.db, `SELECT host, outcome, COUNT(*) AS attempts FROM fetch_log WHERE outcome != 'stored' GROUP BY host, outcome ORDER BY host, attempts DESC`, nil, func(rows *sql.Rows, i *database.ListHostFailuresRow) error {
+		return rows.Scan(&i.Host, &i.Outcome, &i.Attempts)
+	})
+}
+
+func (p *Postgres) ListHostErrorStatuses(ctx context.Context) ([]database.ListHostErrorStatusesRow, error) {
+	return query(ctx, p.db, `SELECT host, status, COUNT(*) AS attempts FROM fetch_log WHERE status >= 400 GROUP BY host, status ORDER BY host, attempts DESC`, nil, func(rows *sql.Rows, i *database.ListHostErrorStatusesRow) error {
+		return rows.Scan(&i.Host, &i.Status, &i.Attempts)
+	})
+}
+
+func query[T any](ctx context.Context, db database.DBTX, statement string, args []any, scan func(*sql.Rows, *T) error) ([]T, error) {
+	rows, err := db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		var i T
+		if err := scan(rows, &i); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+
+	return items, rows.Err()
+}
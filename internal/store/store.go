@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/junwei890/crawler/internal/database"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+	_ "github.com/tursodatabase/libsql-client-go/libsql"
+	_ "modernc.org/sqlite"
+)
+
+// Store is everything the crawler persists: pages, the frontier and the
+// visited set behind it, crawl runs and the fetch log. Its methods match the sqlc
+// generated queries so *database.Queries satisfies it for libsql and SQLite.
+type Store interface {
+	InsertData(ctx context.Context, arg database.InsertDataParams) (string, error)
+	ListFingerprints(ctx context.Context) ([]database.ListFingerprintsRow, error)
+	ListDuplicateClusters(ctx context.Context) ([]database.ListDuplicateClustersRow, error)
+	ListRawPages(ctx context.Context) ([]database.ListRawPagesRow, error)
+	ListPagesAfter(ctx context.Context, arg database.ListPagesAfterParams) ([]database.Datum, error)
+	// ListPagesMatching is ListPagesAfter narrowed to pages on Host, in
+	// Language and last stored from Since until before Until, each of which
+	// is ignored when empty or null.
+	ListPagesMatching(ctx context.Context, arg database.ListPagesMatchingParams) ([]database.Datum, error)
+	GetPage(ctx context.Context, url string) (database.Datum, error)
+
+	// Frontier queues are named by their callers. A leased URL stays in its
+	// queue, hidden until its lease runs out, so work lost with its holder is
+	// leased again; acking it removes it. Lease times are unix milliseconds.
+	EnqueueFrontier(ctx context.Context, arg database.EnqueueFrontierParams) error
+	LeaseFrontier(ctx context.Context, arg database.LeaseFrontierParams) ([]database.Frontier, error)
+	AckFrontier(ctx context.Context, arg database.AckFrontierParams) error
+	ListFrontier(ctx context.Context, arg database.ListFrontierParams) ([]database.Frontier, error)
+	CountFrontier(ctx context.Context, arg database.CountFrontierParams) (int64, error)
+
+	HasVisited(ctx context.Context, url string) (int64, error)
+	InsertVisited(ctx context.Context, url string) error
+
+	StartRun(ctx context.Context, arg database.StartRunParams) (int64, error)
+	FinishRun(ctx context.Context, arg database.FinishRunParams) error
+	InsertSeedStats(ctx context.Context, arg database.InsertSeedStatsParams) error
+	ListLatestRuns(ctx context.Context, limit int64) ([]database.CrawlRun, error)
+	ListSeedStats(ctx context.Context, runID int64) ([]database.SeedStat, error)
+
+	InsertFetchLog(ctx context.Context, arg database.InsertFetchLogParams) error
+	ListFetchAttempts(ctx context.Context, url string) ([]database.FetchLog, error)
+	ListHostFailures(ctx context.Context) ([]database.ListHostFailuresRow, error)
+	ListHostErrorStatuses(ctx context.Context) ([]database.ListHostErrorStatusesRow, error)
+
+	Close() error
+}
+
+// PageWriter is the part of a Store that WithTx hands to its callback.
+type PageWriter interface {
+	InsertData(ctx context.Context, arg database.InsertDataParams) (string, error)
+	InsertFetchLog(ctx context.Context, arg database.InsertFetchLogParams) error
+}
+
+type transactor interface {
+	withTx(ctx context.Context, fn func(PageWriter) error) error
+}
+
+// WithTx runs fn in a single transaction, committed only when fn succeeds.
+// Backends without transactions run fn against s directly.
+func WithTx(ctx context.Context, s Store, fn func(PageWriter) error) error {
+	if t, ok := s.(transactor); ok {
+		return t.withTx(ctx, fn)
+	}
+
+	return fn(s)
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+var _ Store = (*sqlStore)(nil)
+
+type sqlStore struct {
+	*database.Queries
+	db *sql.DB
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlStore) withTx(ctx context.Context, fn func(PageWriter) error) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		return fn(s.Queries.WithTx(tx))
+	})
+}
+
+// Open connects to the kind of backend named, one of libsql (the default),
+// sqlite, postgres or jsonl. migrations holds the sql directory: sqlite is
+// migrated with its schema files and postgres with its postgres files, while
+// libsql databases are still migrated with scripts/upmigration.sh.
+func Open(kind, url string, migrations fs.FS) (Store, error) {
+	switch kind {
+	case "", "libsql":
+		db, err := sql.Open("libsql", url)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlStore{Queries: database.New(db), db: db}, nil
+	case "sqlite":
+		db, err := sql.Open("sqlite", url+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+		if err != nil {
+			return nil, err
+		}
+		// sqlite allows a single writer, so crawler goroutines queue here
+		// rather than failing with "database is locked".
+		db.SetMaxOpenConns(1)
+		if err := migrate(db, goose.DialectSQLite3, migrations, "schema"); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &sqlStore{Queries: database.New(db), db: db}, nil
+	case "postgres":
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrate(db, goose.DialectPostgres, migrations, "postgres"); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &Postgres{db: db, conn: db}, nil
+	case "jsonl":
+		return OpenFileStore(url)
+	}
+
+	return nil, fmt.Errorf("unknown store: %s", kind)
+}
+
+func migrate(db *sql.DB, dialect goose.Dialect, migrations fs.FS, dir string) error {
+	files, err := fs.Sub(migrations, dir)
+	if err != nil {
+		return err
+	}
+
+	provider, err := goose.NewProvider(dialect, db, files)
+	if err != nil {
+		return err
+	}
+
+	_, err = provider.Up(context.TODO())
+	return err
+}
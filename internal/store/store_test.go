@@ -0,0 +1,465 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+)
+
+// backends opens a fresh store of every kind available in this environment.
+// libsql and postgres need a server, so they only run when their test URL is
+// set, and must point at an empty database.
+func backends(t *testing.T) map[string]func(*testing.T) Store {
+	t.Helper()
+
+	dir := t.TempDir()
+	open := func(kind, url string) func(*testing.T) Store {
+		return func(t *testing.T) Store {
+			t.Helper()
+			s, err := Open(kind, url, os.DirFS(filepath.Join("..", "..", "sql")))
+			if err != nil {
+				t.Fatalf("couldn't open %s store: %v", kind, err)
+			}
+			return s
+		}
+	}
+
+	opened := map[string]func(*testing.T) Store{
+		"sqlite": open("sqlite", filepath.Join(dir, "crawler.db")),
+		"jsonl":  open("jsonl", filepath.Join(dir, "jsonl")),
+	}
+	if url := os.Getenv("LIBSQL_TEST_URL"); url != "" {
+		opened["libsql"] = open("libsql", url)
+	}
+	if url := os.Getenv("POSTGRES_TEST_URL"); url != "" {
+		opened["postgres"] = open("postgres", url)
+	}
+
+	return opened
+}
+
+func TestStoreConformance(t *testing.T) {
+	for kind, open := range backends(t) {
+		t.Run(kind, func(t *testing.T) {
+			testStore(t, open)
+			testFrontier(t, open)
+		})
+	}
+}
+
+func testStore(t *testing.T, open func(*testing.T) Store) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s := open(t)
+
+	pages := []database.InsertDataParams{
+		{Url: "https://www.google.com/a", Content: "a", ContentType: "text/html", Simhash: 1, RawHash: "ff", CreatedAt: now, UpdatedAt: now},
+		{Url: "https://www.google.com/b", Content: "b", ContentType: "text/html", Simhash: 2, CreatedAt: now, UpdatedAt: now},
+		{Url: "https://www.google.com/c", Content: "c", ContentType: "text/html", Simhash: 1, DuplicateOf: sql.NullString{String: "https://www.google.com/a", Valid: true}, CreatedAt: now, UpdatedAt: now},
+		{Url: "https://www.google.com/b", Content: "b2", ContentType: "text/plain", Simhash: 3, RawHash: "aa", CreatedAt: now.Add(time.Hour), UpdatedAt: now.Add(time.Hour)},
+	}
+	for _, page := range pages {
+		url, err := s.InsertData(ctx, page)
+		if err != nil {
+			t.Fatalf("F27: test case 1 failed, unexpected error: %v", err)
+		}
+		if url != page.Url {
+			t.Errorf("F27: test case 1 failed, %s != %s", url, page.Url)
+		}
+	}
+
+	fingerprints, err := s.ListFingerprints(ctx)
+	if err != nil {
+		t.Fatalf("F27: test case 2 failed, unexpected error: %v", err)
+	}
+	slices.SortFunc(fingerprints, func(a, b database.ListFingerprintsRow) int {
+		return int(a.Simhash - b.Simhash)
+	})
+	expectedFingerprints := []database.ListFingerprintsRow{
+		{Url: "https://www.google.com/a", Simhash: 1},
+		{Url: "https://www.google.com/b", Simhash: 3},
+	}
+	if !slices.Equal(fingerprints, expectedFingerprints) {
+		t.Errorf("F27: test case 2 failed, %v != %v", fingerprints, expectedFingerprints)
+	}
+
+	clusters, err := s.ListDuplicateClusters(ctx)
+	if err != nil {
+		t.Fatalf("F27: test case 3 failed, unexpected error: %v", err)
+	}
+	expectedClusters := []database.ListDuplicateClustersRow{
+		{DuplicateOf: sql.NullString{String: "https://www.google.com/a", Valid: true}, Url: "https://www.google.com/c"},
+	}
+	if !slices.Equal(clusters, expectedClusters) {
+		t.Errorf("F27: test case 3 failed, %v != %v", clusters, expectedClusters)
+	}
+
+	for i, url := range []string{"www.google.com", "www.google.com"} {
+		if err := s.InsertVisited(ctx, url); err != nil {
+			t.Fatalf("F27: test case 4 failed, unexpected error on insert %d: %v", i, err)
+		}
+	}
+	for url, expected := range map[string]int64{"www.google.com": 1, "www.bing.com": 0} {
+		visited, err := s.HasVisited(ctx, url)
+		if err != nil {
+			t.Fatalf("F27: test case 5 failed, unexpected error: %v", err)
+		}
+		if visited != expected {
+			t.Errorf("F27: test case 5 failed, %s: %d != %d", url, visited, expected)
+		}
+	}
+
+	first, err := s.StartRun(ctx, database.StartRunParams{StartedAt: now, ConfigHash: "abc"})
+	if err != nil {
+		t.Fatalf("F27: test case 6 failed, unexpected error: %v", err)
+	}
+	second, err := s.StartRun(ctx, database.StartRunParams{StartedAt: now.Add(time.Hour), ConfigHash: "def"})
+	if err != nil {
+		t.Fatalf("F27: test case 6 failed, unexpected error: %v", err)
+	}
+	if second <= first {
+		t.Errorf("F27: test case 6 failed, run ids %d then %d aren't increasing", first, second)
+	}
+
+	finished := now.Add(2 * time.Hour)
+	if err := s.FinishRun(ctx, database.FinishRunParams{FinishedAt: sql.NullTime{Time: finished, Valid: true}, Status: "completed", ID: second}); err != nil {
+		t.Fatalf("F27: test case 7 failed, unexpected error: %v", err)
+	}
+
+	// A seed crawled twice in a run has its stats added up.
+	stats := []database.InsertSeedStatsParams{
+		{RunID: second, Seed: "https://www.google.com/", PagesFetched: 10, PagesStored: 8, PagesFailed: 2, Bytes: 1000, DurationMs: 60000},
+		{RunID: second, Seed: "https://arxiv.org/", PagesFetched: 5},
+		{RunID: second, Seed: "https://www.google.com/", PagesFetched: 11, PagesStored: 9, PagesFailed: 2, Bytes: 1100, DurationMs: 61000},
+	}
+	for _, stat := range stats {
+		if err := s.InsertSeedStats(ctx, stat); err != nil {
+			t.Fatalf("F27: test case 8 failed, unexpected error: %v", err)
+		}
+	}
+
+	for i := range 3 {
+		attempt := database.InsertFetchLogParams{
+			RunID:     second,
+			Url:       "https://www.google.com/a",
+			Host:      "www.google.com",
+			Seed:      "https://www.google.com/",
+			Status:    404,
+			Outcome:   "fetch-error",
+			Error:     "400+ status code",
+			CreatedAt: now.Add(time.Duration(i) * time.Second),
+		}
+		if i == 2 {
+			attempt.Url = "https://www.google.com/b"
+			attempt.Status = 200
+			attempt.Outcome = "stored"
+			attempt.Error = ""
+		}
+		if err := s.InsertFetchLog(ctx, attempt); err != nil {
+			t.Fatalf("F27: test case 9 failed, unexpected error: %v", err)
+		}
+	}
+	if err := s.InsertFetchLog(ctx, database.InsertFetchLogParams{RunID: second, Url: "https://arxiv.org/a", Host: "arxiv.org", Status: 200, Outcome: "too-short", CreatedAt: now}); err != nil {
+		t.Fatalf("F27: test case 9 failed, unexpected error: %v", err)
+	}
+
+	// Everything written above must survive reopening the store.
+	if err := s.Close(); err != nil {
+		t.Fatalf("F27: test case 10 failed, unexpected error: %v", err)
+	}
+	s = open(t)
+	defer s.Close()
+
+	visited, err := s.HasVisited(ctx, "www.google.com")
+	if err != nil || visited != 1 {
+		t.Errorf("F27: test case 10 failed, visited %d after reopening, error %v", visited, err)
+	}
+
+	runs, err := s.ListLatestRuns(ctx, 1)
+	if err != nil {
+		t.Fatalf("F27: test case 11 failed, unexpected error: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("F27: test case 11 failed, %d != %d", len(runs), 1)
+	}
+	run := runs[0]
+	if run.ID != second || run.Status != "completed" || run.ConfigHash != "def" || !run.StartedAt.Equal(now.Add(time.Hour)) || !run.FinishedAt.Valid || !run.FinishedAt.Time.Equal(finished) {
+		t.Errorf("F27: test case 11 failed, unexpected run %+v", run)
+	}
+
+	runs, err = s.ListLatestRuns(ctx, 5)
+	if err != nil {
+		t.Fatalf("F27: test case 12 failed, unexpected error: %v", err)
+	}
+	if len(runs) != 2 || runs[1].ID != first || runs[1].Status != "running" || runs[1].FinishedAt.Valid {
+		t.Errorf("F27: test case 12 failed, unexpected runs %+v", runs)
+	}
+
+	seedStats, err := s.ListSeedStats(ctx, second)
+	if err != nil {
+		t.Fatalf("F27: test case 13 failed, unexpected error: %v", err)
+	}
+	expectedStats := []database.SeedStat{
+		{RunID: second, Seed: "https://arxiv.org/", PagesFetched: 5},
+		{RunID: second, Seed: "https://www.google.com/", PagesFetched: 21, PagesStored: 17, PagesFailed: 4, Bytes: 2100, DurationMs: 121000},
+	}
+	if !slices.Equal(seedStats, expectedStats) {
+		t.Errorf("F27: test case 13 failed, %v != %v", seedStats, expectedStats)
+	}
+
+	attempts, err := s.ListFetchAttempts(ctx, "https://www.google.com/a")
+	if err != nil {
+		t.Fatalf("F27: test case 14 failed, unexpected error: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("F27: test case 14 failed, %d != %d", len(attempts), 2)
+	}
+	if attempts[0].ID >= attempts[1].ID || !attempts[0].CreatedAt.Equal(now) || attempts[0].Status != 404 || attempts[0].Error != "400+ status code" {
+		t.Errorf("F27: test case 14 failed, unexpected attempts %+v", attempts)
+	}
+
+	failures, err := s.ListHostFailures(ctx)
+	if err != nil {
+		t.Fatalf("F27: test case 15 failed, unexpected error: %v", err)
+	}
+	expectedFailures := []database.ListHostFailuresRow{
+		{Host: "arxiv.org", Outcome: "too-short", Attempts: 1},
+		{Host: "www.google.com", Outcome: "fetch-error", Attempts: 2},
+	}
+	if !slices.Equal(failures, expectedFailures) {
+		t.Errorf("F27: test case 15 failed, %v != %v", failures, expectedFailures)
+	}
+
+	statuses, err := s.ListHostErrorStatuses(ctx)
+	if err != nil {
+		t.Fatalf("F27: test case 16 failed, unexpected error: %v", err)
+	}
+	expectedStatuses := []database.ListHostErrorStatusesRow{
+		{Host: "www.google.com", Status: 404, Attempts: 2},
+	}
+	if !slices.Equal(statuses, expectedStatuses) {
+		t.Errorf("F27: test case 16 failed, %v != %v", statuses, expectedStatuses)
+	}
+
+	batch := []database.InsertDataParams{
+		{Url: "https://www.google.com/d", Content: "d", Simhash: 4, CreatedAt: now, UpdatedAt: now},
+		{Url: "https://www.google.com/e", Content: "e", Simhash: 5, CreatedAt: now, UpdatedAt: now},
+	}
+	err = WithTx(ctx, s, func(w PageWriter) error {
+		for _, page := range batch {
+			if _, err := w.InsertData(ctx, page); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("F27: test case 17 failed, unexpected error: %v", err)
+	}
+	if fingerprints, _ := s.ListFingerprints(ctx); len(fingerprints) != 4 {
+		t.Errorf("F27: test case 17 failed, %d != %d", len(fingerprints), 4)
+	}
+
+	raw, err := s.ListRawPages(ctx)
+	if err != nil {
+		t.Fatalf("F27: test case 18 failed, unexpected error: %v", err)
+	}
+	expectedRaw := []database.ListRawPagesRow{
+		{Url: "https://www.google.com/a", ContentType: "text/html", RawHash: "ff"},
+		{Url: "https://www.google.com/b", ContentType: "text/plain", RawHash: "aa"},
+	}
+	if !slices.Equal(raw, expectedRaw) {
+		t.Errorf("F27: test case 18 failed, %v != %v", raw, expectedRaw)
+	}
+
+	after, err := s.ListPagesAfter(ctx, database.ListPagesAfterParams{Url: "https://www.google.com/a", Limit: 3})
+	if err != nil {
+		t.Fatalf("F27: test case 19 failed, unexpected error: %v", err)
+	}
+	got := []string{}
+	for _, page := range after {
+		got = append(got, page.Url+" "+page.Content+" "+page.DuplicateOf.String)
+		if !page.CreatedAt.Equal(now) {
+			t.Errorf("F27: test case 19 failed, %v != %v", page.CreatedAt, now)
+		}
+	}
+	expectedAfter := []string{"https://www.google.com/b b2 ", "https://www.google.com/c c https://www.google.com/a", "https://www.google.com/d d "}
+	if !slices.Equal(got, expectedAfter) {
+		t.Errorf("F27: test case 19 failed, %v != %v", got, expectedAfter)
+	}
+
+	page, err := s.GetPage(ctx, "https://www.google.com/b")
+	if err != nil {
+		t.Fatalf("F27: test case 20 failed, unexpected error: %v", err)
+	}
+	if page.Content != "b2" || page.ContentType != "text/plain" || page.RawHash != "aa" {
+		t.Errorf("F27: test case 20 failed, %v != %v", page, pages[3])
+	}
+	if !page.CreatedAt.Equal(now) || !page.UpdatedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("F27: test case 32 failed, stored again at %v, got created %v updated %v", now.Add(time.Hour), page.CreatedAt, page.UpdatedAt)
+	}
+	if _, err := s.GetPage(ctx, "https://www.google.com/z"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("F27: test case 21 failed, %v != %v", err, sql.ErrNoRows)
+	}
+
+	for _, page := range []database.InsertDataParams{
+		{Url: "http://www.google.com:8080/x", Language: "en", DuplicateOf: sql.NullString{String: "https://www.google.com/a", Valid: true}, CreatedAt: now, UpdatedAt: now},
+		{Url: "https://arxiv.org/x", Language: "en", DuplicateOf: sql.NullString{String: "https://www.google.com/a", Valid: true}, CreatedAt: now, UpdatedAt: now},
+	} {
+		if _, err := s.InsertData(ctx, page); err != nil {
+			t.Fatalf("F27: test case 31 failed, unexpected error: %v", err)
+		}
+	}
+	matching := []struct {
+		arg      database.ListPagesMatchingParams
+		expected []string
+	}{
+		{database.ListPagesMatchingParams{Host: "www.google.com", Language: "en", Limit: 10}, []string{"http://www.google.com:8080/x"}},
+		{database.ListPagesMatchingParams{Host: "arxiv.org", Limit: 10}, []string{"https://arxiv.org/x"}},
+		{database.ListPagesMatchingParams{Url: "https://www.google.com/a", Host: "www.google.com", Limit: 2}, []string{"https://www.google.com/b", "https://www.google.com/c"}},
+		{database.ListPagesMatchingParams{Language: "en", Limit: 10}, []string{"http://www.google.com:8080/x", "https://arxiv.org/x"}},
+		{database.ListPagesMatchingParams{Host: "google.com", Limit: 10}, []string{}},
+		{database.ListPagesMatchingParams{Since: sql.NullTime{Time: now.Add(time.Minute), Valid: true}, Limit: 10}, []string{"https://www.google.com/b"}},
+		{database.ListPagesMatchingParams{Host: "www.google.com", Until: sql.NullTime{Time: now.Add(time.Hour), Valid: true}, Limit: 10}, []string{"http://www.google.com:8080/x", "https://www.google.com/a", "https://www.google.com/c", "https://www.google.com/d", "https://www.google.com/e"}},
+	}
+	for _, test := range matching {
+		pages, err := s.ListPagesMatching(ctx, test.arg)
+		if err != nil {
+			t.Fatalf("F27: test case 31 failed, unexpected error: %v", err)
+		}
+		got := []string{}
+		for _, page := range pages {
+			got = append(got, page.Url)
+		}
+		if !slices.Equal(got, test.expected) {
+			t.Errorf("F27: test case 31 failed, %+v: %v != %v", test.arg, got, test.expected)
+		}
+	}
+
+	if _, ok := s.(transactor); !ok {
+		return
+	}
+	err = WithTx(ctx, s, func(w PageWriter) error {
+		if _, err := w.InsertData(ctx, database.InsertDataParams{Url: "https://www.google.com/f", Simhash: 6, CreatedAt: now, UpdatedAt: now}); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	if err == nil {
+		t.Errorf("F27: test case 22 failed, expected the callback's error")
+	}
+	if fingerprints, _ := s.ListFingerprints(ctx); len(fingerprints) != 4 {
+		t.Errorf("F27: test case 22 failed, rolled back write is visible, %d != %d", len(fingerprints), 4)
+	}
+}
+
+func testFrontier(t *testing.T, open func(*testing.T) Store) {
+	ctx := context.Background()
+	s := open(t)
+
+	enqueued := []database.EnqueueFrontierParams{
+		{Queue: "q1", Url: "https://www.google.com/a"},
+		{Queue: "q1", Url: "https://www.google.com/b", Depth: 1, Priority: 1},
+		{Queue: "q1", Url: "https://www.google.com/c", Depth: 2},
+		{Queue: "q1", Url: "https://www.google.com/a", Priority: 5},
+		{Queue: "q2", Url: "https://www.google.com/a"},
+	}
+	for _, arg := range enqueued {
+		if err := s.EnqueueFrontier(ctx, arg); err != nil {
+			t.Fatalf("F27: test case 23 failed, unexpected error: %v", err)
+		}
+	}
+	if count, err := s.CountFrontier(ctx, database.CountFrontierParams{Queue: "q1", Now: 0}); err != nil || count != 3 {
+		t.Errorf("F27: test case 23 failed, %d != %d, error %v", count, 3, err)
+	}
+
+	listed, err := s.ListFrontier(ctx, database.ListFrontierParams{Queue: "q1", Now: 0, Limit: 2})
+	if err != nil {
+		t.Fatalf("F27: test case 24 failed, unexpected error: %v", err)
+	}
+	expectedListed := []string{"https://www.google.com/b", "https://www.google.com/a"}
+	if got := frontierURLs(listed); !slices.Equal(got, expectedListed) {
+		t.Errorf("F27: test case 24 failed, %v != %v", got, expectedListed)
+	}
+	if listed[0].Depth != 1 || listed[0].Priority != 1 || listed[0].Queue != "q1" {
+		t.Errorf("F27: test case 24 failed, unexpected row %+v", listed[0])
+	}
+
+	leased, err := s.LeaseFrontier(ctx, database.LeaseFrontierParams{LeaseOwner: "w1", LeasedUntil: 100, Queue: "q1", Now: 0, Limit: 2})
+	if err != nil {
+		t.Fatalf("F27: test case 25 failed, unexpected error: %v", err)
+	}
+	// UPDATE ... RETURNING doesn't promise an order.
+	expectedLeased := []string{"https://www.google.com/a", "https://www.google.com/b"}
+	if got := slices.Sorted(slices.Values(frontierURLs(leased))); !slices.Equal(got, expectedLeased) {
+		t.Errorf("F27: test case 25 failed, %v != %v", got, expectedLeased)
+	}
+	for _, row := range leased {
+		if row.LeaseOwner != "w1" || row.LeasedUntil != 100 {
+			t.Errorf("F27: test case 25 failed, unexpected lease %+v", row)
+		}
+	}
+
+	leased, err = s.LeaseFrontier(ctx, database.LeaseFrontierParams{LeaseOwner: "w2", LeasedUntil: 200, Queue: "q1", Now: 50, Limit: 5})
+	if err != nil {
+		t.Fatalf("F27: test case 26 failed, unexpected error: %v", err)
+	}
+	expectedLeased = []string{"https://www.google.com/c"}
+	if got := frontierURLs(leased); !slices.Equal(got, expectedLeased) {
+		t.Errorf("F27: test case 26 failed, %v != %v", got, expectedLeased)
+	}
+	if count, err := s.CountFrontier(ctx, database.CountFrontierParams{Queue: "q1", Now: 50}); err != nil || count != 0 {
+		t.Errorf("F27: test case 26 failed, %d != %d, error %v", count, 0, err)
+	}
+
+	// Leases survive reopening, and run out when their time passes.
+	if err := s.Close(); err != nil {
+		t.Fatalf("F27: test case 27 failed, unexpected error: %v", err)
+	}
+	s = open(t)
+	defer s.Close()
+
+	if count, err := s.CountFrontier(ctx, database.CountFrontierParams{Queue: "q1", Now: 150}); err != nil || count != 2 {
+		t.Errorf("F27: test case 27 failed, %d != %d, error %v", count, 2, err)
+	}
+
+	if err := s.AckFrontier(ctx, database.AckFrontierParams{Queue: "q1", Url: "https://www.google.com/a", LeaseOwner: "w2"}); err != nil {
+		t.Fatalf("F27: test case 28 failed, unexpected error: %v", err)
+	}
+	if count, _ := s.CountFrontier(ctx, database.CountFrontierParams{Queue: "q1", Now: 150}); count != 2 {
+		t.Errorf("F27: test case 28 failed, acked by a worker not holding the lease, %d != %d", count, 2)
+	}
+	if err := s.AckFrontier(ctx, database.AckFrontierParams{Queue: "q1", Url: "https://www.google.com/a", LeaseOwner: "w1"}); err != nil {
+		t.Fatalf("F27: test case 29 failed, unexpected error: %v", err)
+	}
+
+	leased, err = s.LeaseFrontier(ctx, database.LeaseFrontierParams{LeaseOwner: "w3", LeasedUntil: 300, Queue: "q1", Now: 150, Limit: 5})
+	if err != nil {
+		t.Fatalf("F27: test case 29 failed, unexpected error: %v", err)
+	}
+	expectedLeased = []string{"https://www.google.com/b"}
+	if got := frontierURLs(leased); !slices.Equal(got, expectedLeased) {
+		t.Errorf("F27: test case 29 failed, %v != %v", got, expectedLeased)
+	}
+
+	if count, err := s.CountFrontier(ctx, database.CountFrontierParams{Queue: "q2", Now: 150}); err != nil || count != 1 {
+		t.Errorf("F27: test case 30 failed, %d != %d, error %v", count, 1, err)
+	}
+}
+
+func frontierURLs(rows []database.Frontier) []string {
+	urls := []string{}
+	for _, row := range rows {
+		urls = append(urls, row.Url)
+	}
+
+	return urls
+}
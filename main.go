@@ -1,33 +1,568 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"crypto/rand"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
-	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
 	"github.com/junwei890/crawler/src"
-	_ "github.com/tursodatabase/libsql-client-go/libsql"
+	"github.com/junwei890/crawler/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+//go:embed sql/schema/*.sql sql/postgres/*.sql
+var migrations embed.FS
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	logger, err := newLogger(os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
 	dbUrl := os.Getenv("DB_URL")
 
-	db, err := sql.Open("libsql", dbUrl)
+	schema, err := fs.Sub(migrations, "sql")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queries, err := store.Open(os.Getenv("STORE"), dbUrl, schema)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer queries.Close()
+
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "duplicates":
+		if err := src.Duplicates(queries, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "report":
+		if err := src.Report(queries, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "failures":
+		if err := src.Failures(queries, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "attempts":
+		if len(os.Args) < 3 {
+			log.Fatal("usage: attempts <url>")
+		}
+		if err := src.Attempts(queries, os.Stdout, os.Args[2]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "export":
+		if err := export(queries, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "", "serve", "coordinator", "worker", "reprocess", "reextract":
+	default:
+		log.Fatalf("unknown command: %s", command)
+	}
+
+	pipeline, err := utils.ParsePipeline(os.Getenv("TEXT_PIPELINE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	distance := utils.DefaultSimDistance
+	if value := os.Getenv("DUPLICATE_DISTANCE"); value != "" {
+		distance, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	feedInterval := 15 * time.Minute
+	if value := os.Getenv("FEED_INTERVAL"); value != "" {
+		feedInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	batchSize := 50
+	if value := os.Getenv("STORE_BATCH_SIZE"); value != "" {
+		batchSize, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	batchInterval := 2 * time.Second
+	if value := os.Getenv("STORE_BATCH_INTERVAL"); value != "" {
+		batchInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	weights, err := utils.ParseWeights(os.Getenv("URL_WEIGHTS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	patterns, err := utils.PatternScorer(weights)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The crawl runs on the system clock, which scoring and leasing queued
+	// URLs go by too.
+	clock := src.SystemClock{}
+	scorers := []utils.Scorer{
+		utils.DepthScorer(1),
+		utils.SitemapScorer(2),
+		utils.InLinkScorer(1),
+		utils.FreshnessScorer(1, 30*24*time.Hour, clock.Now),
+		patterns,
+	}
+
+	newQueue, err := queueFactory(queries, os.Getenv("QUEUE"), clock, scorers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	visitedFile := os.Getenv("VISITED_FILE")
+	exact, closeExact, err := visitedStore(queries, os.Getenv("VISITED_STORE"), visitedFile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	defer closeExact()
+	seen := utils.NewSeenSet(1<<20, 0.01, exact)
+
+	if visitedFile != "" {
+		if err := seen.Load(visitedFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Fatal(err)
+		}
+	}
+	if err := seen.Rebuild(); err != nil {
+		log.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	metrics := src.NewMetrics(registry)
+	src.RegisterVisited(registry, seen)
+
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		go func() {
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				logger.Error("metrics listener stopped", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	cfg := src.Config{
+		Pipeline:       pipeline,
+		Duplicates:     utils.NewSimIndex(distance),
+		SkipDuplicates: os.Getenv("DUPLICATE_MODE") == "skip",
+		FeedInterval:   feedInterval,
+		Scorers:        scorers,
+		NewQueue:       newQueue,
+		Visited:        seen,
+		Logger:         logger,
+		Metrics:        metrics,
+		ConfigHash:     src.HashSettings(settings()),
+		BatchSize:      batchSize,
+		BatchInterval:  batchInterval,
+	}
+
+	if dir := os.Getenv("BLOB_DIR"); dir != "" {
+		cfg.Blobs, err = utils.NewBlobDir(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if command == "reextract" {
+		if err := src.Reextract(queries, cfg, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	warcDir := os.Getenv("WARC_DIR")
+
+	if command == "reprocess" {
+		paths := os.Args[2:]
+		if len(paths) == 0 && warcDir != "" {
+			paths = []string{warcDir}
+		}
+		if err := src.Reprocess(queries, cfg, paths, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	queries := database.New(db)
+	if warcDir != "" {
+		warcSize := int64(utils.DefaultWARCSize)
+		if value := os.Getenv("WARC_MAX_SIZE"); value != "" {
+			warcSize, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 
-	if err := src.Init(queries); err != nil {
+		cfg.Archive, err = utils.NewWARCWriter(warcDir, "crawler", warcSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch command {
+	case "serve":
+		err = serve(queries, cfg, os.Getenv("ADMIN_ADDR"))
+	case "coordinator":
+		err = coordinate(queries, cfg, os.Getenv("COORDINATOR_ADDR"))
+	case "worker":
+		err = work(queries, cfg, os.Getenv("COORDINATOR_URL"))
+	default:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = src.Init(ctx, queries, cfg)
+		stop()
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
+
+	if cfg.Archive != nil {
+		if err := cfg.Archive.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if visitedFile != "" {
+		if err := seen.Save(visitedFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// visitedStore opens the exact store behind the visited set, returning a
+// func that closes it. The disk store sits next to visitedFile so the two are
+// kept together, or in a temporary directory removed on close without one.
+func visitedStore(queries store.Store, kind, visitedFile string) (utils.ExactStore, func() error, error) {
+	switch kind {
+	case "memory":
+		return utils.NewMemoryStore(), func() error { return nil }, nil
+	case "db":
+		return src.NewDBStore(queries), func() error { return nil }, nil
+	case "", "disk":
+	default:
+		return nil, nil, fmt.Errorf("unknown visited store: %s", kind)
+	}
+
+	if visitedFile != "" {
+		exact, err := utils.OpenDiskStore(visitedFile + ".db")
+		if err != nil {
+			return nil, nil, err
+		}
+		return exact, exact.Close, nil
+	}
+
+	dir, err := os.MkdirTemp("", "visited")
+	if err != nil {
+		return nil, nil, err
+	}
+	exact, err := utils.OpenDiskStore(filepath.Join(dir, "visited.db"))
+	if err != nil {
+		return nil, nil, errors.Join(err, os.RemoveAll(dir))
+	}
+
+	return exact, func() error {
+		return errors.Join(exact.Close(), os.RemoveAll(dir))
+	}, nil
+}
+
+var settingKeys = []string{
+	"TEXT_PIPELINE",
+	"DUPLICATE_DISTANCE",
+	"DUPLICATE_MODE",
+	"URL_WEIGHTS",
+	"QUEUE",
+	"VISITED_STORE",
+	"FEED_INTERVAL",
+}
+
+// settings returns the environment that changes what a crawl stores, leaving
+// out paths and logging which don't.
+func settings() map[string]string {
+	values := map[string]string{}
+	for _, key := range settingKeys {
+		values[key] = os.Getenv(key)
+	}
+
+	return values
+}
+
+// serve runs the admin API until interrupted, then cancels whatever is still
+// crawling and finishes the run.
+func serve(queries store.Store, cfg src.Config, addr string) error {
+	// The admin API has no auth and starts crawls of whatever it's sent, so
+	// it's only reachable locally unless asked otherwise.
+	if addr == "" {
+		addr = "127.0.0.1:8080"
+	}
+
+	controller, err := src.NewController(queries, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: addr, Handler: src.NewServer(controller)}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.ListenAndServe()
+	}()
+	cfg.Logger.Info("admin api listening", slog.String("addr", addr))
+
+	select {
+	case err = <-failed:
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdown)
+	}
+
+	return errors.Join(err, controller.Close())
+}
+
+// coordinate leases the seeds in links.txt out to workers until they've all
+// been crawled or it's interrupted, then finishes the run.
+func coordinate(queries store.Store, cfg src.Config, addr string) error {
+	if addr == "" {
+		addr = ":8081"
+	}
+
+	ttl := src.DefaultLeaseTTL
+	if value := os.Getenv("LEASE_TTL"); value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err := os.ReadFile("links.txt")
+	if err != nil {
+		return err
+	}
+	seeds, err := utils.ParseSeeds(file)
+	if err != nil {
+		return err
+	}
+
+	coordinator, err := src.NewCoordinator(queries, cfg, seeds, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go coordinator.Run(ctx)
+
+	server := &http.Server{Addr: addr, Handler: coordinator.Handler()}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- server.ListenAndServe()
+	}()
+	cfg.Logger.Info("coordinator listening", slog.String("addr", addr))
+
+	select {
+	case err = <-failed:
+	case <-ctx.Done():
+	case <-coordinator.Done():
+		// Keep answering for a lease's length so polling workers hear the
+		// crawl is done rather than finding nobody there.
+		select {
+		case <-time.After(ttl):
+		case <-ctx.Done():
+		}
+	}
+	if err == nil {
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err = server.Shutdown(shutdown)
+	}
+
+	return errors.Join(err, coordinator.Close())
+}
+
+// work crawls what the coordinator leases until the crawl is done or it's
+// interrupted, handing back whatever it hasn't got to.
+func work(queries store.Store, cfg src.Config, coordinatorURL string) error {
+	if coordinatorURL == "" {
+		coordinatorURL = "http://localhost:8081"
+	}
+
+	id := os.Getenv("WORKER_ID")
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	size := 20
+	if value := os.Getenv("LEASE_SIZE"); value != "" {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if size <= 0 {
+			return fmt.Errorf("LEASE_SIZE must be positive, got %d", size)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg.Logger.Info("worker started", slog.String("worker", id), slog.String("coordinator", coordinatorURL))
+
+	return src.NewWorker(id, coordinatorURL, queries, cfg).Run(ctx, size)
+}
+
+func export(queries store.Store, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "jsonl", "jsonl, csv, parquet or markdown")
+	out := flags.String("out", "", "file to write, or directory for markdown; stdout if empty")
+	seed := flags.String("seed", "", "only pages on this seed's host")
+	since := flags.String("since", "", "only pages stored on or after this date or RFC 3339 time")
+	until := flags.String("until", "", "only pages stored before this date or RFC 3339 time")
+	language := flags.String("language", "", "only pages in this language")
+	minLength := flags.Int("min-length", 0, "only pages with at least this many characters of content")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filter := src.ExportFilter{Seed: *seed, Language: *language, MinLength: *minLength}
+	var err error
+	if filter.Since, err = parseTime(*since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseTime(*until); err != nil {
+		return err
+	}
+
+	exporter, err := src.NewExporter(*format, *out)
+	if err != nil {
+		return err
+	}
+
+	// The summary goes to stderr so it doesn't end up in an export written
+	// to stdout.
+	return src.Export(queries, filter, exporter, os.Stderr)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.DateOnly, value); err == nil {
+		return parsed, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func newLogger(format, level string) (*slog.Logger, error) {
+	var threshold slog.Level
+	if level != "" {
+		if err := threshold.UnmarshalText([]byte(level)); err != nil {
+			return nil, err
+		}
+	}
+	options := &slog.HandlerOptions{Level: threshold}
+
+	switch format {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format: %s", format)
+}
+
+func queueFactory(queries store.Store, kind string, clock src.Clock, scorers []utils.Scorer) (func(utils.Seed) (utils.QueueOps, error), error) {
+	switch kind {
+	case "", "priority":
+		return func(utils.Seed) (utils.QueueOps, error) {
+			return utils.NewFrontier(scorers...), nil
+		}, nil
+	case "memory":
+		return func(utils.Seed) (utils.QueueOps, error) {
+			return &utils.Queue{}, nil
+		}, nil
+	case "disk":
+		dir := os.Getenv("QUEUE_DIR")
+		if dir == "" {
+			dir = os.TempDir()
+		}
+
+		limit := 10000
+		if value := os.Getenv("QUEUE_MEMORY"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			limit = parsed
+		}
+
+		// Files are named for this process as well as the seed, so processes
+		// sharing a QUEUE_DIR never open each other's.
+		process := rand.Text()[:8]
+		return func(seed utils.Seed) (utils.QueueOps, error) {
+			hasher := fnv.New64a()
+			hasher.Write([]byte(seed.URL))
+			return utils.NewDiskQueue(filepath.Join(dir, fmt.Sprintf("%s-%x.queue", process, hasher.Sum64())), limit, scorers...)
+		}, nil
+	case "store":
+		return func(seed utils.Seed) (utils.QueueOps, error) {
+			return src.NewStoreQueue(queries, seed.URL, clock, scorers...), nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown queue: %s", kind)
 }
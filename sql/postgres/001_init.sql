@@ -0,0 +1,61 @@
+-- +goose Up
+CREATE TABLE data (
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT UNIQUE NOT NULL,
+	content TEXT NOT NULL,
+	content_type TEXT NOT NULL DEFAULT 'text/html',
+	normalized TEXT NOT NULL DEFAULT '',
+	language TEXT NOT NULL DEFAULT '',
+	simhash BIGINT NOT NULL DEFAULT 0,
+	duplicate_of TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX data_duplicate_of_idx ON data (duplicate_of);
+
+CREATE TABLE visited (
+	url TEXT PRIMARY KEY
+);
+
+CREATE TABLE crawl_runs (
+	id BIGSERIAL PRIMARY KEY,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ,
+	config_hash TEXT NOT NULL,
+	status TEXT NOT NULL
+);
+
+CREATE TABLE seed_stats (
+	run_id BIGINT NOT NULL REFERENCES crawl_runs (id) ON DELETE CASCADE,
+	seed TEXT NOT NULL,
+	pages_fetched BIGINT NOT NULL,
+	pages_stored BIGINT NOT NULL,
+	pages_failed BIGINT NOT NULL,
+	bytes BIGINT NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	PRIMARY KEY (run_id, seed)
+);
+
+CREATE TABLE fetch_log (
+	id BIGSERIAL PRIMARY KEY,
+	run_id BIGINT NOT NULL REFERENCES crawl_runs (id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	host TEXT NOT NULL,
+	seed TEXT NOT NULL,
+	status BIGINT NOT NULL,
+	content_type TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	latency_ms BIGINT NOT NULL,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX fetch_log_url_idx ON fetch_log (url);
+CREATE INDEX fetch_log_host_outcome_idx ON fetch_log (host, outcome);
+
+-- +goose Down
+DROP TABLE fetch_log;
+DROP TABLE seed_stats;
+DROP TABLE crawl_runs;
+DROP TABLE visited;
+DROP TABLE data;
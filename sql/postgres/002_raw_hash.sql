@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE data ADD COLUMN raw_hash TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE data DROP COLUMN raw_hash;
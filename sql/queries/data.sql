@@ -1,7 +1,45 @@
 -- name: InsertData :one
-INSERT OR REPLACE INTO data (url, content, created_at, updated_at) VALUES (
+INSERT INTO data (url, content, content_type, normalized, language, simhash, duplicate_of, raw_hash, created_at, updated_at) VALUES (
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
 	?,
 	?,
 	?,
 	?
-) RETURNING url;
+) ON CONFLICT (url) DO UPDATE SET
+	content = excluded.content,
+	content_type = excluded.content_type,
+	normalized = excluded.normalized,
+	language = excluded.language,
+	simhash = excluded.simhash,
+	duplicate_of = excluded.duplicate_of,
+	raw_hash = excluded.raw_hash,
+	updated_at = excluded.updated_at
+RETURNING url;
+
+-- name: ListFingerprints :many
+SELECT url, simhash FROM data WHERE duplicate_of IS NULL;
+
+-- name: ListDuplicateClusters :many
+SELECT duplicate_of, url FROM data WHERE duplicate_of IS NOT NULL ORDER BY duplicate_of, url;
+
+-- name: ListRawPages :many
+SELECT url, content_type, raw_hash FROM data WHERE raw_hash != '' ORDER BY url;
+
+-- name: ListPagesAfter :many
+SELECT * FROM data WHERE url > ? ORDER BY url LIMIT ?;
+
+-- name: ListPagesMatching :many
+SELECT * FROM data WHERE url > sqlc.arg(url)
+	AND (sqlc.arg(host) = '' OR url LIKE 'http://' || sqlc.arg(host) || '/%' OR url LIKE 'https://' || sqlc.arg(host) || '/%' OR url LIKE 'http://' || sqlc.arg(host) || ':%' OR url LIKE 'https://' || sqlc.arg(host) || ':%')
+	AND (sqlc.arg(language) = '' OR language = sqlc.arg(language))
+	AND (sqlc.narg(since) IS NULL OR julianday(updated_at) >= julianday(sqlc.narg(since)))
+	AND (sqlc.narg(until) IS NULL OR julianday(updated_at) < julianday(sqlc.narg(until)))
+ORDER BY url LIMIT sqlc.arg(limit);
+
+-- name: GetPage :one
+SELECT * FROM data WHERE url = ?;
@@ -0,0 +1,23 @@
+-- name: InsertFetchLog :exec
+INSERT INTO fetch_log (run_id, url, host, seed, status, content_type, size, latency_ms, outcome, error, created_at) VALUES (
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?
+);
+
+-- name: ListFetchAttempts :many
+SELECT * FROM fetch_log WHERE url = ? ORDER BY id;
+
+-- name: ListHostFailures :many
+SELECT host, outcome, COUNT(*) AS attempts FROM fetch_log WHERE outcome != 'stored' GROUP BY host, outcome ORDER BY host, attempts DESC;
+
+-- name: ListHostErrorStatuses :many
+SELECT host, status, COUNT(*) AS attempts FROM fetch_log WHERE status >= 400 GROUP BY host, status ORDER BY host, attempts DESC;
@@ -0,0 +1,16 @@
+-- name: AckFrontier :exec
+DELETE FROM frontier WHERE queue = ? AND url = ? AND lease_owner = ?;
+
+-- name: CountFrontier :one
+SELECT COUNT(*) FROM frontier WHERE queue = ? AND leased_until <= sqlc.arg(now);
+
+-- name: EnqueueFrontier :exec
+INSERT INTO frontier (queue, url, depth, priority) VALUES (?, ?, ?, ?) ON CONFLICT (queue, url) DO NOTHING;
+
+-- name: LeaseFrontier :many
+UPDATE frontier SET lease_owner = ?, leased_until = ? WHERE id IN (
+	SELECT id FROM frontier WHERE queue = ? AND leased_until <= sqlc.arg(now) ORDER BY priority DESC, id LIMIT ?
+) RETURNING *;
+
+-- name: ListFrontier :many
+SELECT * FROM frontier WHERE queue = ? AND leased_until <= sqlc.arg(now) ORDER BY priority DESC, id LIMIT ?;
@@ -0,0 +1,28 @@
+-- name: StartRun :one
+INSERT INTO crawl_runs (started_at, config_hash, status) VALUES (?, ?, 'running') RETURNING id;
+
+-- name: FinishRun :exec
+UPDATE crawl_runs SET finished_at = ?, status = ? WHERE id = ?;
+
+-- name: InsertSeedStats :exec
+INSERT INTO seed_stats (run_id, seed, pages_fetched, pages_stored, pages_failed, bytes, duration_ms) VALUES (
+	?,
+	?,
+	?,
+	?,
+	?,
+	?,
+	?
+)
+ON CONFLICT (run_id, seed) DO UPDATE SET
+	pages_fetched = pages_fetched + excluded.pages_fetched,
+	pages_stored = pages_stored + excluded.pages_stored,
+	pages_failed = pages_failed + excluded.pages_failed,
+	bytes = bytes + excluded.bytes,
+	duration_ms = duration_ms + excluded.duration_ms;
+
+-- name: ListLatestRuns :many
+SELECT * FROM crawl_runs ORDER BY id DESC LIMIT ?;
+
+-- name: ListSeedStats :many
+SELECT * FROM seed_stats WHERE run_id = ? ORDER BY seed;
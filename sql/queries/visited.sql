@@ -0,0 +1,5 @@
+-- name: HasVisited :one
+SELECT EXISTS (SELECT 1 FROM visited WHERE url = ?);
+
+-- name: InsertVisited :exec
+INSERT OR IGNORE INTO visited (url) VALUES (?);
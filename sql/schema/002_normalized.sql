@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE data ADD COLUMN normalized TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE data DROP COLUMN normalized;
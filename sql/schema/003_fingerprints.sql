@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE data ADD COLUMN simhash INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE data ADD COLUMN duplicate_of TEXT;
+CREATE INDEX data_duplicate_of_idx ON data (duplicate_of);
+
+-- +goose Down
+DROP INDEX data_duplicate_of_idx;
+ALTER TABLE data DROP COLUMN duplicate_of;
+ALTER TABLE data DROP COLUMN simhash;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE data ADD COLUMN content_type TEXT NOT NULL DEFAULT 'text/html';
+
+-- +goose Down
+ALTER TABLE data DROP COLUMN content_type;
@@ -0,0 +1,7 @@
+-- +goose Up
+CREATE TABLE visited (
+	url TEXT PRIMARY KEY
+);
+
+-- +goose Down
+DROP TABLE visited;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE crawl_runs (
+	id INTEGER PRIMARY KEY,
+	started_at DATETIME NOT NULL,
+	finished_at DATETIME,
+	config_hash TEXT NOT NULL,
+	status TEXT NOT NULL
+);
+
+CREATE TABLE seed_stats (
+	run_id INTEGER NOT NULL REFERENCES crawl_runs (id) ON DELETE CASCADE,
+	seed TEXT NOT NULL,
+	pages_fetched INTEGER NOT NULL,
+	pages_stored INTEGER NOT NULL,
+	pages_failed INTEGER NOT NULL,
+	bytes INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	PRIMARY KEY (run_id, seed)
+);
+
+-- +goose Down
+DROP TABLE seed_stats;
+DROP TABLE crawl_runs;
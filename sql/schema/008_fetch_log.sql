@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE fetch_log (
+	id INTEGER PRIMARY KEY,
+	run_id INTEGER NOT NULL REFERENCES crawl_runs (id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	host TEXT NOT NULL,
+	seed TEXT NOT NULL,
+	status INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	latency_ms INTEGER NOT NULL,
+	outcome TEXT NOT NULL,
+	error TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX fetch_log_url_idx ON fetch_log (url);
+CREATE INDEX fetch_log_host_outcome_idx ON fetch_log (host, outcome);
+
+-- +goose Down
+DROP INDEX fetch_log_host_outcome_idx;
+DROP INDEX fetch_log_url_idx;
+DROP TABLE fetch_log;
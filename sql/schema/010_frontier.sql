@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE frontier (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	queue TEXT NOT NULL,
+	url TEXT NOT NULL,
+	depth INTEGER NOT NULL DEFAULT 0,
+	priority REAL NOT NULL DEFAULT 0,
+	lease_owner TEXT NOT NULL DEFAULT '',
+	leased_until INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (queue, url)
+);
+CREATE INDEX frontier_queue_priority_idx ON frontier (queue, priority DESC, id);
+
+-- +goose Down
+DROP TABLE frontier;
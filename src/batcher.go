@@ -0,0 +1,186 @@
+package src
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+)
+
+const (
+	batchAttempts = 3
+	batchBackoff  = 500 * time.Millisecond
+)
+
+type pendingPage struct {
+	params database.InsertDataParams
+	done   func(error)
+	// flush marks a request to write the batch so far, rather than a page.
+	flush bool
+}
+
+// Batcher writes pages behind the crawler, upserting them a batch at a time
+// in one transaction once size pages are waiting or interval has passed.
+// Pages' done callbacks run on a goroutine of their own, so a slow one never
+// holds up the writes behind it.
+type Batcher struct {
+	queries   store.Store
+	size      int
+	clock     Clock
+	pending   chan pendingPage
+	callbacks chan func()
+	stopped   chan struct{}
+	metrics   *Metrics
+	logger    Logger
+}
+
+// NewBatcher buffers up to four batches. Once that buffer is full, Add
+// blocks, which holds fetch workers to the pace the store can write at.
+func NewBatcher(queries store.Store, size int, interval time.Duration, clock Clock, metrics *Metrics, logger Logger) *Batcher {
+	size = max(size, 1)
+
+	b := &Batcher{
+		queries:   queries,
+		size:      size,
+		clock:     clock,
+		pending:   make(chan pendingPage, size*4),
+		callbacks: make(chan func(), size*4),
+		stopped:   make(chan struct{}),
+		metrics:   metrics,
+		logger:    logger,
+	}
+	// The ticker starts now, so the first tick is an interval after this.
+	go b.run(clock.NewTicker(interval))
+	go b.report()
+
+	return b
+}
+
+// Add queues a page for writing. done is called, in the order pages were
+// added, once the page is stored or with the error that stopped it being
+// stored.
+func (b *Batcher) Add(params database.InsertDataParams, done func(error)) {
+	page := pendingPage{params: params, done: done}
+
+	select {
+	case b.pending <- page:
+		return
+	default:
+	}
+
+	waiting := b.clock.Now()
+	b.pending <- page
+	b.metrics.BackpressureWait.Add(b.clock.Now().Sub(waiting).Seconds())
+}
+
+// Flush writes the pages added so far without waiting for the batch to fill
+// or the interval to pass, for callers about to wait on their callbacks.
+func (b *Batcher) Flush() {
+	b.pending <- pendingPage{flush: true}
+}
+
+// Close writes whatever is still queued and waits for it and its callbacks.
+// Add must not be called after Close.
+func (b *Batcher) Close() {
+	close(b.pending)
+	<-b.stopped
+}
+
+func (b *Batcher) run(ticker Ticker) {
+	defer close(b.callbacks)
+	defer ticker.Stop()
+
+	batch := make([]pendingPage, 0, b.size)
+	for {
+		select {
+		case page, ok := <-b.pending:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			if !page.flush {
+				batch = append(batch, page)
+			}
+			if len(batch) >= b.size || (page.flush && len(batch) > 0) {
+				b.flush(batch)
+				batch = make([]pendingPage, 0, b.size)
+			}
+		case <-ticker.C():
+			if len(batch) > 0 {
+				b.flush(batch)
+				batch = make([]pendingPage, 0, b.size)
+			}
+		}
+	}
+}
+
+// report runs callbacks until the writer has finished.
+func (b *Batcher) report() {
+	defer close(b.stopped)
+
+	for callback := range b.callbacks {
+		callback()
+	}
+}
+
+// done hands a page's outcome to the callback goroutine.
+func (b *Batcher) done(page pendingPage, err error) {
+	b.callbacks <- func() {
+		page.done(err)
+	}
+}
+
+// flush retries a failed batch with backoff, then falls back to writing its
+// pages one at a time so a single bad page doesn't take the rest with it.
+// Backing off holds up the batches behind it on purpose: they'd meet the
+// same store, and Add blocking once they fill the buffer is what slows the
+// crawl to the store's pace.
+func (b *Batcher) flush(batch []pendingPage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := b.retry(func() error {
+		return store.WithTx(context.TODO(), b.queries, func(w store.PageWriter) error {
+			for _, page := range batch {
+				if _, err := w.InsertData(context.TODO(), page.params); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err == nil {
+		for _, page := range batch {
+			b.done(page, nil)
+		}
+		return
+	}
+
+	b.logger.Warn("batch write failed, writing pages one at a time", slog.Int("pages", len(batch)), slog.String("error", err.Error()))
+	for _, page := range batch {
+		_, err := b.queries.InsertData(context.TODO(), page.params)
+		b.done(page, err)
+	}
+}
+
+func (b *Batcher) retry(write func() error) error {
+	var err error
+	for attempt := range batchAttempts {
+		if attempt > 0 {
+			b.metrics.StoreRetries.Inc()
+			<-b.clock.After(batchBackoff << (attempt - 1))
+		}
+
+		start := b.clock.Now()
+		err = write()
+		b.metrics.InsertLatency.Observe(b.clock.Now().Sub(start).Seconds())
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,127 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyStore fails inserts of bad, and of anything else its first failures
+// times.
+type flakyStore struct {
+	store.Store
+	mu       sync.Mutex
+	failures int
+	bad      string
+}
+
+func (s *flakyStore) InsertData(ctx context.Context, arg database.InsertDataParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if arg.Url == s.bad {
+		return "", errors.New("bad page")
+	}
+	if s.failures > 0 {
+		s.failures--
+		return "", errors.New("store unavailable")
+	}
+
+	return s.Store.InsertData(ctx, arg)
+}
+
+func TestBatcher(t *testing.T) {
+	queries := memoryStore(t)
+	cfg := testConfig()
+
+	// A callback that blocks doesn't hold up the writes after it.
+	batcher := NewBatcher(queries, 1, time.Hour, SystemClock{}, NewMetrics(nil), cfg.Logger)
+	release := make(chan struct{})
+	order := []string{}
+	batcher.Add(database.InsertDataParams{Url: "https://www.google.com/a"}, func(err error) {
+		<-release
+		order = append(order, "https://www.google.com/a")
+	})
+	batcher.Add(database.InsertDataParams{Url: "https://www.google.com/b"}, func(err error) {
+		order = append(order, "https://www.google.com/b")
+	})
+	eventually(t, "F41: test case 1", func() bool {
+		_, err := queries.GetPage(context.Background(), "https://www.google.com/b")
+		return err == nil
+	})
+	close(release)
+	batcher.Close()
+	if expected := []string{"https://www.google.com/a", "https://www.google.com/b"}; !slices.Equal(order, expected) {
+		t.Errorf("F41: test case 2 failed, %v != %v", order, expected)
+	}
+
+	// A failed batch is retried after backing off in simulated time.
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	metrics := NewMetrics(nil)
+	flaky := &flakyStore{Store: queries, failures: 2, bad: "https://www.google.com/bad"}
+	batcher = NewBatcher(flaky, 1, time.Hour, clock, metrics, cfg.Logger)
+	var stored error = errors.New("not called")
+	batcher.Add(database.InsertDataParams{Url: "https://www.google.com/c"}, func(err error) {
+		stored = err
+	})
+	batcher.Close()
+	if stored != nil {
+		t.Errorf("F41: test case 3 failed, unexpected error: %v", stored)
+	}
+	if clock.slept != batchBackoff+2*batchBackoff {
+		t.Errorf("F41: test case 4 failed, %v != %v", clock.slept, batchBackoff+2*batchBackoff)
+	}
+	if retries := testutil.ToFloat64(metrics.StoreRetries); retries != 2 {
+		t.Errorf("F41: test case 5 failed, %v != %v", retries, 2)
+	}
+
+	// A batch that keeps failing is written a page at a time, so only the
+	// bad page fails.
+	batcher = NewBatcher(flaky, 2, time.Hour, clock, metrics, cfg.Logger)
+	results := map[string]error{}
+	for _, url := range []string{"https://www.google.com/d", "https://www.google.com/bad"} {
+		batcher.Add(database.InsertDataParams{Url: url}, func(err error) {
+			results[url] = err
+		})
+	}
+	batcher.Close()
+	if err := results["https://www.google.com/d"]; err != nil {
+		t.Errorf("F41: test case 6 failed, unexpected error: %v", err)
+	}
+	if err := results["https://www.google.com/bad"]; err == nil {
+		t.Errorf("F41: test case 7 failed, expected an error storing the bad page")
+	}
+
+	// A batch that isn't full waits for an interval on the batcher's clock,
+	// or for a flush.
+	batcher = NewBatcher(queries, 10, time.Minute, clock, metrics, cfg.Logger)
+	written := make(chan error, 1)
+	batcher.Add(database.InsertDataParams{Url: "https://www.google.com/e"}, func(err error) {
+		written <- err
+	})
+	clock.advance(time.Second)
+	select {
+	case <-written:
+		t.Errorf("F41: test case 8 failed, page written before the interval passed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.advance(time.Minute)
+	if err := <-written; err != nil {
+		t.Errorf("F41: test case 9 failed, unexpected error: %v", err)
+	}
+	batcher.Add(database.InsertDataParams{Url: "https://www.google.com/f"}, func(err error) {
+		written <- err
+	})
+	batcher.Flush()
+	if err := <-written; err != nil {
+		t.Errorf("F41: test case 10 failed, unexpected error: %v", err)
+	}
+	batcher.Close()
+}
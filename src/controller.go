@@ -0,0 +1,526 @@
+package src
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+const (
+	SeedQueued    = "queued"
+	SeedRunning   = "running"
+	SeedPaused    = "paused"
+	SeedCompleted = "completed"
+	SeedFailed    = "failed"
+	SeedCancelled = "cancelled"
+)
+
+// maxCrawlers caps how many seeds crawl at once, the rest wait queued.
+const maxCrawlers = 1000
+
+// recentResults is how many fetch results a job keeps for inspection.
+const recentResults = 100
+
+var (
+	ErrJobNotFound = errors.New("no such crawl")
+	ErrJobState    = errors.New("crawl is in the wrong state")
+)
+
+// FetchResult is what happened to one dequeued URL.
+type FetchResult struct {
+	URL         string    `json:"url"`
+	Event       Event     `json:"event"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size,omitempty"`
+	DurationMs  int64     `json:"duration_ms,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// JobStatus is a snapshot of a job's progress.
+type JobStatus struct {
+	ID         int64           `json:"id"`
+	Seed       string          `json:"seed"`
+	State      string          `json:"state"`
+	Error      string          `json:"error,omitempty"`
+	Queued     int             `json:"queued"`
+	Fetched    int64           `json:"fetched"`
+	Stored     int64           `json:"stored"`
+	Failed     int64           `json:"failed"`
+	Bytes      int64           `json:"bytes"`
+	Events     map[Event]int64 `json:"events"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Job is one seed's crawl. Its frontier and recent results live here rather
+// than in the Crawler so they can be inspected while it runs.
+type Job struct {
+	ID   int64
+	Seed utils.Seed
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	clock  Clock
+	counts *seedStats
+
+	mu       sync.Mutex
+	state    string
+	err      error
+	resume   chan struct{}
+	queue    utils.QueueOps
+	current  string
+	recent   []FetchResult
+	events   map[Event]int64
+	started  time.Time
+	finished time.Time
+}
+
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{
+		ID:      j.ID,
+		Seed:    j.Seed.URL,
+		State:   j.state,
+		Fetched: j.counts.fetched.Load(),
+		Stored:  j.counts.stored.Load(),
+		Failed:  j.counts.failed.Load(),
+		Bytes:   j.counts.bytes.Load(),
+		Events:  maps.Clone(j.events),
+	}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	if j.queue != nil {
+		status.Queued = j.queue.Size()
+	}
+	if !j.started.IsZero() {
+		status.StartedAt = &j.started
+	}
+	if !j.finished.IsZero() {
+		status.FinishedAt = &j.finished
+	}
+
+	return status
+}
+
+// Frontier returns how many URLs are queued and up to n of them in the order
+// they'd be crawled. Queues that can't be listed only report the next one.
+func (j *Job) Frontier(n int) (int, []utils.URLInfo) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n = max(n, 0)
+	if j.queue == nil {
+		return 0, []utils.URLInfo{}
+	}
+	if lister, ok := j.queue.(interface{ Top(int) []utils.URLInfo }); ok {
+		return j.queue.Size(), lister.Top(n)
+	}
+
+	next, err := j.queue.Peek()
+	if err != nil || n < 1 {
+		return j.queue.Size(), []utils.URLInfo{}
+	}
+
+	return j.queue.Size(), []utils.URLInfo{{URL: next}}
+}
+
+// Results returns the most recent fetch results, newest first.
+func (j *Job) Results() []FetchResult {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	results := slices.Clone(j.recent)
+	slices.Reverse(results)
+
+	return results
+}
+
+func (j *Job) Pause() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.state != SeedRunning {
+		return fmt.Errorf("%w: can't pause a %s crawl", ErrJobState, j.state)
+	}
+	j.state = SeedPaused
+	j.resume = make(chan struct{})
+
+	return nil
+}
+
+func (j *Job) Resume() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.state != SeedPaused {
+		return fmt.Errorf("%w: can't resume a %s crawl", ErrJobState, j.state)
+	}
+	j.state = SeedRunning
+	close(j.resume)
+	j.resume = nil
+
+	return nil
+}
+
+// Cancel stops the crawl after the URL it's on. Pages already handed to the
+// batcher are still stored.
+func (j *Job) Cancel() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if !j.active() {
+		return fmt.Errorf("%w: can't cancel a %s crawl", ErrJobState, j.state)
+	}
+	j.cancel()
+
+	return nil
+}
+
+func (j *Job) active() bool {
+	return j.state == SeedQueued || j.state == SeedRunning || j.state == SeedPaused
+}
+
+// wait blocks while the job is paused, returning an error once it's been
+// cancelled.
+func (j *Job) wait() error {
+	j.mu.Lock()
+	resume := j.resume
+	j.mu.Unlock()
+
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-j.ctx.Done():
+		}
+	}
+
+	return j.ctx.Err()
+}
+
+// sleep waits out a crawl delay on clock, returning early when the job is
+// cancelled.
+func (j *Job) sleep(clock Clock, delay time.Duration) {
+	select {
+	case <-clock.After(delay):
+	case <-j.ctx.Done():
+	}
+}
+
+func (j *Job) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.state = SeedRunning
+	j.started = j.clock.Now()
+	j.counts.started = j.started
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.state = SeedCancelled
+	case err != nil:
+		j.state = SeedFailed
+		j.err = err
+	default:
+		j.state = SeedCompleted
+	}
+	if j.resume != nil {
+		close(j.resume)
+		j.resume = nil
+	}
+	j.finished = j.clock.Now()
+	j.cancel()
+}
+
+func (j *Job) setQueue(queue utils.QueueOps) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.queue = queue
+}
+
+// closeQueue drops the queue so nothing inspects it once it's closed. The
+// crawl only stops between URLs, so the last one dequeued is acked.
+func (j *Job) closeQueue() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	queue := j.queue
+	j.queue = nil
+
+	errs := []error{}
+	if j.current != "" {
+		errs = append(errs, utils.Ack(queue, j.current))
+		j.current = ""
+	}
+	if closer, ok := queue.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (j *Job) enqueue(info utils.URLInfo) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	utils.EnqueueInfo(j.queue, info)
+
+	return j.queue.Size()
+}
+
+// dequeue acks the URL dequeued before, which the crawl has finished with,
+// and pops the next, reporting false when the queue is empty.
+func (j *Job) dequeue() (utils.URLInfo, int, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.current != "" {
+		if err := utils.Ack(j.queue, j.current); err != nil {
+			return utils.URLInfo{}, 0, false, err
+		}
+		j.current = ""
+	}
+
+	if j.queue.Empty() {
+		return utils.URLInfo{}, 0, false, nil
+	}
+	info, err := utils.DequeueInfo(j.queue)
+	j.current = info.URL
+
+	return info, j.queue.Size(), true, err
+}
+
+func (j *Job) record(e URLEvent) {
+	result := FetchResult{
+		URL:         e.URL,
+		Event:       e.Event,
+		Status:      e.Status,
+		ContentType: e.ContentType,
+		Size:        e.Size,
+		DurationMs:  e.Duration.Milliseconds(),
+		At:          j.clock.Now(),
+	}
+	if e.Err != nil {
+		result.Error = e.Err.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.recent) == recentResults {
+		j.recent = slices.Delete(j.recent, 0, 1)
+	}
+	j.recent = append(j.recent, result)
+	j.events[e.Event]++
+}
+
+// Controller owns every crawl in a run, whether seeds came from links.txt or
+// were submitted to a running server.
+type Controller struct {
+	queries store.Store
+	cfg     Config
+	crawler *Crawler
+	run     *run
+	ctx     context.Context
+	cancel  context.CancelFunc
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	failed  atomic.Bool
+	// interrupted is set once any crawl is cancelled before it's done.
+	interrupted atomic.Bool
+
+	mu     sync.Mutex
+	jobs   map[int64]*Job
+	nextID int64
+}
+
+// NewController starts a crawl run that lasts until Close, crawling with
+// the dependencies opts inject.
+func NewController(queries store.Store, cfg Config, opts ...Option) (*Controller, error) {
+	crawler := NewCrawler(queries, cfg, opts...)
+	cfg = crawler.cfg
+
+	if err := loadFingerprints(queries, cfg); err != nil {
+		return nil, err
+	}
+
+	runID, err := queries.StartRun(context.TODO(), database.StartRunParams{
+		StartedAt:  crawler.clock.Now(),
+		ConfigHash: cfg.ConfigHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Controller{
+		queries: queries,
+		cfg:     cfg,
+		crawler: crawler,
+		run: &run{
+			id:       runID,
+			stats:    &Stats{},
+			batcher:  NewBatcher(queries, cfg.BatchSize, cfg.BatchInterval, crawler.clock, cfg.Metrics, cfg.Logger),
+			attempts: NewAttemptLog(queries, cfg.BatchSize, cfg.BatchInterval, crawler.clock, cfg.Logger),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		slots:  make(chan struct{}, maxCrawlers),
+		jobs:   map[int64]*Job{},
+	}, nil
+}
+
+// Submit queues a seed to be crawled. A seed can't be submitted again while
+// an earlier crawl of it is still active.
+func (c *Controller) Submit(seed utils.Seed) (*Job, error) {
+	parsed, err := url.Parse(seed.URL)
+	if err != nil {
+		return nil, err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("seed isn't an http url: %s", seed.URL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, job := range c.jobs {
+		job.mu.Lock()
+		active := job.active()
+		job.mu.Unlock()
+		if job.Seed.URL == seed.URL && active {
+			return nil, fmt.Errorf("%w: %s is already being crawled", ErrJobState, seed.URL)
+		}
+	}
+
+	c.nextID++
+	ctx, cancel := context.WithCancel(c.ctx)
+	job := &Job{
+		ID:     c.nextID,
+		Seed:   seed,
+		ctx:    ctx,
+		cancel: cancel,
+		clock:  c.crawler.clock,
+		counts: &seedStats{},
+		state:  SeedQueued,
+		events: map[Event]int64{},
+	}
+	c.jobs[job.ID] = job
+
+	c.wg.Add(1)
+	go c.crawl(job)
+
+	return job, nil
+}
+
+func (c *Controller) crawl(job *Job) {
+	defer c.wg.Done()
+
+	select {
+	case c.slots <- struct{}{}:
+	case <-job.ctx.Done():
+		c.interrupted.Store(true)
+		job.finish(job.ctx.Err())
+		return
+	}
+	defer func() {
+		<-c.slots
+	}()
+
+	job.start()
+	err := c.crawler.crawl(job, c.run)
+	switch {
+	case errors.Is(err, context.Canceled):
+		c.interrupted.Store(true)
+	case err != nil:
+		c.failed.Store(true)
+		c.cfg.Logger.Error("crawl failed", slog.String("seed", job.Seed.URL), slog.String("error", err.Error()))
+	}
+	job.finish(err)
+}
+
+// Jobs returns every job submitted in this run, oldest first.
+func (c *Controller) Jobs() []*Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	jobs := []*Job{}
+	for _, id := range slices.Sorted(maps.Keys(c.jobs)) {
+		jobs = append(jobs, c.jobs[id])
+	}
+
+	return jobs
+}
+
+func (c *Controller) Job(id int64) (*Job, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	job, ok := c.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return job, nil
+}
+
+// Wait blocks until every submitted job has finished.
+func (c *Controller) Wait() {
+	c.wg.Wait()
+}
+
+// Close cancels whatever is still crawling, stores what's been fetched and
+// finishes the run, marking it failed if any seed's crawl errored, or
+// interrupted if any was cancelled before it was done.
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	c.cancel()
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	c.run.batcher.Close()
+	c.run.attempts.Close()
+
+	c.cfg.Logger.Info(c.run.stats.String())
+
+	status := RunCompleted
+	switch {
+	case c.failed.Load():
+		status = RunFailed
+	case c.interrupted.Load():
+		status = RunInterrupted
+	}
+
+	return c.queries.FinishRun(context.TODO(), database.FinishRunParams{
+		FinishedAt: sql.NullTime{Time: c.crawler.clock.Now(), Valid: true},
+		Status:     status,
+		ID:         c.run.id,
+	})
+}
@@ -0,0 +1,589 @@
+package src
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+// DefaultLeaseTTL is how long a worker holds leased URLs without renewing
+// them before they're handed out again.
+const DefaultLeaseTTL = 30 * time.Second
+
+// ringReplicas is how many points each worker takes on the hash ring.
+const ringReplicas = 64
+
+var (
+	ErrLeaseExpired = errors.New("lease expired")
+	ErrLeaseSize    = errors.New("lease size must be positive")
+)
+
+type LeaseRequest struct {
+	Worker string `json:"worker"`
+	Max    int    `json:"max"`
+}
+
+type LeasedURL struct {
+	URL   string      `json:"url"`
+	Depth int         `json:"depth"`
+	Seed  SeedRequest `json:"seed"`
+}
+
+// Lease is a batch of URLs a worker has until it completes them or stops
+// renewing. A lease without an ID means there's nothing for the worker yet,
+// and Done that the crawl is over.
+type Lease struct {
+	ID    string      `json:"id,omitempty"`
+	RunID int64       `json:"run_id"`
+	URLs  []LeasedURL `json:"urls"`
+	TTLMs int64       `json:"ttl_ms"`
+	Done  bool        `json:"done,omitempty"`
+}
+
+type RenewRequest struct {
+	Worker string `json:"worker"`
+	Lease  string `json:"lease"`
+}
+
+// Discovered is a link a worker found, from a page or a sitemap.
+type Discovered struct {
+	URL             string    `json:"url"`
+	Depth           int       `json:"depth"`
+	SitemapPriority float64   `json:"sitemap_priority,omitempty"`
+	LastModified    time.Time `json:"last_modified,omitzero"`
+}
+
+// LeaseResult is what became of one leased URL.
+type LeaseResult struct {
+	URL     string       `json:"url"`
+	Event   Event        `json:"event"`
+	Fetched bool         `json:"fetched,omitempty"`
+	Bytes   int64        `json:"bytes,omitempty"`
+	Links   []Discovered `json:"links,omitempty"`
+}
+
+type CompleteRequest struct {
+	Worker  string        `json:"worker"`
+	Lease   string        `json:"lease"`
+	Results []LeaseResult `json:"results"`
+}
+
+type CoordinatorStatus struct {
+	RunID   int64          `json:"run_id"`
+	Done    bool           `json:"done"`
+	Workers []WorkerStatus `json:"workers"`
+	Hosts   []HostStatus   `json:"hosts"`
+}
+
+type WorkerStatus struct {
+	ID       string    `json:"id"`
+	LastSeen time.Time `json:"last_seen"`
+	Leases   int       `json:"leases"`
+}
+
+type HostStatus struct {
+	Host    string `json:"host"`
+	Seed    string `json:"seed"`
+	Owner   string `json:"owner,omitempty"`
+	Queued  int    `json:"queued"`
+	Leased  int    `json:"leased"`
+	Fetched int64  `json:"fetched"`
+	Stored  int64  `json:"stored"`
+	Failed  int64  `json:"failed"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// hostFrontier is the shared frontier for one seed's host. While any of its
+// URLs are leased, holder is the worker leasing them, which keeps the host
+// until they're released even if the ring moves it, so two workers never
+// crawl it at once.
+type hostFrontier struct {
+	seed   utils.Seed
+	queue  utils.QueueOps
+	counts *seedStats
+	leased int
+	holder string
+}
+
+type lease struct {
+	worker  string
+	urls    []LeasedURL
+	expires time.Time
+}
+
+// Coordinator shards the hosts of every seed across workers by consistent
+// hashing and leases each worker batches of URLs from its hosts. URLs whose
+// lease isn't renewed go back on the frontier, and workers that stop asking
+// for work are dropped from the ring so their hosts move to the others.
+type Coordinator struct {
+	queries store.Store
+	cfg     Config
+	clock   Clock
+	ttl     time.Duration
+	runID   int64
+	done    chan struct{}
+
+	mu        sync.Mutex
+	hosts     map[string]*hostFrontier
+	leases    map[string]*lease
+	workers   map[string]time.Time
+	ring      *utils.HashRing
+	nextLease int64
+	finished  bool
+}
+
+// NewCoordinator starts a crawl run over seeds, one frontier per host. Seeds
+// sharing a host are crawled as the first of them. Of opts, only the clock
+// and logger apply, since workers do the fetching.
+func NewCoordinator(queries store.Store, cfg Config, seeds []utils.Seed, ttl time.Duration, opts ...Option) (*Coordinator, error) {
+	deps := NewCrawler(queries, cfg, opts...)
+	cfg = deps.cfg
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+
+	runID, err := queries.StartRun(context.TODO(), database.StartRunParams{
+		StartedAt:  deps.clock.Now(),
+		ConfigHash: cfg.ConfigHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Coordinator{
+		queries: queries,
+		cfg:     cfg,
+		clock:   deps.clock,
+		ttl:     ttl,
+		runID:   runID,
+		done:    make(chan struct{}),
+		hosts:   map[string]*hostFrontier{},
+		leases:  map[string]*lease{},
+		workers: map[string]time.Time{},
+		ring:    utils.NewHashRing(ringReplicas),
+	}
+
+	for _, seed := range seeds {
+		dom, err := url.Parse(seed.URL)
+		if err != nil {
+			return nil, errors.Join(err, c.Close())
+		}
+		if _, ok := c.hosts[dom.Hostname()]; ok {
+			cfg.Logger.Warn("skipping seed on a host already being crawled", slog.String("seed", seed.URL))
+			continue
+		}
+
+		var queue utils.QueueOps = utils.NewFrontier(cfg.Scorers...)
+		if cfg.NewQueue != nil {
+			if queue, err = cfg.NewQueue(seed); err != nil {
+				return nil, errors.Join(err, c.Close())
+			}
+		}
+		c.hosts[dom.Hostname()] = &hostFrontier{
+			seed:   seed,
+			queue:  queue,
+			counts: &seedStats{started: c.clock.Now()},
+		}
+		c.add(utils.URLInfo{URL: seed.URL}, true)
+	}
+	c.checkDone()
+
+	return c, nil
+}
+
+// add queues a URL on its host's frontier unless it's out of scope or
+// already visited. A recrawled URL, which seeds are, is queued even when an
+// earlier run's persisted visited set has it, so a rerun finds what's new.
+// The caller holds c.mu.
+func (c *Coordinator) add(info utils.URLInfo, recrawl bool) {
+	parsed, err := url.Parse(info.URL)
+	if err != nil {
+		return
+	}
+	host, ok := c.hosts[parsed.Hostname()]
+	if !ok {
+		return
+	}
+
+	normURL, err := utils.Normalize(info.URL)
+	if err != nil || (!c.cfg.Visited.Visit(normURL) && !recrawl) {
+		return
+	}
+
+	utils.EnqueueInfo(host.queue, info)
+	c.cfg.Metrics.QueueDepth.WithLabelValues(host.seed.URL).Set(float64(host.queue.Size()))
+}
+
+// checkDone finishes the crawl once nothing is queued or leased. The caller
+// holds c.mu.
+func (c *Coordinator) checkDone() {
+	if c.finished || len(c.leases) > 0 {
+		return
+	}
+	for _, host := range c.hosts {
+		if !host.queue.Empty() {
+			return
+		}
+	}
+
+	c.finished = true
+	close(c.done)
+}
+
+// Done is closed once every URL has been crawled.
+func (c *Coordinator) Done() <-chan struct{} {
+	return c.done
+}
+
+// seen records that a worker is alive, adding it to the ring if it's new.
+// The caller holds c.mu.
+func (c *Coordinator) seen(worker string) {
+	if _, ok := c.workers[worker]; !ok {
+		c.ring.Add(worker)
+		c.cfg.Logger.Info("worker joined", slog.String("worker", worker))
+	}
+	c.workers[worker] = c.clock.Now()
+}
+
+// ownerOf returns the worker a host's URLs are leased to: whoever holds its
+// outstanding URLs, or else its owner on the ring. The caller holds c.mu.
+func (c *Coordinator) ownerOf(name string) string {
+	if holder := c.hosts[name].holder; holder != "" {
+		return holder
+	}
+	owner, _ := c.ring.Get(name)
+
+	return owner
+}
+
+// Lease hands a worker up to max URLs from the hosts it owns.
+func (c *Coordinator) Lease(req LeaseRequest) (Lease, error) {
+	if req.Max <= 0 {
+		return Lease{}, ErrLeaseSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen(req.Worker)
+
+	granted := Lease{RunID: c.runID, URLs: []LeasedURL{}, TTLMs: c.ttl.Milliseconds(), Done: c.finished}
+	if c.finished {
+		return granted, nil
+	}
+
+	owned := []*hostFrontier{}
+	for _, name := range slices.Sorted(maps.Keys(c.hosts)) {
+		if c.ownerOf(name) == req.Worker {
+			owned = append(owned, c.hosts[name])
+		}
+	}
+
+	// URLs are taken from each host in turn so a lease spreads across hosts,
+	// which the worker crawls in parallel.
+	for len(granted.URLs) < req.Max {
+		taken := false
+		for _, host := range owned {
+			if len(granted.URLs) == req.Max || host.queue.Empty() {
+				continue
+			}
+			info, err := utils.DequeueInfo(host.queue)
+			if err != nil {
+				continue
+			}
+			granted.URLs = append(granted.URLs, LeasedURL{URL: info.URL, Depth: info.Depth, Seed: seedRequest(host.seed)})
+			host.leased++
+			host.holder = req.Worker
+			taken = true
+		}
+		if !taken {
+			break
+		}
+	}
+	for _, host := range owned {
+		c.cfg.Metrics.QueueDepth.WithLabelValues(host.seed.URL).Set(float64(host.queue.Size()))
+	}
+	if len(granted.URLs) == 0 {
+		return granted, nil
+	}
+
+	c.nextLease++
+	granted.ID = fmt.Sprintf("%d-%d", c.runID, c.nextLease)
+	c.leases[granted.ID] = &lease{worker: req.Worker, urls: granted.URLs, expires: c.clock.Now().Add(c.ttl)}
+
+	return granted, nil
+}
+
+func (c *Coordinator) Renew(req RenewRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen(req.Worker)
+
+	held, ok := c.leases[req.Lease]
+	if !ok || held.worker != req.Worker {
+		return ErrLeaseExpired
+	}
+	held.expires = c.clock.Now().Add(c.ttl)
+
+	return nil
+}
+
+// Complete takes a lease's results and queues the links found. Leased URLs
+// without a result, left when a worker stops partway, are queued again.
+// Results for a lease that already expired are still counted, since its
+// pages were stored, though its URLs will have been leased again.
+func (c *Coordinator) Complete(req CompleteRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen(req.Worker)
+
+	if held, ok := c.leases[req.Lease]; ok && held.worker == req.Worker {
+		c.release(held)
+		delete(c.leases, req.Lease)
+
+		reported := map[string]bool{}
+		for _, result := range req.Results {
+			reported[result.URL] = true
+		}
+		c.requeue(held, func(leased LeasedURL) bool { return !reported[leased.URL] })
+		c.ack(held, reported)
+	}
+
+	for _, result := range req.Results {
+		if host := c.hostOf(result.URL); host != nil {
+			host.counts.record(result.Event)
+			host.counts.bytes.Add(result.Bytes)
+			if result.Fetched {
+				host.counts.fetched.Add(1)
+			}
+		}
+		for _, link := range result.Links {
+			c.add(utils.URLInfo{URL: link.URL, Depth: link.Depth, SitemapPriority: link.SitemapPriority, LastModified: link.LastModified}, false)
+		}
+	}
+
+	c.checkDone()
+}
+
+// release stops counting a lease's URLs as leased, unpinning hosts with
+// none left. The caller holds c.mu.
+func (c *Coordinator) release(held *lease) {
+	for _, leased := range held.urls {
+		if host := c.hostOf(leased.URL); host != nil {
+			if host.leased--; host.leased == 0 {
+				host.holder = ""
+			}
+		}
+	}
+}
+
+// requeue puts a lease's URLs matching keep back on their frontiers,
+// bypassing the visited check that already let them through once. The
+// caller holds c.mu.
+func (c *Coordinator) requeue(held *lease, keep func(LeasedURL) bool) {
+	for _, leased := range held.urls {
+		if host := c.hostOf(leased.URL); host != nil && keep(leased) {
+			utils.EnqueueInfo(host.queue, utils.URLInfo{URL: leased.URL, Depth: leased.Depth})
+		}
+	}
+}
+
+// ack acknowledges a lease's reported URLs on the frontiers that wait for
+// it. The caller holds c.mu.
+func (c *Coordinator) ack(held *lease, reported map[string]bool) {
+	for _, leased := range held.urls {
+		host := c.hostOf(leased.URL)
+		if host == nil || !reported[leased.URL] {
+			continue
+		}
+		if err := utils.Ack(host.queue, leased.URL); err != nil {
+			c.cfg.Logger.Error("couldn't ack url", slog.String("url", leased.URL), slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (c *Coordinator) hostOf(rawURL string) *hostFrontier {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	return c.hosts[parsed.Hostname()]
+}
+
+// reap puts the URLs of expired leases back on the frontier and drops
+// workers that haven't been heard from within a lease's TTL.
+func (c *Coordinator) reap() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	for id, held := range c.leases {
+		if now.Before(held.expires) {
+			continue
+		}
+
+		c.release(held)
+		delete(c.leases, id)
+		c.requeue(held, func(LeasedURL) bool { return true })
+		c.cfg.Logger.Warn("lease expired", slog.String("lease", id), slog.String("worker", held.worker), slog.Int("urls", len(held.urls)))
+	}
+
+	for worker, last := range c.workers {
+		if now.Sub(last) < c.ttl {
+			continue
+		}
+
+		delete(c.workers, worker)
+		c.ring.Remove(worker)
+		c.cfg.Logger.Warn("worker lost", slog.String("worker", worker))
+	}
+
+	c.checkDone()
+}
+
+// Run reaps expired leases until the crawl is done or ctx is cancelled.
+func (c *Coordinator) Run(ctx context.Context) {
+	for {
+		select {
+		case <-c.clock.After(c.ttl / 4):
+			c.reap()
+		case <-c.done:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Coordinator) Status() CoordinatorStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := CoordinatorStatus{RunID: c.runID, Done: c.finished, Workers: []WorkerStatus{}, Hosts: []HostStatus{}}
+
+	leases := map[string]int{}
+	for _, held := range c.leases {
+		leases[held.worker]++
+	}
+	for _, worker := range slices.Sorted(maps.Keys(c.workers)) {
+		status.Workers = append(status.Workers, WorkerStatus{ID: worker, LastSeen: c.workers[worker], Leases: leases[worker]})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.hosts)) {
+		host := c.hosts[name]
+		owner := c.ownerOf(name)
+		status.Hosts = append(status.Hosts, HostStatus{
+			Host:    name,
+			Seed:    host.seed.URL,
+			Owner:   owner,
+			Queued:  host.queue.Size(),
+			Leased:  host.leased,
+			Fetched: host.counts.fetched.Load(),
+			Stored:  host.counts.stored.Load(),
+			Failed:  host.counts.failed.Load(),
+			Bytes:   host.counts.bytes.Load(),
+		})
+	}
+
+	return status
+}
+
+// Close saves every seed's stats and finishes the run, as interrupted if the
+// crawl was stopped before it was done.
+func (c *Coordinator) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := []error{}
+	for _, host := range c.hosts {
+		errs = append(errs, host.counts.save(c.queries, c.runID, host.seed.URL, c.clock.Now()))
+		if closer, ok := host.queue.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	status := RunCompleted
+	if !c.finished {
+		status = RunInterrupted
+	}
+	errs = append(errs, c.queries.FinishRun(context.TODO(), database.FinishRunParams{
+		FinishedAt: sql.NullTime{Time: c.clock.Now(), Valid: true},
+		Status:     status,
+		ID:         c.runID,
+	}))
+
+	return errors.Join(errs...)
+}
+
+// Handler serves the protocol workers speak, plus GET /status.
+func (c *Coordinator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /lease", func(w http.ResponseWriter, r *http.Request) {
+		req := LeaseRequest{}
+		if !decode(w, r, &req) {
+			return
+		}
+		granted, err := c.Lease(req)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, granted)
+	})
+	mux.HandleFunc("POST /renew", func(w http.ResponseWriter, r *http.Request) {
+		req := RenewRequest{}
+		if !decode(w, r, &req) {
+			return
+		}
+		if err := c.Renew(req); err != nil {
+			writeJSON(w, http.StatusGone, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{})
+	})
+	mux.HandleFunc("POST /complete", func(w http.ResponseWriter, r *http.Request) {
+		req := CompleteRequest{}
+		if !decode(w, r, &req) {
+			return
+		}
+		c.Complete(req)
+		writeJSON(w, http.StatusOK, map[string]string{})
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.Status())
+	})
+
+	return mux
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,297 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/fakeweb"
+	"github.com/junwei890/crawler/utils"
+)
+
+// coordinate serves a coordinator over seeds on localhost, closed when the
+// test ends.
+func coordinate(t *testing.T, seeds []utils.Seed, ttl time.Duration, opts ...Option) (*Coordinator, *httptest.Server) {
+	t.Helper()
+
+	coordinator, err := NewCoordinator(memoryStore(t), testConfig(), seeds, ttl, opts...)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	server := httptest.NewServer(coordinator.Handler())
+	t.Cleanup(server.Close)
+
+	return coordinator, server
+}
+
+// leaseURLs asks the coordinator for up to size URLs as w, returning the
+// URLs leased.
+func leaseURLs(t *testing.T, w *Worker, size int) (Lease, []string) {
+	t.Helper()
+
+	granted := Lease{}
+	if err := w.post(context.Background(), "/lease", LeaseRequest{Worker: w.id, Max: size}, &granted); err != nil {
+		t.Fatalf("error leasing urls, unexpected error: %v", err)
+	}
+
+	urls := []string{}
+	for _, leased := range granted.URLs {
+		urls = append(urls, leased.URL)
+	}
+	slices.Sort(urls)
+
+	return granted, urls
+}
+
+// completeLease reports every URL of a lease as stored, along with links
+// found on the first.
+func completeLease(t *testing.T, w *Worker, granted Lease, links ...string) {
+	t.Helper()
+
+	results := []LeaseResult{}
+	for _, leased := range granted.URLs {
+		results = append(results, LeaseResult{URL: leased.URL, Event: EventStored, Fetched: true})
+	}
+	for _, link := range links {
+		results[0].Links = append(results[0].Links, Discovered{URL: link, Depth: 1})
+	}
+	if err := w.post(context.Background(), "/complete", CompleteRequest{Worker: w.id, Lease: granted.ID, Results: results}, nil); err != nil {
+		t.Fatalf("error completing lease, unexpected error: %v", err)
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	sites := []*fakeweb.Server{}
+	seeds := []utils.Seed{}
+	for i := range 3 {
+		site, err := fakeweb.NewAt(fmt.Sprintf("127.0.0.%d", i+2), fakeweb.Site{Pages: fakeweb.Tree(1, 3)})
+		if err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		defer site.Close()
+		sites = append(sites, site)
+		seeds = append(seeds, utils.Seed{URL: site.Seed()})
+	}
+
+	coordinator, server := coordinate(t, seeds, 1500*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go coordinator.Run(ctx)
+
+	// A worker that leases every seed and then dies: its lease has to run
+	// out and its hosts move to the others before anything is crawled.
+	ghost := NewWorker("ghost", server.URL, coordinator.queries, testConfig())
+	if _, urls := leaseURLs(t, ghost, 10); len(urls) != len(seeds) {
+		t.Fatalf("F35: test case 1 failed, %v != %v", len(urls), len(seeds))
+	}
+
+	errs := make(chan error, 2)
+	for _, id := range []string{"a", "b"} {
+		worker := NewWorker(id, server.URL, coordinator.queries, testConfig())
+		go func() {
+			errs <- worker.Run(ctx, 2)
+		}()
+	}
+
+	select {
+	case <-coordinator.Done():
+	case <-time.After(30 * time.Second):
+		t.Fatalf("F35: test case 2 failed, crawl didn't finish: %+v", coordinator.Status())
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("F35: test case 2 failed, unexpected error: %v", err)
+		}
+	}
+
+	pages := slices.Sorted(maps.Keys(fakeweb.Tree(1, 3)))
+	expected := []string{}
+	for _, site := range sites {
+		for _, path := range pages {
+			expected = append(expected, site.Page(path))
+			if hits := site.Hits(path); hits != 1 {
+				t.Errorf("F35: test case 3 failed, %s fetched %v times", site.Page(path), hits)
+			}
+		}
+	}
+	slices.Sort(expected)
+	if urls := stored(t, coordinator.queries); !slices.Equal(urls, expected) {
+		t.Errorf("F35: test case 4 failed, %v != %v", urls, expected)
+	}
+
+	status := coordinator.Status()
+	for _, worker := range status.Workers {
+		if worker.ID == "ghost" {
+			t.Errorf("F35: test case 5 failed, lost worker still listed in %+v", status.Workers)
+		}
+	}
+	for _, host := range status.Hosts {
+		if host.Queued != 0 || host.Leased != 0 || host.Stored != 4 {
+			t.Errorf("F35: test case 6 failed, unexpected host %+v", host)
+		}
+	}
+	if err := coordinator.Close(); err != nil {
+		t.Errorf("F35: test case 7 failed, unexpected error: %v", err)
+	}
+}
+
+func TestCoordinatorLeases(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	coordinator, server := coordinate(t, []utils.Seed{{URL: "http://one.test/"}}, time.Minute, WithClock(clock))
+	a := NewWorker("a", server.URL, coordinator.queries, testConfig())
+	b := NewWorker("b", server.URL, coordinator.queries, testConfig())
+
+	if err := a.post(context.Background(), "/lease", LeaseRequest{Worker: "a"}, &Lease{}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("F36: test case 1 failed, %v != %v", err, "a 400")
+	}
+
+	held, urls := leaseURLs(t, a, 5)
+	if !slices.Equal(urls, []string{"http://one.test/"}) {
+		t.Errorf("F36: test case 2 failed, %v != %v", urls, []string{"http://one.test/"})
+	}
+
+	// Renewing keeps the lease past its first TTL.
+	clock.advance(30 * time.Second)
+	if err := a.post(context.Background(), "/renew", RenewRequest{Worker: "a", Lease: held.ID}, nil); err != nil {
+		t.Fatalf("F36: test case 3 failed, unexpected error: %v", err)
+	}
+	clock.advance(45 * time.Second)
+	coordinator.reap()
+	if status := coordinator.Status(); status.Hosts[0].Leased != 1 || len(status.Workers) != 1 {
+		t.Errorf("F36: test case 3 failed, unexpected status %+v", status)
+	}
+
+	// Once a stops renewing, its URLs go back on the frontier, it leaves
+	// the ring and b is leased them.
+	clock.advance(30 * time.Second)
+	coordinator.reap()
+	status := coordinator.Status()
+	if status.Hosts[0].Queued != 1 || status.Hosts[0].Leased != 0 || len(status.Workers) != 0 {
+		t.Errorf("F36: test case 4 failed, unexpected status %+v", status)
+	}
+	reassigned, urls := leaseURLs(t, b, 5)
+	if !slices.Equal(urls, []string{"http://one.test/"}) {
+		t.Errorf("F36: test case 5 failed, %v != %v", urls, []string{"http://one.test/"})
+	}
+	if err := a.post(context.Background(), "/renew", RenewRequest{Worker: "a", Lease: held.ID}, nil); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("F36: test case 6 failed, %v != %v", err, ErrLeaseExpired)
+	}
+
+	// a's late results still count and its links are queued, but the host
+	// stays with b while b holds a lease on it, wherever the ring puts it.
+	completeLease(t, a, held, "http://one.test/x")
+	if _, urls := leaseURLs(t, a, 5); len(urls) != 0 {
+		t.Errorf("F36: test case 7 failed, %v leased to a while b holds the host", urls)
+	}
+	completeLease(t, b, reassigned)
+	if status := coordinator.Status(); status.Hosts[0].Stored != 2 || status.Hosts[0].Queued != 1 {
+		t.Errorf("F36: test case 8 failed, unexpected status %+v", status)
+	}
+}
+
+func TestCoordinatorRing(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	coordinator, server := coordinate(t, []utils.Seed{{URL: "http://one.test/"}}, time.Minute, WithClock(clock))
+	a := NewWorker("a", server.URL, coordinator.queries, testConfig())
+
+	// Find a worker the host moves to when it joins the ring.
+	id := ""
+	for i := 0; id == ""; i++ {
+		ring := utils.NewHashRing(ringReplicas)
+		ring.Add("a")
+		ring.Add(fmt.Sprintf("c%d", i))
+		if owner, _ := ring.Get("one.test"); owner != "a" {
+			id = owner
+		}
+	}
+	c := NewWorker(id, server.URL, coordinator.queries, testConfig())
+
+	root, _ := leaseURLs(t, a, 1)
+	completeLease(t, a, root, "http://one.test/x", "http://one.test/y")
+	held, urls := leaseURLs(t, a, 1)
+	if len(urls) != 1 {
+		t.Fatalf("F37: test case 1 failed, %v != %v", len(urls), 1)
+	}
+
+	// c takes the host on the ring, but a keeps it until its lease is done
+	// so the two never crawl it at once.
+	if granted, urls := leaseURLs(t, c, 5); granted.ID != "" || len(urls) != 0 {
+		t.Errorf("F37: test case 2 failed, %v leased to %s while a holds the host", urls, id)
+	}
+	if owner := coordinator.Status().Hosts[0].Owner; owner != "a" {
+		t.Errorf("F37: test case 3 failed, %v != %v", owner, "a")
+	}
+
+	completeLease(t, a, held)
+	if _, urls := leaseURLs(t, a, 5); len(urls) != 0 {
+		t.Errorf("F37: test case 4 failed, %v leased to a after the host moved", urls)
+	}
+	if owner := coordinator.Status().Hosts[0].Owner; owner != id {
+		t.Errorf("F37: test case 5 failed, %v != %v", owner, id)
+	}
+	moved, urls := leaseURLs(t, c, 5)
+	if len(urls) != 1 {
+		t.Fatalf("F37: test case 6 failed, %v != %v", len(urls), 1)
+	}
+	completeLease(t, c, moved)
+
+	select {
+	case <-coordinator.Done():
+	default:
+		t.Errorf("F37: test case 7 failed, crawl isn't done: %+v", coordinator.Status())
+	}
+	if granted, _ := leaseURLs(t, c, 5); !granted.Done {
+		t.Errorf("F37: test case 7 failed, %v != %v", granted.Done, true)
+	}
+}
+
+func TestWorkerLeaseExpired(t *testing.T) {
+	hits := map[string]*atomic.Int64{"/renew": {}, "/complete": {}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits[r.URL.Path].Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	worker := NewWorker("a", server.URL, memoryStore(t), testConfig(), WithClock(clock))
+	lease := Lease{ID: "lease", TTLMs: 3000}
+
+	// Completing an expired lease gives up straight away.
+	if err := worker.complete(lease, []LeaseResult{}); !errors.Is(err, ErrLeaseExpired) {
+		t.Errorf("F51: test case 1 failed, %v != %v", err, ErrLeaseExpired)
+	}
+	if completes := hits["/complete"].Load(); completes != 1 || clock.slept != 0 {
+		t.Errorf("F51: test case 2 failed, %d attempts after waiting %v != 1 after 0s", completes, clock.slept)
+	}
+
+	// Renewing stops once the coordinator says the lease is gone, without
+	// waiting for the crawl to finish.
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		worker.renew(context.Background(), lease)
+	}()
+	eventually(t, "F51: test case 3", func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.tickers) == 1
+	})
+	clock.advance(time.Second)
+	select {
+	case <-renewed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("F51: test case 3 failed, still renewing an expired lease")
+	}
+	if renews := hits["/renew"].Load(); renews != 1 || len(clock.tickers) != 0 {
+		t.Errorf("F51: test case 4 failed, %d renewals with %d tickers left != 1 with 0", renews, len(clock.tickers))
+	}
+}
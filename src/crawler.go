@@ -2,7 +2,10 @@ package src
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/url"
 	"os"
 	"strings"
@@ -10,45 +13,139 @@ import (
 	"time"
 
 	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
 	"github.com/junwei890/crawler/utils"
 )
 
-func Init(queries *database.Queries) error {
-	file, err := os.ReadFile("links.txt")
+type Config struct {
+	Pipeline       []utils.TextStage
+	Duplicates     *utils.SimIndex
+	SkipDuplicates bool
+	FeedInterval   time.Duration
+	Scorers        []utils.Scorer
+	NewQueue       func(seed utils.Seed) (utils.QueueOps, error)
+	Visited        utils.Visited
+	Logger         Logger
+	Metrics        *Metrics
+	ConfigHash     string
+	BatchSize      int
+	BatchInterval  time.Duration
+	Archive        *utils.WARCWriter
+	Blobs          *utils.BlobDir
+}
+
+// run is what every seed's crawler shares within one Init.
+type run struct {
+	id       int64
+	stats    *Stats
+	batcher  *Batcher
+	attempts *AttemptLog
+}
+
+const feedBoost = 100
+
+func (cfg *Config) setDefaults() {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = NewMetrics(nil)
+	}
+	if cfg.Visited == nil {
+		cfg.Visited = utils.NewSeenSet(1<<16, 0.01, utils.NewMemoryStore())
+	}
+	if cfg.Duplicates == nil {
+		cfg.Duplicates = utils.NewSimIndex(utils.DefaultSimDistance)
+	}
+	if cfg.BatchInterval <= 0 {
+		cfg.BatchInterval = 2 * time.Second
+	}
+}
+
+// loadFingerprints seeds the duplicate index with every stored original.
+func loadFingerprints(queries store.Store, cfg Config) error {
+	fingerprints, err := queries.ListFingerprints(context.TODO())
 	if err != nil {
 		return err
 	}
-	links := strings.Fields(string(file))
-
-	wg := &sync.WaitGroup{}
-	channel := make(chan struct{}, 1000)
-
-	for _, link := range links {
-		wg.Add(1)
-		channel <- struct{}{}
-		go func() {
-			defer func() {
-				<-channel
-				wg.Done()
-			}()
-			if err := crawler(link, queries); err != nil {
-				log.Println(err)
-				return
-			}
-		}()
+	for _, fingerprint := range fingerprints {
+		cfg.Duplicates.Add(fingerprint.Url, uint64(fingerprint.Simhash))
 	}
-	wg.Wait()
 
 	return nil
 }
 
-func crawler(startURL string, queries *database.Queries) error {
-	file, err := utils.GetRobots(startURL)
+// Init crawls every seed in links.txt as one run, stopping early once ctx
+// is done, which is the only way a feeds-only seed with feeds finishes.
+func Init(ctx context.Context, queries store.Store, cfg Config, opts ...Option) error {
+	cfg.setDefaults()
+
+	file, err := os.ReadFile("links.txt")
+	if err != nil {
+		return err
+	}
+	seeds, err := utils.ParseSeeds(file)
 	if err != nil {
 		return err
 	}
 
-	normURL, err := utils.Normalize(startURL)
+	controller, err := NewController(queries, cfg, opts...)
+	if err != nil {
+		return err
+	}
+
+	for _, seed := range seeds {
+		_, err := controller.Submit(seed)
+		if errors.Is(err, ErrJobState) {
+			cfg.Logger.Warn("skipping repeated seed", slog.String("seed", seed.URL))
+			continue
+		}
+		if err != nil {
+			controller.failed.Store(true)
+			cfg.Logger.Error("crawl failed", slog.String("seed", seed.URL), slog.String("error", err.Error()))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		controller.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	return controller.Close()
+}
+
+func (c *Crawler) crawl(job *Job, r *run) error {
+	queries, cfg := c.queries, c.cfg
+	seed := job.Seed
+	logger := withAttrs(cfg.Logger, slog.String("seed", seed.URL))
+
+	counts := job.counts
+	defer func() {
+		if err := counts.save(queries, r.id, seed.URL, c.clock.Now()); err != nil {
+			logger.Error("couldn't save seed stats", slog.String("error", err.Error()))
+		}
+	}()
+
+	// Seed stats are saved once the batcher has reported on every page this
+	// crawler handed it, the last of them flushed rather than left for the
+	// next tick.
+	writes := &sync.WaitGroup{}
+	defer func() {
+		r.batcher.Flush()
+		writes.Wait()
+	}()
+
+	file, err := c.robots.Robots(seed.URL)
+	if err != nil {
+		return err
+	}
+
+	normURL, err := utils.Normalize(seed.URL)
 	if err != nil {
 		return err
 	}
@@ -58,73 +155,259 @@ func crawler(startURL string, queries *database.Queries) error {
 		return err
 	}
 
-	dom, err := url.Parse(startURL)
+	dom, err := url.Parse(seed.URL)
 	if err != nil {
 		return err
 	}
 
-	visited := map[string]struct{}{}
-	queue := &utils.Queue{}
-	queue.Enqueue(startURL)
+	var queue utils.QueueOps = utils.NewFrontier(cfg.Scorers...)
+	if cfg.NewQueue != nil {
+		queue, err = cfg.NewQueue(seed)
+		if err != nil {
+			return err
+		}
+	}
+	job.setQueue(queue)
+	defer job.closeQueue()
 
-	for {
-		if comp := queue.CheckEmpty(); comp {
-			break
+	depth := cfg.Metrics.QueueDepth.WithLabelValues(seed.URL)
+	defer cfg.Metrics.QueueDepth.DeleteLabelValues(seed.URL)
+
+	enqueue := func(info utils.URLInfo) {
+		depth.Set(float64(job.enqueue(info)))
+		cfg.Metrics.Events.WithLabelValues(string(EventEnqueued)).Inc()
+		logEvent(cfg.Logger, URLEvent{Event: EventEnqueued, Seed: seed.URL, URL: info.URL, Attrs: []slog.Attr{slog.Int("depth", info.Depth)}})
+	}
+
+	// The seed and feed entries are crawled even when an earlier run's
+	// persisted visited set has them, since that's how a rerun finds what's
+	// new. They're still marked visited, so this run fetches them once.
+	recrawl := map[string]bool{}
+	revisit := func(info utils.URLInfo) {
+		if normURL, err := utils.Normalize(info.URL); err == nil {
+			recrawl[normURL] = true
 		}
+		enqueue(info)
+	}
 
-		popped, err := queue.Dequeue()
-		if err != nil {
+	revisit(utils.URLInfo{URL: seed.URL})
+
+	if !seed.FeedsOnly {
+		for _, info := range loadSitemaps(c.plain, rules.Sitemaps, logger) {
+			enqueue(info)
+		}
+	}
+
+	poller := NewFeedPoller(c.plain, cfg.FeedInterval, cfg.Visited, logger)
+	for _, feed := range seed.Feeds {
+		poller.Add(feed)
+	}
+
+	for {
+		if err := job.wait(); err != nil {
 			return err
 		}
 
-		ok, err := utils.CheckDomain(dom, popped)
+		if now := c.clock.Now(); poller.Due(now) {
+			for _, link := range poller.Poll(now) {
+				revisit(utils.URLInfo{URL: link, Boost: feedBoost})
+			}
+		}
+
+		current, size, ok, err := job.dequeue()
 		if err != nil {
-			continue
+			return err
 		}
 		if !ok {
+			// A feeds-only seed is kept alive to poll its feeds again once
+			// they're due, until its job is cancelled.
+			next, polling := poller.Next()
+			if !seed.FeedsOnly || !polling {
+				break
+			}
+			job.sleep(c.clock, next.Sub(c.clock.Now()))
+			continue
+		}
+		popped := current.URL
+		depth.Set(float64(size))
+
+		event := URLEvent{Seed: seed.URL, URL: popped}
+		emit := func(e Event, err error) {
+			event.Event = e
+			event.Err = err
+			counts.record(e)
+			job.record(event)
+			cfg.Metrics.Events.WithLabelValues(string(e)).Inc()
+			logEvent(cfg.Logger, event)
+			r.attempts.Add(r.id, event)
+		}
+
+		ok, err = utils.CheckDomain(dom, popped)
+		if err != nil || !ok {
+			emit(EventSkippedScope, err)
 			continue
 		}
 
 		currURL, err := utils.Normalize(popped)
 		if err != nil {
+			emit(EventSkippedScope, err)
 			continue
 		}
 
-		ok = utils.CheckAbility(visited, rules, currURL)
-		if !ok {
+		if ok := cfg.Visited.Visit(currURL); !ok && !recrawl[currURL] {
+			emit(EventSkippedVisited, nil)
+			continue
+		}
+		delete(recrawl, currURL)
+		if ok := utils.CheckRobots(rules, currURL); !ok {
+			emit(EventSkippedRobots, nil)
 			continue
 		}
 
-		page, err := utils.GetHTML(popped)
+		page, err := c.fetch(popped, logger, &event)
+		counts.bytes.Add(page.WireSize)
 		if err != nil {
+			emit(EventFetchError, err)
 			continue
 		}
+		r.stats.Record(page.WireSize, page.Size)
+		counts.fetched.Add(1)
 
-		res, err := utils.ParseHTML(dom, page)
+		doc, err := extract(dom, page)
 		if err != nil {
+			emit(EventParseError, err)
 			continue
 		}
 
-		for _, link := range res.Links {
-			queue.Enqueue(link)
+		for _, feed := range doc.Feeds {
+			poller.Add(feed)
 		}
 
-		clean := strings.TrimSpace(strings.Join(res.Content, " "))
-		if len(clean) < 500 {
+		if !seed.FeedsOnly {
+			for _, link := range doc.Links {
+				enqueue(utils.URLInfo{URL: link, Depth: current.Depth + 1})
+			}
+		}
+
+		row, skipped, attrs := buildRow(popped, page, doc, seed, cfg, c.clock.Now())
+		event.Attrs = attrs
+		if skipped != "" {
+			emit(skipped, nil)
 			continue
 		}
 
-		returned, err := queries.InsertData(context.TODO(), database.InsertDataParams{
-			Url:       popped,
-			Content:   clean,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		})
+		row.RawHash = keepRaw(popped, page, cfg, logger)
 
-		log.Println(returned)
+		writes.Add(1)
+		r.batcher.Add(row, func(err error) {
+			defer writes.Done()
+			if err != nil {
+				emit(EventStoreError, err)
+				return
+			}
+			indexFingerprint(cfg, row)
+			emit(EventStored, nil)
+		})
 
-		time.Sleep(time.Duration(rules.Delay) * time.Second)
+		delay := time.Duration(rules.Delay) * time.Second
+		cfg.Metrics.DelayWait.WithLabelValues(dom.Hostname()).Add(delay.Seconds())
+		job.sleep(c.clock, delay)
 	}
 
 	return nil
 }
+
+// fetch fetches a URL, describing the response on event and in metrics. A
+// page that couldn't be archived is logged and returned without an error.
+func (c *Crawler) fetch(rawURL string, logger Logger, event *URLEvent) (utils.Page, error) {
+	cfg := c.cfg
+	start := c.clock.Now()
+	page, err := c.fetcher.Fetch(rawURL)
+	event.Status = page.Status
+	event.ContentType = page.MediaType
+	event.Size = page.Size
+	event.Duration = c.clock.Now().Sub(start)
+	cfg.Metrics.observeFetch(page.Status, event.Duration)
+	cfg.Metrics.WireBytes.Add(float64(page.WireSize))
+	if errors.Is(err, utils.ErrArchive) {
+		logger.Warn("couldn't archive page", slog.String("url", rawURL), slog.String("error", err.Error()))
+	} else if err != nil {
+		return page, err
+	}
+	cfg.Metrics.Fetches.Inc()
+	cfg.Metrics.DecodedBytes.Add(float64(page.Size))
+
+	return page, nil
+}
+
+// extract runs the extractor for the page's media type, resolving links
+// against dom.
+func extract(dom *url.URL, page utils.Page) (utils.Document, error) {
+	extractor, ok := utils.Extractors.Lookup(page.MediaType)
+	if !ok {
+		return utils.Document{}, fmt.Errorf("no extractor for %s", page.MediaType)
+	}
+
+	return extractor.Extract(dom, page.Body)
+}
+
+// indexFingerprint records a stored page in the duplicate index, once its
+// write has succeeded, so a page that failed to store is never matched as an
+// original. A page stored as a duplicate drops any entry it had as one.
+func indexFingerprint(cfg Config, row database.InsertDataParams) {
+	if row.DuplicateOf.Valid {
+		cfg.Duplicates.Remove(row.Url)
+		return
+	}
+	cfg.Duplicates.Add(row.Url, uint64(row.Simhash))
+}
+
+// keepRaw saves the response the page came in, as it came off the wire, to
+// cfg.Blobs when there is one, returning its hash. Pages are still stored
+// when this fails, just without it.
+func keepRaw(rawURL string, page utils.Page, cfg Config, logger Logger) string {
+	if cfg.Blobs == nil || len(page.Raw) == 0 {
+		return ""
+	}
+
+	hash, err := cfg.Blobs.Put(page.Raw)
+	if err != nil {
+		logger.Warn("couldn't store raw page", slog.String("url", rawURL), slog.String("error", err.Error()))
+	}
+
+	return hash
+}
+
+// buildRow applies the checks every stored page goes through, whether it was
+// just fetched or is being reprocessed from an archive. A non-empty Event is
+// the reason the page shouldn't be stored; the attrs describe it either way.
+// now is when the page counts as stored.
+func buildRow(rawURL string, page utils.Page, doc utils.Document, seed utils.Seed, cfg Config, now time.Time) (database.InsertDataParams, Event, []slog.Attr) {
+	clean := strings.TrimSpace(strings.Join(doc.Content, "\n\n"))
+	if len(clean) < 500 {
+		return database.InsertDataParams{}, EventTooShort, []slog.Attr{slog.Int("length", len(clean))}
+	}
+
+	language := utils.DetectLanguage(clean, doc.Metadata["lang"], page.Header.Get("Content-Language"))
+	if !seed.AcceptsLanguage(language) {
+		return database.InsertDataParams{}, EventSkippedLanguage, []slog.Attr{slog.String("language", language)}
+	}
+
+	fingerprint := utils.SimHash(clean)
+	original, duplicate := cfg.Duplicates.Match(rawURL, fingerprint)
+	if duplicate && cfg.SkipDuplicates {
+		return database.InsertDataParams{}, EventDuplicate, []slog.Attr{slog.String("duplicate_of", original)}
+	}
+
+	return database.InsertDataParams{
+		Url:         rawURL,
+		Content:     clean,
+		Normalized:  utils.ApplyPipeline(clean, cfg.Pipeline),
+		ContentType: page.MediaType,
+		Language:    language,
+		Simhash:     int64(fingerprint),
+		DuplicateOf: sql.NullString{String: original, Valid: duplicate},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, "", []slog.Attr{slog.String("language", language), slog.Bool("duplicate", duplicate)}
+}
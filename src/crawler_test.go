@@ -0,0 +1,659 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/fakeweb"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// memoryStore opens a fresh in-memory SQLite store, closed when the test
+// ends. The driver is pure Go, so this needs no cgo.
+func memoryStore(t *testing.T) store.Store {
+	t.Helper()
+
+	schema, err := filepath.Abs(filepath.Join("..", "sql"))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	queries, err := store.Open("sqlite", ":memory:", os.DirFS(schema))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		queries.Close()
+	})
+
+	return queries
+}
+
+func testConfig() Config {
+	return Config{
+		Duplicates:    utils.NewSimIndex(utils.DefaultSimDistance),
+		Visited:       utils.NewSeenSet(1<<10, 0.01, utils.NewMemoryStore()),
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		BatchSize:     10,
+		BatchInterval: 50 * time.Millisecond,
+	}
+}
+
+// crawl runs Init over links in a fresh in-memory store, which it returns.
+func crawl(t *testing.T, links string) store.Store {
+	t.Helper()
+
+	queries := memoryStore(t)
+
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("links.txt", []byte(links), 0o644); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	if err := Init(context.Background(), queries, testConfig()); err != nil {
+		t.Fatalf("error running crawl, unexpected error: %v", err)
+	}
+
+	return queries
+}
+
+// stored returns the URLs of every stored page, in order.
+func stored(t *testing.T, queries store.Store) []string {
+	t.Helper()
+
+	pages, err := queries.ListPagesAfter(context.Background(), database.ListPagesAfterParams{Url: "", Limit: 1000})
+	if err != nil {
+		t.Fatalf("error reading pages, unexpected error: %v", err)
+	}
+
+	urls := []string{}
+	for _, page := range pages {
+		urls = append(urls, page.Url)
+	}
+
+	return urls
+}
+
+func outcomes(t *testing.T, queries store.Store, rawURL string) []Event {
+	t.Helper()
+
+	attempts, err := queries.ListFetchAttempts(context.Background(), rawURL)
+	if err != nil {
+		t.Fatalf("error reading fetch log, unexpected error: %v", err)
+	}
+
+	events := []Event{}
+	for _, attempt := range attempts {
+		events = append(events, Event(attempt.Outcome))
+	}
+
+	return events
+}
+
+func TestCrawl(t *testing.T) {
+	external, err := fakeweb.NewAt("127.0.0.2", fakeweb.Site{Pages: map[string]fakeweb.Page{"/": {}}})
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer external.Close()
+
+	french := "<html><head><meta charset=\"utf-8\"></head><body><p>" + strings.Repeat("Le chat est sur la table et les enfants jouent dans le jardin avec leur chien. ", 10) + "</p></body></html>"
+
+	testCases := []struct {
+		name    string
+		site    fakeweb.Site
+		options string
+		// stored lists the paths expected in the store, sorted.
+		stored []string
+		// unrequested lists paths the crawler must never fetch.
+		unrequested []string
+		// outcomes maps a path to an event in its fetch log.
+		outcomes map[string]Event
+	}{
+		{
+			name:   "F32: test case 1",
+			site:   fakeweb.Site{Pages: fakeweb.Tree(2, 2)},
+			stored: []string{"/", "/0", "/0/0", "/0/1", "/1", "/1/0", "/1/1"},
+		},
+		{
+			name: "F32: test case 2",
+			site: fakeweb.Site{
+				Robots: "User-agent: *\nDisallow: /private\n",
+				Pages: map[string]fakeweb.Page{
+					"/":             {Links: []string{"/public", "/private", "/private/deep"}},
+					"/public":       {},
+					"/private":      {},
+					"/private/deep": {},
+				},
+			},
+			stored:      []string{"/", "/public"},
+			unrequested: []string{"/private", "/private/deep"},
+			outcomes:    map[string]Event{"/private": EventSkippedRobots},
+		},
+		{
+			name: "F32: test case 3",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":       {Links: []string{"/a", external.Seed(), "mailto:someone@example.com"}},
+				"/a":      {Links: []string{"/", "/a"}},
+				"/orphan": {},
+			}},
+			stored:      []string{"/", "/a"},
+			unrequested: []string{"/orphan"},
+			outcomes:    map[string]Event{"/a": EventStored},
+		},
+		{
+			name: "F32: test case 4",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":      {Links: []string{"/old", "/moved"}},
+				"/old":   {Redirect: "/new"},
+				"/moved": {Redirect: "/new", Status: http.StatusMovedPermanently},
+				"/new":   {},
+			}},
+			stored:   []string{"/", "/moved", "/old"},
+			outcomes: map[string]Event{"/old": EventStored, "/moved": EventStored},
+		},
+		{
+			name: "F32: test case 5",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":          {Links: []string{"/missing", "/forbidden", "/broken", "/fine"}},
+				"/forbidden": {Status: http.StatusForbidden},
+				"/broken":    {Body: "internal error", Status: http.StatusInternalServerError, ContentType: "text/plain; charset=utf-8"},
+				"/fine":      {},
+			}},
+			stored: []string{"/", "/fine"},
+			outcomes: map[string]Event{
+				"/missing":   EventFetchError,
+				"/forbidden": EventFetchError,
+				"/broken":    EventTooShort,
+			},
+		},
+		{
+			name: "F32: test case 6",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":          {Links: []string{"/notes.txt", "/image.png", "/nocharset"}},
+				"/notes.txt": {Body: fakeweb.Filler("/notes.txt", 200), ContentType: "text/plain; charset=utf-8"},
+				"/image.png": {Body: "\x89PNG\r\n", ContentType: "image/png"},
+				"/nocharset": {Body: fakeweb.Render("/nocharset", fakeweb.Page{}), ContentType: "text/html"},
+			}},
+			stored:   []string{"/", "/nocharset", "/notes.txt"},
+			outcomes: map[string]Event{"/image.png": EventFetchError},
+		},
+		{
+			name: "F32: test case 7",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":      {Links: []string{"/slow", "/short"}},
+				"/slow":  {Delay: 300 * time.Millisecond},
+				"/short": {Words: 10},
+			}},
+			stored:   []string{"/", "/slow"},
+			outcomes: map[string]Event{"/short": EventTooShort},
+		},
+		{
+			name: "F32: test case 8",
+			site: fakeweb.Site{Pages: map[string]fakeweb.Page{
+				"/":   {Links: []string{"/fr"}},
+				"/fr": {Body: french},
+			}},
+			options:  " allow=en",
+			stored:   []string{"/"},
+			outcomes: map[string]Event{"/fr": EventSkippedLanguage},
+		},
+		{
+			name:        "F32: test case 9",
+			site:        fakeweb.Site{Pages: fakeweb.Tree(2, 2)},
+			options:     " mode=feeds",
+			stored:      []string{"/"},
+			unrequested: []string{"/0", "/1"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			site := fakeweb.New(testCase.site)
+			defer site.Close()
+
+			queries := crawl(t, site.Seed()+testCase.options+"\n")
+
+			expected := []string{}
+			for _, path := range testCase.stored {
+				expected = append(expected, site.Page(path))
+			}
+			if urls := stored(t, queries); !reflect.DeepEqual(urls, expected) {
+				t.Errorf("%s failed, %v != %v", testCase.name, urls, expected)
+			}
+
+			for _, path := range testCase.unrequested {
+				if hits := site.Hits(path); hits != 0 {
+					t.Errorf("%s failed, %s was requested %d times", testCase.name, path, hits)
+				}
+			}
+			for path, event := range testCase.outcomes {
+				if events := outcomes(t, queries, site.Page(path)); !slices.Contains(events, event) {
+					t.Errorf("%s failed, %s not in %v for %s", testCase.name, event, events, path)
+				}
+			}
+		})
+	}
+
+	if hits := external.Hits("/"); hits != 0 {
+		t.Errorf("F32: test case 10 failed, out of scope site was requested %d times", hits)
+	}
+
+	// Sites linking to each other are each crawled only by their own seed.
+	listeners := []net.Listener{}
+	for _, ip := range []string{"127.0.0.1", "127.0.0.3"} {
+		listener, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+		if err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		listeners = append(listeners, listener)
+	}
+	firstURL := "http://" + listeners[0].Addr().String()
+	secondURL := "http://" + listeners[1].Addr().String()
+	first := fakeweb.Serve(listeners[0], fakeweb.Site{Pages: map[string]fakeweb.Page{
+		"/":  {Links: []string{"/a", secondURL + "/b"}},
+		"/a": {},
+	}})
+	defer first.Close()
+	second := fakeweb.Serve(listeners[1], fakeweb.Site{Pages: map[string]fakeweb.Page{
+		"/":  {Links: []string{"/b", firstURL + "/a"}},
+		"/b": {},
+	}})
+	defer second.Close()
+
+	queries := crawl(t, first.Seed()+"\n"+second.Seed()+"\n")
+	expected := []string{first.Seed(), first.Page("/a"), second.Seed(), second.Page("/b")}
+	if urls := stored(t, queries); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("F32: test case 11 failed, %v != %v", urls, expected)
+	}
+	if hits := first.Hits("/a") + second.Hits("/b"); hits != 2 {
+		t.Errorf("F32: test case 12 failed, %v != %v", hits, 2)
+	}
+}
+
+// fakeClock only moves when waited on, so crawl delays pass instantly.
+// Its tickers tick as it moves past each period.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	slept   time.Duration
+	tickers []*fakeTicker
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.slept += d
+	c.tick()
+	fired := make(chan time.Time, 1)
+	fired <- c.now
+
+	return fired
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticker := &fakeTicker{clock: c, period: d, next: c.now.Add(d), ticks: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, ticker)
+
+	return ticker
+}
+
+// tick sends on every ticker whose next tick has passed, dropping ticks a
+// slow receiver missed as a time.Ticker does.
+func (c *fakeClock) tick() {
+	for _, ticker := range c.tickers {
+		if ticker.next.After(c.now) {
+			continue
+		}
+		for !ticker.next.After(c.now) {
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+		select {
+		case ticker.ticks <- c.now:
+		default:
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock  *fakeClock
+	period time.Duration
+	next   time.Time
+	ticks  chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ticks
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.clock.tickers = slices.DeleteFunc(t.clock.tickers, func(ticker *fakeTicker) bool {
+		return ticker == t
+	})
+}
+
+// advance moves the clock on without anyone waiting, as time passing
+// between requests.
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	c.tick()
+}
+
+// fakeFetcher serves bodies by URL, calling them with the fetch time so a
+// site can change as the crawl goes on.
+type fakeFetcher struct {
+	clock  Clock
+	pages  map[string]func(now time.Time) (string, string)
+	mu     sync.Mutex
+	robots int
+	calls  []string
+}
+
+// recordingLogger keeps every line logged at info or above as its level,
+// message and attrs.
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (l *recordingLogger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	record := slog.NewRecord(time.Time{}, level, msg, 0)
+	record.AddAttrs(attrs...)
+	l.add(record)
+}
+
+func (l *recordingLogger) Info(msg string, args ...any) {
+	l.log(slog.LevelInfo, msg, args)
+}
+
+func (l *recordingLogger) Warn(msg string, args ...any) {
+	l.log(slog.LevelWarn, msg, args)
+}
+
+func (l *recordingLogger) Error(msg string, args ...any) {
+	l.log(slog.LevelError, msg, args)
+}
+
+func (l *recordingLogger) log(level slog.Level, msg string, args []any) {
+	record := slog.NewRecord(time.Time{}, level, msg, 0)
+	record.Add(args...)
+	l.add(record)
+}
+
+func (l *recordingLogger) add(record slog.Record) {
+	if !l.Enabled(context.Background(), record.Level) {
+		return
+	}
+
+	line := record.Level.String() + " " + record.Message
+	record.Attrs(func(attr slog.Attr) bool {
+		line += " " + attr.String()
+		return true
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (f *fakeFetcher) Fetch(rawURL string) (utils.Page, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, rawURL)
+	f.mu.Unlock()
+
+	page, ok := f.pages[rawURL]
+	if !ok {
+		return utils.Page{Status: http.StatusNotFound}, errors.New("400+ status code")
+	}
+	mediaType, body := page(f.clock.Now())
+
+	return utils.Page{Status: http.StatusOK, MediaType: mediaType, Body: []byte(body), Size: int64(len(body))}, nil
+}
+
+func (f *fakeFetcher) Robots(seedURL string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.robots++
+	return []byte("User-agent: *\nCrawl-delay: 30\nDisallow: /private\n"), nil
+}
+
+func TestCrawler(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	html := func(path string, links ...string) func(time.Time) (string, string) {
+		return func(time.Time) (string, string) {
+			return "text/html", fakeweb.Render(path, fakeweb.Page{Links: links})
+		}
+	}
+	fresh := false
+	fetcher := &fakeFetcher{clock: clock, pages: map[string]func(time.Time) (string, string){
+		"http://fake.test/":        html("/", "/a", "/private"),
+		"http://fake.test/a":       html("/a", "/b"),
+		"http://fake.test/b":       html("/b", "/"),
+		"http://fake.test/private": html("/private"),
+		"http://fake.test/news/1":  html("/news/1"),
+		"http://fake.test/news/2":  html("/news/2"),
+		"http://fake.test/news/3":  html("/news/3"),
+		"http://fake.test/feed.xml": func(now time.Time) (string, string) {
+			// The second story is only published once a feed interval of
+			// simulated time has passed.
+			items := "<item><link>http://fake.test/news/1</link></item>"
+			if now.Sub(start) >= time.Minute {
+				items += "<item><link>http://fake.test/news/2</link></item>"
+			}
+			if fresh {
+				items += "<item><link>http://fake.test/news/3</link></item>"
+			}
+			return "application/rss+xml", "<rss><channel>" + items + "</channel></rss>"
+		},
+	}}
+
+	queries := memoryStore(t)
+	cfg := testConfig()
+	cfg.FeedInterval = time.Minute
+	cfg.Metrics = NewMetrics(nil)
+	logger := &recordingLogger{}
+
+	controller, err := NewController(queries, cfg, WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	if _, err := controller.Submit(utils.Seed{URL: "http://fake.test/", Feeds: []string{"http://fake.test/feed.xml"}}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	controller.Wait()
+	if err := controller.Close(); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	expected := []string{"http://fake.test/", "http://fake.test/a", "http://fake.test/b", "http://fake.test/news/1", "http://fake.test/news/2"}
+	if urls := stored(t, queries); !reflect.DeepEqual(urls, expected) {
+		t.Errorf("F33: test case 1 failed, %v != %v", urls, expected)
+	}
+	if slices.Contains(fetcher.calls, "http://fake.test/private") {
+		t.Errorf("F33: test case 2 failed, disallowed page fetched in %v", fetcher.calls)
+	}
+	if events := outcomes(t, queries, "http://fake.test/private"); !slices.Equal(events, []Event{EventSkippedRobots}) {
+		t.Errorf("F33: test case 3 failed, %v != %v", events, []Event{EventSkippedRobots})
+	}
+	if fetcher.robots != 1 {
+		t.Errorf("F33: test case 4 failed, %v != %v", fetcher.robots, 1)
+	}
+	if clock.slept != 5*30*time.Second {
+		t.Errorf("F33: test case 5 failed, %v != %v", clock.slept, 5*30*time.Second)
+	}
+	// /b links back to the seed, which is skipped as visited and so left out
+	// of the fetch log.
+	if events := outcomes(t, queries, "http://fake.test/"); !slices.Equal(events, []Event{EventStored}) {
+		t.Errorf("F33: test case 6 failed, %v != %v", events, []Event{EventStored})
+	}
+	if count := testutil.ToFloat64(cfg.Metrics.Events.WithLabelValues(string(EventSkippedVisited))); count != 1 {
+		t.Errorf("F33: test case 7 failed, %v != %v", count, 1)
+	}
+
+	// Everything the crawl timestamps is in simulated time.
+	end := clock.Now()
+	page, err := queries.GetPage(context.Background(), "http://fake.test/news/2")
+	if err != nil {
+		t.Fatalf("F33: test case 10 failed, unexpected error: %v", err)
+	}
+	attempts, err := queries.ListFetchAttempts(context.Background(), "http://fake.test/news/2")
+	if err != nil || len(attempts) != 1 {
+		t.Fatalf("F33: test case 10 failed, %v attempts, error %v", len(attempts), err)
+	}
+	runs, err := queries.ListLatestRuns(context.Background(), 1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("F33: test case 10 failed, %v runs, error %v", len(runs), err)
+	}
+	for _, at := range []time.Time{page.CreatedAt, page.UpdatedAt, attempts[0].CreatedAt, runs[0].StartedAt, runs[0].FinishedAt.Time} {
+		if at.Before(start) || at.After(end) {
+			t.Errorf("F33: test case 10 failed, %v isn't between %v and %v", at, start, end)
+		}
+	}
+	if !page.UpdatedAt.After(start) {
+		t.Errorf("F33: test case 10 failed, %v isn't after %v", page.UpdatedAt, start)
+	}
+
+	expectedLine := "INFO stored event=stored seed=http://fake.test/ url=http://fake.test/news/2"
+	if !slices.ContainsFunc(logger.lines, func(line string) bool { return strings.HasPrefix(line, expectedLine) }) {
+		t.Errorf("F33: test case 11 failed, %q not in %v", expectedLine, logger.lines)
+	}
+	withAttrs(logger, slog.String("seed", "http://fake.test/")).Warn("couldn't store raw page", slog.String("url", "http://fake.test/a"))
+	if line := logger.lines[len(logger.lines)-1]; line != "WARN couldn't store raw page seed=http://fake.test/ url=http://fake.test/a" {
+		t.Errorf("F33: test case 12 failed, %v != %v", line, "WARN couldn't store raw page seed=http://fake.test/ url=http://fake.test/a")
+	}
+
+	// A rerun sharing the visited set, as one loading VISITED_FILE does,
+	// fetches the seed again and only the feed entries it hasn't seen, and
+	// skips the pages they link.
+	fetcher.calls = nil
+	fresh = true
+	controller, err = NewController(queries, cfg, WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock), WithLogger(cfg.Logger))
+	if err != nil {
+		t.Fatalf("F33: test case 8 failed, unexpected error: %v", err)
+	}
+	if _, err := controller.Submit(utils.Seed{URL: "http://fake.test/", Feeds: []string{"http://fake.test/feed.xml"}}); err != nil {
+		t.Fatalf("F33: test case 8 failed, unexpected error: %v", err)
+	}
+	controller.Wait()
+	if err := controller.Close(); err != nil {
+		t.Fatalf("F33: test case 8 failed, unexpected error: %v", err)
+	}
+	// The feed is polled again once its interval passes, so calls are
+	// compacted.
+	slices.Sort(fetcher.calls)
+	fetcher.calls = slices.Compact(fetcher.calls)
+	expectedCalls := []string{"http://fake.test/", "http://fake.test/feed.xml", "http://fake.test/news/3"}
+	if !slices.Equal(fetcher.calls, expectedCalls) {
+		t.Errorf("F33: test case 8 failed, %v != %v", fetcher.calls, expectedCalls)
+	}
+
+	cfg.Visited = nil
+	cfg.Duplicates = nil
+	crawler := NewCrawler(queries, cfg)
+	if crawler.cfg.Visited == nil {
+		t.Errorf("F33: test case 9 failed, visited set wasn't defaulted")
+	}
+	if crawler.cfg.Duplicates == nil {
+		t.Errorf("F33: test case 9 failed, duplicate index wasn't defaulted")
+	}
+
+	// A feeds-only seed keeps polling its feed once the queue runs dry, until
+	// it's cancelled.
+	fetcher.mu.Lock()
+	fetcher.calls = nil
+	fetcher.mu.Unlock()
+	cfg = testConfig()
+	cfg.FeedInterval = time.Minute
+	queries = memoryStore(t)
+	controller, err = NewController(queries, cfg, WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock))
+	if err != nil {
+		t.Fatalf("F33: test case 13 failed, unexpected error: %v", err)
+	}
+	job, err := controller.Submit(utils.Seed{URL: "http://fake.test/", Feeds: []string{"http://fake.test/feed.xml"}, FeedsOnly: true})
+	if err != nil {
+		t.Fatalf("F33: test case 13 failed, unexpected error: %v", err)
+	}
+	calls := func() []string {
+		fetcher.mu.Lock()
+		defer fetcher.mu.Unlock()
+		return slices.Clone(fetcher.calls)
+	}
+	eventually(t, "F33: test case 13", func() bool {
+		polls := 0
+		for _, call := range calls() {
+			if call == "http://fake.test/feed.xml" {
+				polls++
+			}
+		}
+		return polls >= 3
+	})
+	if state := job.Status().State; state != SeedRunning {
+		t.Errorf("F33: test case 13 failed, %v != %v", state, SeedRunning)
+	}
+	if err := job.Cancel(); err != nil {
+		t.Fatalf("F33: test case 14 failed, unexpected error: %v", err)
+	}
+	controller.Wait()
+	if state := job.Status().State; state != SeedCancelled {
+		t.Errorf("F33: test case 14 failed, %v != %v", state, SeedCancelled)
+	}
+	if err := controller.Close(); err != nil {
+		t.Fatalf("F33: test case 14 failed, unexpected error: %v", err)
+	}
+	entries := slices.DeleteFunc(calls(), func(call string) bool {
+		return call == "http://fake.test/feed.xml"
+	})
+	// Each entry is fetched once, ahead of the seed.
+	if expected := []string{"http://fake.test/news/1", "http://fake.test/news/2", "http://fake.test/news/3", "http://fake.test/"}; !slices.Equal(entries, expected) {
+		t.Errorf("F33: test case 15 failed, %v != %v", entries, expected)
+	}
+
+	// Cancelling a crawl before it's done leaves the run interrupted rather
+	// than completed.
+	runs, err = queries.ListLatestRuns(context.Background(), 1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("F33: test case 16 failed, %v runs, error %v", len(runs), err)
+	}
+	if runs[0].Status != RunInterrupted || !runs[0].FinishedAt.Valid {
+		t.Errorf("F33: test case 16 failed, %v != %v", runs[0].Status, RunInterrupted)
+	}
+}
@@ -0,0 +1,220 @@
+package src
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/utils"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// dashboardRefresh is how often, in seconds, pages showing live progress
+// reload themselves.
+const dashboardRefresh = 5
+
+// dashboardPages is how many stored pages the browser lists at a time.
+const dashboardPages = 50
+
+// view is what every dashboard template needs besides its own data.
+type view struct {
+	Title   string
+	Refresh int
+}
+
+type crawlsView struct {
+	view
+	Jobs     []JobStatus
+	Failures []database.ListHostFailuresRow
+	Statuses []database.ListHostErrorStatusesRow
+}
+
+type crawlView struct {
+	view
+	Job      JobStatus
+	Queued   int
+	Frontier []utils.URLInfo
+	Results  []FetchResult
+}
+
+type pagesView struct {
+	view
+	Seed     string
+	Language string
+	Pages    []database.Datum
+	Next     string
+}
+
+type pageView struct {
+	view
+	Page     database.Datum
+	Attempts []database.FetchLog
+	Robots   robotsDecision
+}
+
+// robotsDecision is what robots.txt said about a page the last time a crawl
+// dequeued it, as the fetch log recorded.
+type robotsDecision struct {
+	Known   bool
+	Allowed bool
+	At      time.Time
+}
+
+// robotsOutcome reads the decision from the newest attempt. Only robots.txt
+// skips a URL without fetching it once it's in scope, so any other logged
+// outcome means it was allowed.
+func robotsOutcome(attempts []database.FetchLog) robotsDecision {
+	if len(attempts) == 0 {
+		return robotsDecision{}
+	}
+	last := attempts[len(attempts)-1]
+
+	return robotsDecision{Known: true, Allowed: last.Outcome != string(EventSkippedRobots), At: last.CreatedAt}
+}
+
+func (s *server) crawls(w http.ResponseWriter, r *http.Request) {
+	data := crawlsView{view: view{Title: "Crawls", Refresh: dashboardRefresh}, Jobs: []JobStatus{}}
+	for _, job := range s.controller.Jobs() {
+		data.Jobs = append(data.Jobs, job.Status())
+	}
+
+	var err error
+	if data.Failures, err = s.controller.queries.ListHostFailures(r.Context()); err != nil {
+		s.failPage(w, http.StatusInternalServerError, err)
+		return
+	}
+	if data.Statuses, err = s.controller.queries.ListHostErrorStatuses(r.Context()); err != nil {
+		s.failPage(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.render(w, "crawls.html", data)
+}
+
+func (s *server) crawl(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.failPage(w, http.StatusNotFound, ErrJobNotFound)
+		return
+	}
+	job, err := s.controller.Job(id)
+	if err != nil {
+		s.failPage(w, http.StatusNotFound, err)
+		return
+	}
+
+	status := job.Status()
+	data := crawlView{view: view{Title: status.Seed, Refresh: dashboardRefresh}, Job: status, Results: job.Results()}
+	data.Queued, data.Frontier = job.Frontier(20)
+
+	s.render(w, "crawl.html", data)
+}
+
+func (s *server) pages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := pagesView{
+		view:     view{Title: "Pages"},
+		Seed:     query.Get("seed"),
+		Language: query.Get("language"),
+		Pages:    []database.Datum{},
+	}
+
+	host := ""
+	if data.Seed != "" {
+		parsed, err := url.Parse(data.Seed)
+		if err != nil {
+			s.failPage(w, http.StatusBadRequest, err)
+			return
+		}
+		host = parsed.Hostname()
+	}
+
+	pages, err := s.controller.queries.ListPagesMatching(r.Context(), database.ListPagesMatchingParams{
+		Url:      query.Get("after"),
+		Host:     host,
+		Language: data.Language,
+		Limit:    dashboardPages + 1,
+	})
+	if err != nil {
+		s.failPage(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(pages) > dashboardPages {
+		pages = pages[:dashboardPages]
+		data.Next = pages[len(pages)-1].Url
+	}
+	data.Pages = append(data.Pages, pages...)
+
+	s.render(w, "pages.html", data)
+}
+
+func (s *server) page(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+
+	page, err := s.controller.queries.GetPage(r.Context(), rawURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		s.failPage(w, http.StatusNotFound, fmt.Errorf("%s isn't stored", rawURL))
+		return
+	}
+	if err != nil {
+		s.failPage(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	attempts, err := s.controller.queries.ListFetchAttempts(r.Context(), rawURL)
+	if err != nil {
+		s.failPage(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.render(w, "page.html", pageView{
+		view:     view{Title: page.Url},
+		Page:     page,
+		Attempts: attempts,
+		Robots:   robotsOutcome(attempts),
+	})
+}
+
+func (s *server) render(w http.ResponseWriter, name string, data any) {
+	// Templates are rendered into a buffer first so one that fails halfway
+	// doesn't send half a page.
+	page := &bytes.Buffer{}
+	if err := templates.ExecuteTemplate(page, name, data); err != nil {
+		s.logger.Error("couldn't render dashboard", slog.String("template", name), slog.String("error", err.Error()))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
+
+func (s *server) failPage(w http.ResponseWriter, status int, err error) {
+	page := &bytes.Buffer{}
+	data := struct {
+		view
+		Err error
+	}{view{Title: http.StatusText(status)}, err}
+	if err := templates.ExecuteTemplate(page, "error.html", data); err != nil {
+		s.logger.Error("couldn't render dashboard", slog.String("template", "error.html"), slog.String("error", err.Error()))
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,187 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/fakeweb"
+	"github.com/junwei890/crawler/utils"
+)
+
+func TestDashboard(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+	fetcher := &fakeFetcher{clock: clock, pages: map[string]func(time.Time) (string, string){
+		"http://fake.test/": func(time.Time) (string, string) {
+			return "text/html", fakeweb.Render("/", fakeweb.Page{Links: []string{"/private"}})
+		},
+	}}
+
+	queries := memoryStore(t)
+	controller, err := NewController(queries, testConfig(), WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer controller.Close()
+	if _, err := controller.Submit(utils.Seed{URL: "http://fake.test/"}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	controller.Wait()
+
+	for i := range dashboardPages + 10 {
+		page := database.InsertDataParams{Url: fmt.Sprintf("https://www.google.com/%02d", i), Content: fmt.Sprintf("page %d", i), Language: "en", CreatedAt: now, UpdatedAt: now}
+		if _, err := queries.InsertData(context.Background(), page); err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+	}
+	if _, err := queries.InsertData(context.Background(), database.InsertDataParams{Url: "https://arxiv.org/a", Content: "un article", Language: "fr", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	attempts := []database.InsertFetchLogParams{
+		{Url: "https://www.google.com/00", Outcome: string(EventStored), CreatedAt: now},
+		{Url: "https://www.google.com/00", Outcome: string(EventSkippedRobots), CreatedAt: now.Add(time.Hour)},
+		{Url: "https://www.google.com/01", Outcome: string(EventSkippedRobots), CreatedAt: now},
+		{Url: "https://www.google.com/01", Outcome: string(EventStored), CreatedAt: now.Add(time.Hour)},
+	}
+	for _, attempt := range attempts {
+		attempt.RunID = controller.run.id
+		attempt.Host = "www.google.com"
+		if err := queries.InsertFetchLog(context.Background(), attempt); err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+	}
+
+	handler := NewServer(controller)
+	get := func(path string) (int, string) {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res.Code, res.Body.String()
+	}
+	pageLink := `<a href="/ui/pages/view?url=`
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		contains []string
+		excludes []string
+		links    int
+	}{
+		{
+			name:     "F40: test case 1",
+			path:     "/",
+			status:   http.StatusFound,
+			contains: []string{"/ui/"},
+		},
+		{
+			name:     "F40: test case 2",
+			path:     "/ui/",
+			status:   http.StatusOK,
+			contains: []string{`<a href="/ui/crawls/1">http://fake.test/</a>`, "completed", "skipped-robots"},
+		},
+		{
+			name:     "F40: test case 3",
+			path:     "/ui/crawls/1",
+			status:   http.StatusOK,
+			contains: []string{"<title>http://fake.test/ · crawler</title>", "Nothing queued.", "/private"},
+		},
+		{
+			name:   "F40: test case 4",
+			path:   "/ui/crawls/2",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "F40: test case 5",
+			path:   "/ui/crawls/one",
+			status: http.StatusNotFound,
+		},
+		{
+			name:     "F40: test case 6",
+			path:     "/ui/pages?seed=" + url.QueryEscape("https://www.google.com/"),
+			status:   http.StatusOK,
+			contains: []string{"https://www.google.com/00", "https://www.google.com/49", "Next</a>"},
+			excludes: []string{"https://www.google.com/50", "http://fake.test/"},
+			links:    dashboardPages,
+		},
+		{
+			name:     "F40: test case 7",
+			path:     "/ui/pages?seed=" + url.QueryEscape("https://www.google.com/") + "&after=" + url.QueryEscape("https://www.google.com/49"),
+			status:   http.StatusOK,
+			contains: []string{"https://www.google.com/50", "https://www.google.com/59"},
+			excludes: []string{"Next</a>"},
+			links:    10,
+		},
+		{
+			name:     "F40: test case 8",
+			path:     "/ui/pages?language=fr",
+			status:   http.StatusOK,
+			contains: []string{"https://arxiv.org/a"},
+			links:    1,
+		},
+		{
+			name:     "F40: test case 9",
+			path:     "/ui/pages?seed=" + url.QueryEscape("https://www.google.com/") + "&language=fr",
+			status:   http.StatusOK,
+			contains: []string{"No stored pages match."},
+		},
+		{
+			name:     "F40: test case 10",
+			path:     "/ui/pages?seed=" + url.QueryEscape("://bad"),
+			status:   http.StatusBadRequest,
+			contains: []string{"missing protocol scheme"},
+		},
+		{
+			name:     "F40: test case 11",
+			path:     "/ui/pages/view?url=" + url.QueryEscape("https://www.google.com/00"),
+			status:   http.StatusOK,
+			contains: []string{"<pre>page 0</pre>", `<span class="bad">disallowed</span> <span class="muted">as of 2025-01-01 01:00:00</span>`},
+		},
+		{
+			name:     "F40: test case 12",
+			path:     "/ui/pages/view?url=" + url.QueryEscape("https://www.google.com/01"),
+			status:   http.StatusOK,
+			contains: []string{`<span class="good">allowed</span>`},
+		},
+		{
+			name:     "F40: test case 13",
+			path:     "/ui/pages/view?url=" + url.QueryEscape("https://www.google.com/02"),
+			status:   http.StatusOK,
+			contains: []string{"no attempts logged", "No attempts logged."},
+		},
+		{
+			name:     "F40: test case 14",
+			path:     "/ui/pages/view?url=" + url.QueryEscape("https://www.google.com/zz"),
+			status:   http.StatusNotFound,
+			contains: []string{"https://www.google.com/zz isn&#39;t stored"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := get(test.path)
+			if status != test.status {
+				t.Errorf("%s failed, %v != %v", test.name, status, test.status)
+			}
+			for _, expected := range test.contains {
+				if !strings.Contains(body, expected) {
+					t.Errorf("%s failed, %q not in %s", test.name, expected, body)
+				}
+			}
+			for _, unexpected := range test.excludes {
+				if strings.Contains(body, unexpected) {
+					t.Errorf("%s failed, %q in %s", test.name, unexpected, body)
+				}
+			}
+			if links := strings.Count(body, pageLink); test.links != 0 && links != test.links {
+				t.Errorf("%s failed, %v != %v page links", test.name, links, test.links)
+			}
+		})
+	}
+}
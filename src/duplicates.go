@@ -0,0 +1,27 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/junwei890/crawler/internal/store"
+)
+
+func Duplicates(queries store.Store, w io.Writer) error {
+	rows, err := queries.ListDuplicateClusters(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	current := ""
+	for _, row := range rows {
+		if row.DuplicateOf.String != current {
+			current = row.DuplicateOf.String
+			fmt.Fprintln(w, current)
+		}
+		fmt.Fprintf(w, "\t%s\n", row.Url)
+	}
+
+	return nil
+}
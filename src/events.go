@@ -0,0 +1,82 @@
+package src
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Event string
+
+const (
+	EventEnqueued        Event = "enqueued"
+	EventSkippedScope    Event = "skipped-scope"
+	EventSkippedVisited  Event = "skipped-visited"
+	EventSkippedRobots   Event = "skipped-robots"
+	EventFetchError      Event = "fetch-error"
+	EventParseError      Event = "parse-error"
+	EventTooShort        Event = "too-short"
+	EventSkippedLanguage Event = "skipped-language"
+	EventDuplicate       Event = "duplicate"
+	EventStored          Event = "stored"
+	EventStoreError      Event = "store-error"
+)
+
+var eventLevels = map[Event]slog.Level{
+	EventEnqueued:        slog.LevelDebug,
+	EventSkippedScope:    slog.LevelDebug,
+	EventSkippedVisited:  slog.LevelDebug,
+	EventSkippedRobots:   slog.LevelInfo,
+	EventFetchError:      slog.LevelWarn,
+	EventParseError:      slog.LevelWarn,
+	EventTooShort:        slog.LevelInfo,
+	EventSkippedLanguage: slog.LevelInfo,
+	EventDuplicate:       slog.LevelInfo,
+	EventStored:          slog.LevelInfo,
+	EventStoreError:      slog.LevelError,
+}
+
+// URLEvent describes one decision the crawler made about a URL. Status,
+// ContentType, Size and Duration are left zero when the URL was never fetched.
+type URLEvent struct {
+	Event       Event
+	Seed        string
+	URL         string
+	Status      int
+	ContentType string
+	Size        int64
+	Duration    time.Duration
+	Err         error
+	Attrs       []slog.Attr
+}
+
+func logEvent(logger Logger, e URLEvent) {
+	level := eventLevels[e.Event]
+	if !logger.Enabled(context.TODO(), level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("event", string(e.Event)),
+		slog.String("seed", e.Seed),
+		slog.String("url", e.URL),
+	}
+	if e.Status != 0 {
+		attrs = append(attrs, slog.Int("status", e.Status))
+	}
+	if e.ContentType != "" {
+		attrs = append(attrs, slog.String("content_type", e.ContentType))
+	}
+	if e.Size != 0 {
+		attrs = append(attrs, slog.Int64("size", e.Size))
+	}
+	if e.Duration != 0 {
+		attrs = append(attrs, slog.Duration("duration", e.Duration))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	attrs = append(attrs, e.Attrs...)
+
+	logger.LogAttrs(context.TODO(), level, string(e.Event), attrs...)
+}
@@ -0,0 +1,76 @@
+package src
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogEvent(t *testing.T) {
+	events := []URLEvent{
+		{Event: EventEnqueued, Seed: "https://www.google.com/", URL: "https://www.google.com/a"},
+		{Event: EventStored, Seed: "https://www.google.com/", URL: "https://www.google.com/b", Status: 200, ContentType: "text/html", Size: 1024, Duration: time.Second},
+		{Event: EventFetchError, Seed: "https://www.google.com/", URL: "https://www.google.com/c", Status: 404, Err: errors.New("400+ status code")},
+		{Event: EventStoreError, Seed: "https://www.google.com/", URL: "https://www.google.com/d", Err: errors.New("store unavailable"), Attrs: []slog.Attr{slog.Int("length", 600)}},
+	}
+
+	testCases := []struct {
+		name     string
+		level    slog.Level
+		expected []string
+	}{
+		{
+			name:  "F45: test case 1",
+			level: slog.LevelDebug,
+			expected: []string{
+				"level=DEBUG msg=enqueued event=enqueued seed=https://www.google.com/ url=https://www.google.com/a",
+				"level=INFO msg=stored event=stored seed=https://www.google.com/ url=https://www.google.com/b status=200 content_type=text/html size=1024 duration=1s",
+				`level=WARN msg=fetch-error event=fetch-error seed=https://www.google.com/ url=https://www.google.com/c status=404 error="400+ status code"`,
+				`level=ERROR msg=store-error event=store-error seed=https://www.google.com/ url=https://www.google.com/d error="store unavailable" length=600`,
+			},
+		},
+		{
+			name:  "F45: test case 2",
+			level: slog.LevelInfo,
+			expected: []string{
+				"level=INFO msg=stored event=stored seed=https://www.google.com/ url=https://www.google.com/b status=200 content_type=text/html size=1024 duration=1s",
+				`level=WARN msg=fetch-error event=fetch-error seed=https://www.google.com/ url=https://www.google.com/c status=404 error="400+ status code"`,
+				`level=ERROR msg=store-error event=store-error seed=https://www.google.com/ url=https://www.google.com/d error="store unavailable" length=600`,
+			},
+		},
+		{
+			name:  "F45: test case 3",
+			level: slog.LevelError,
+			expected: []string{
+				`level=ERROR msg=store-error event=store-error seed=https://www.google.com/ url=https://www.google.com/d error="store unavailable" length=600`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{
+				Level: testCase.level,
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if attr.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			}))
+			for _, event := range events {
+				logEvent(logger, event)
+			}
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if !slices.Equal(lines, testCase.expected) {
+				t.Errorf("%s failed, %v != %v", testCase.name, lines, testCase.expected)
+			}
+		})
+	}
+}
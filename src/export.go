@@ -0,0 +1,314 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// exportBatch is how many pages Export reads from the store at a time.
+const exportBatch = 500
+
+// ExportFilter picks the pages an export writes. Zero fields don't filter.
+// Since and Until bound when a page was last stored, Until exclusively, and
+// MinLength counts characters of content.
+type ExportFilter struct {
+	Seed      string
+	Since     time.Time
+	Until     time.Time
+	Language  string
+	MinLength int
+}
+
+// listParams pushes everything but MinLength down to the store, so an export
+// only reads the pages it might write.
+func (f ExportFilter) listParams() (database.ListPagesMatchingParams, error) {
+	params := database.ListPagesMatchingParams{
+		Language: f.Language,
+		Since:    sql.NullTime{Time: f.Since, Valid: !f.Since.IsZero()},
+		Until:    sql.NullTime{Time: f.Until, Valid: !f.Until.IsZero()},
+		Limit:    exportBatch,
+	}
+	if f.Seed != "" {
+		parsed, err := url.Parse(f.Seed)
+		if err != nil {
+			return params, err
+		}
+		if parsed.Hostname() == "" {
+			return params, fmt.Errorf("seed has no host: %s", f.Seed)
+		}
+		params.Host = parsed.Hostname()
+	}
+
+	return params, nil
+}
+
+// Exporter writes pages out in one format. Close flushes whatever is
+// buffered, so an export isn't complete until it returns.
+type Exporter interface {
+	Write(page database.Datum) error
+	Close() error
+}
+
+// NewExporter returns an Exporter for format, one of jsonl, csv, parquet or
+// markdown. The first three write to the file out, or stdout when out is
+// empty or "-", while markdown writes a file per page into the directory out.
+func NewExporter(format, out string) (Exporter, error) {
+	if format == "markdown" {
+		if out == "" || out == "-" {
+			return nil, errors.New("markdown exports need a directory to write to")
+		}
+		if err := os.MkdirAll(out, 0o755); err != nil {
+			return nil, err
+		}
+		return &markdownExporter{dir: out}, nil
+	}
+
+	if format != "jsonl" && format != "csv" && format != "parquet" {
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+
+	var file io.WriteCloser = nopCloser{os.Stdout}
+	if out != "" && out != "-" {
+		created, err := os.Create(out)
+		if err != nil {
+			return nil, err
+		}
+		file = created
+	}
+
+	switch format {
+	case "jsonl":
+		buffered := bufio.NewWriter(file)
+		return &jsonlExporter{file: file, buffered: buffered, encoder: json.NewEncoder(buffered)}, nil
+	case "csv":
+		exporter := &csvExporter{file: file, writer: csv.NewWriter(file)}
+		if err := exporter.writer.Write(csvHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return exporter, nil
+	}
+
+	parquet, err := writer.NewParquetWriterFromWriter(file, new(parquetPage), 1)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &parquetExporter{file: file, writer: parquet}, nil
+}
+
+// Export streams the stored pages matching filter to exporter in url order,
+// closing it once every page has been written, and prints a summary to w.
+func Export(queries store.Store, filter ExportFilter, exporter Exporter, w io.Writer) error {
+	params, err := filter.listParams()
+	if err != nil {
+		return errors.Join(err, exporter.Close())
+	}
+
+	scanned, exported := 0, 0
+	for {
+		pages, err := queries.ListPagesMatching(context.TODO(), params)
+		if err != nil {
+			return errors.Join(err, exporter.Close())
+		}
+
+		for _, page := range pages {
+			scanned++
+			if utf8.RuneCountInString(page.Content) < filter.MinLength {
+				continue
+			}
+			if err := exporter.Write(page); err != nil {
+				return errors.Join(fmt.Errorf("%s: %w", page.Url, err), exporter.Close())
+			}
+			exported++
+		}
+
+		if len(pages) < exportBatch {
+			break
+		}
+		params.Url = pages[len(pages)-1].Url
+	}
+
+	if err := exporter.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "exported %d of %d pages\n", exported, scanned)
+
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type jsonlExporter struct {
+	file     io.WriteCloser
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (e *jsonlExporter) Write(page database.Datum) error {
+	return e.encoder.Encode(store.PageRecord{
+		URL:         page.Url,
+		Content:     page.Content,
+		ContentType: page.ContentType,
+		Normalized:  page.Normalized,
+		Language:    page.Language,
+		Simhash:     page.Simhash,
+		DuplicateOf: page.DuplicateOf.String,
+		RawHash:     page.RawHash,
+		CreatedAt:   page.CreatedAt,
+		UpdatedAt:   page.UpdatedAt,
+	})
+}
+
+func (e *jsonlExporter) Close() error {
+	return errors.Join(e.buffered.Flush(), e.file.Close())
+}
+
+var csvHeader = []string{"url", "content_type", "language", "simhash", "duplicate_of", "raw_hash", "created_at", "updated_at", "content", "normalized"}
+
+type csvExporter struct {
+	file   io.WriteCloser
+	writer *csv.Writer
+}
+
+func (e *csvExporter) Write(page database.Datum) error {
+	return e.writer.Write([]string{
+		page.Url,
+		page.ContentType,
+		page.Language,
+		strconv.FormatInt(page.Simhash, 10),
+		page.DuplicateOf.String,
+		page.RawHash,
+		page.CreatedAt.Format(time.RFC3339),
+		page.UpdatedAt.Format(time.RFC3339),
+		page.Content,
+		page.Normalized,
+	})
+}
+
+func (e *csvExporter) Close() error {
+	e.writer.Flush()
+	return errors.Join(e.writer.Error(), e.file.Close())
+}
+
+type parquetPage struct {
+	URL         string  `parquet:"name=url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ContentType string  `parquet:"name=content_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Language    string  `parquet:"name=language, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Simhash     int64   `parquet:"name=simhash, type=INT64"`
+	DuplicateOf *string `parquet:"name=duplicate_of, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	RawHash     string  `parquet:"name=raw_hash, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreatedAt   int64   `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	UpdatedAt   int64   `parquet:"name=updated_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Content     string  `parquet:"name=content, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Normalized  string  `parquet:"name=normalized, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// parquetExporter holds a row group in memory until it fills, so it streams
+// in row group sized pieces rather than page by page.
+type parquetExporter struct {
+	file   io.WriteCloser
+	writer *writer.ParquetWriter
+}
+
+func (e *parquetExporter) Write(page database.Datum) error {
+	row := parquetPage{
+		URL:         page.Url,
+		ContentType: page.ContentType,
+		Language:    page.Language,
+		Simhash:     page.Simhash,
+		RawHash:     page.RawHash,
+		CreatedAt:   page.CreatedAt.UnixMilli(),
+		UpdatedAt:   page.UpdatedAt.UnixMilli(),
+		Content:     page.Content,
+		Normalized:  page.Normalized,
+	}
+	if page.DuplicateOf.Valid {
+		row.DuplicateOf = &page.DuplicateOf.String
+	}
+
+	return e.writer.Write(row)
+}
+
+func (e *parquetExporter) Close() error {
+	return errors.Join(e.writer.WriteStop(), e.file.Close())
+}
+
+type markdownExporter struct {
+	dir string
+}
+
+func (e *markdownExporter) Write(page database.Datum) error {
+	doc := &strings.Builder{}
+	doc.WriteString("---\n")
+	fmt.Fprintf(doc, "url: %s\n", strconv.Quote(page.Url))
+	fmt.Fprintf(doc, "content_type: %s\n", strconv.Quote(page.ContentType))
+	fmt.Fprintf(doc, "language: %s\n", strconv.Quote(page.Language))
+	if page.DuplicateOf.Valid {
+		fmt.Fprintf(doc, "duplicate_of: %s\n", strconv.Quote(page.DuplicateOf.String))
+	}
+	fmt.Fprintf(doc, "created_at: %s\n", page.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(doc, "updated_at: %s\n", page.UpdatedAt.Format(time.RFC3339))
+	doc.WriteString("---\n\n")
+	doc.WriteString(page.Content)
+	doc.WriteString("\n")
+
+	return os.WriteFile(filepath.Join(e.dir, markdownName(page.Url)), []byte(doc.String()), 0o644)
+}
+
+func (e *markdownExporter) Close() error {
+	return nil
+}
+
+// markdownName turns a url into a readable file name, suffixed with a hash of
+// the url since different urls can slug to the same name.
+func markdownName(rawURL string) string {
+	slug := strings.Builder{}
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(rawURL, "https://"), "http://")) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && slug.Len() > 0 {
+			slug.WriteRune('-')
+			dash = true
+		}
+	}
+
+	name := strings.TrimRight(slug.String(), "-")
+	if len(name) > 80 {
+		name = strings.TrimRight(name[:80], "-")
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+
+	return fmt.Sprintf("%s-%s.md", name, hex.EncodeToString(sum[:4]))
+}
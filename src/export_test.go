@@ -0,0 +1,302 @@
+package src
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// recordingExporter keeps the urls written to it.
+type recordingExporter struct {
+	urls   []string
+	closed bool
+}
+
+func (e *recordingExporter) Write(page database.Datum) error {
+	e.urls = append(e.urls, page.Url)
+	return nil
+}
+
+func (e *recordingExporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	queries := memoryStore(t)
+
+	pages := []database.InsertDataParams{
+		{Url: "https://www.google.com/a", Content: "héllo", Language: "fr", UpdatedAt: now.Add(-time.Hour)},
+		{Url: "https://www.google.com/b", Content: "hello world", Language: "en", UpdatedAt: now},
+		{Url: "https://www.google.com/c", Content: "hi", Language: "en", UpdatedAt: now.Add(time.Hour)},
+		{Url: "https://arxiv.org/a", Content: "hello there", Language: "en", UpdatedAt: now.Add(2 * time.Hour)},
+	}
+	for _, page := range pages {
+		page.CreatedAt = page.UpdatedAt
+		if _, err := queries.InsertData(context.Background(), page); err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   ExportFilter
+		expected []string
+		// read is how many pages the store returns, only MinLength being
+		// left to filter on after.
+		read int
+	}{
+		{
+			name:     "F38: test case 1",
+			filter:   ExportFilter{},
+			expected: []string{"https://arxiv.org/a", "https://www.google.com/a", "https://www.google.com/b", "https://www.google.com/c"},
+			read:     4,
+		},
+		{
+			name:     "F38: test case 2",
+			filter:   ExportFilter{Seed: "https://www.google.com/"},
+			expected: []string{"https://www.google.com/a", "https://www.google.com/b", "https://www.google.com/c"},
+			read:     3,
+		},
+		{
+			name:     "F38: test case 3",
+			filter:   ExportFilter{Since: now},
+			expected: []string{"https://arxiv.org/a", "https://www.google.com/b", "https://www.google.com/c"},
+			read:     3,
+		},
+		{
+			name:     "F38: test case 4",
+			filter:   ExportFilter{Until: now.Add(time.Hour)},
+			expected: []string{"https://www.google.com/a", "https://www.google.com/b"},
+			read:     2,
+		},
+		{
+			name:     "F38: test case 5",
+			filter:   ExportFilter{Since: now, Until: now.Add(time.Hour)},
+			expected: []string{"https://www.google.com/b"},
+			read:     1,
+		},
+		{
+			name:     "F38: test case 6",
+			filter:   ExportFilter{Language: "fr"},
+			expected: []string{"https://www.google.com/a"},
+			read:     1,
+		},
+		{
+			name:     "F38: test case 7",
+			filter:   ExportFilter{MinLength: 5},
+			expected: []string{"https://arxiv.org/a", "https://www.google.com/a", "https://www.google.com/b"},
+			read:     4,
+		},
+		{
+			name:     "F38: test case 8",
+			filter:   ExportFilter{Seed: "https://www.google.com/", Language: "en", MinLength: 5},
+			expected: []string{"https://www.google.com/b"},
+			read:     2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exporter := &recordingExporter{}
+			summary := &strings.Builder{}
+			if err := Export(queries, test.filter, exporter, summary); err != nil {
+				t.Fatalf("%s failed, unexpected error: %v", test.name, err)
+			}
+			if !slices.Equal(exporter.urls, test.expected) || !exporter.closed {
+				t.Errorf("%s failed, %v != %v, closed %v", test.name, exporter.urls, test.expected, exporter.closed)
+			}
+			expectedSummary := fmt.Sprintf("exported %d of %d pages\n", len(test.expected), test.read)
+			if summary.String() != expectedSummary {
+				t.Errorf("%s failed, %q != %q", test.name, summary.String(), expectedSummary)
+			}
+		})
+	}
+}
+
+func TestExportBatches(t *testing.T) {
+	queries, err := store.OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer queries.Close()
+
+	expected := []string{}
+	for i := range exportBatch + 1 {
+		url := fmt.Sprintf("https://www.google.com/%04d", i)
+		if _, err := queries.InsertData(context.Background(), database.InsertDataParams{Url: url}); err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		expected = append(expected, url)
+	}
+
+	exporter := &recordingExporter{}
+	if err := Export(queries, ExportFilter{}, exporter, io.Discard); err != nil {
+		t.Fatalf("F38: test case 9 failed, unexpected error: %v", err)
+	}
+	if !slices.Equal(exporter.urls, expected) {
+		t.Errorf("F38: test case 9 failed, exported %d pages != %d", len(exporter.urls), len(expected))
+	}
+}
+
+func TestExporters(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	pages := []database.Datum{
+		{Url: "https://www.google.com/a", Content: "a, \"quoted\"\nline", ContentType: "text/html", Language: "en", Simhash: 1, RawHash: "ff", CreatedAt: created, UpdatedAt: updated},
+		{Url: "https://www.google.com/b", Content: "b", ContentType: "text/plain", Simhash: 2, DuplicateOf: sql.NullString{String: "https://www.google.com/a", Valid: true}, CreatedAt: created, UpdatedAt: updated},
+	}
+
+	export := func(t *testing.T, format, out string) {
+		t.Helper()
+
+		exporter, err := NewExporter(format, out)
+		if err != nil {
+			t.Fatalf("error exporting %s, unexpected error: %v", format, err)
+		}
+		for _, page := range pages {
+			if err := exporter.Write(page); err != nil {
+				t.Fatalf("error exporting %s, unexpected error: %v", format, err)
+			}
+		}
+		if err := exporter.Close(); err != nil {
+			t.Fatalf("error exporting %s, unexpected error: %v", format, err)
+		}
+	}
+
+	t.Run("jsonl", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "pages.jsonl")
+		export(t, "jsonl", out)
+
+		file, err := os.Open(out)
+		if err != nil {
+			t.Fatalf("F38: test case 10 failed, unexpected error: %v", err)
+		}
+		defer file.Close()
+
+		decoder := json.NewDecoder(file)
+		for _, page := range pages {
+			record := store.PageRecord{}
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatalf("F38: test case 10 failed, unexpected error: %v", err)
+			}
+			if record.URL != page.Url || record.Content != page.Content || record.DuplicateOf != page.DuplicateOf.String || !record.UpdatedAt.Equal(page.UpdatedAt) {
+				t.Errorf("F38: test case 10 failed, %v != %v", record, page)
+			}
+		}
+		if decoder.More() {
+			t.Errorf("F38: test case 10 failed, more than %d lines", len(pages))
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "pages.csv")
+		export(t, "csv", out)
+
+		file, err := os.Open(out)
+		if err != nil {
+			t.Fatalf("F38: test case 11 failed, unexpected error: %v", err)
+		}
+		defer file.Close()
+
+		records, err := csv.NewReader(file).ReadAll()
+		if err != nil {
+			t.Fatalf("F38: test case 11 failed, unexpected error: %v", err)
+		}
+		expected := [][]string{
+			csvHeader,
+			{"https://www.google.com/a", "text/html", "en", "1", "", "ff", "2025-01-01T00:00:00Z", "2025-01-01T01:00:00Z", "a, \"quoted\"\nline", ""},
+			{"https://www.google.com/b", "text/plain", "", "2", "https://www.google.com/a", "", "2025-01-01T00:00:00Z", "2025-01-01T01:00:00Z", "b", ""},
+		}
+		if !slices.EqualFunc(records, expected, slices.Equal) {
+			t.Errorf("F38: test case 11 failed, %v != %v", records, expected)
+		}
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "pages.parquet")
+		export(t, "parquet", out)
+
+		file, err := local.NewLocalFileReader(out)
+		if err != nil {
+			t.Fatalf("F38: test case 12 failed, unexpected error: %v", err)
+		}
+		defer file.Close()
+
+		parquet, err := reader.NewParquetReader(file, new(parquetPage), 1)
+		if err != nil {
+			t.Fatalf("F38: test case 12 failed, unexpected error: %v", err)
+		}
+		defer parquet.ReadStop()
+
+		rows := make([]parquetPage, parquet.GetNumRows())
+		if err := parquet.Read(&rows); err != nil {
+			t.Fatalf("F38: test case 12 failed, unexpected error: %v", err)
+		}
+		if len(rows) != len(pages) {
+			t.Fatalf("F38: test case 12 failed, %d != %d", len(rows), len(pages))
+		}
+		for i, row := range rows {
+			page := pages[i]
+			if row.URL != page.Url || row.Content != page.Content || row.Simhash != page.Simhash || row.UpdatedAt != page.UpdatedAt.UnixMilli() {
+				t.Errorf("F38: test case 12 failed, %v != %v", row, page)
+			}
+			if (row.DuplicateOf != nil) != page.DuplicateOf.Valid || (row.DuplicateOf != nil && *row.DuplicateOf != page.DuplicateOf.String) {
+				t.Errorf("F38: test case 12 failed, duplicate_of %v != %v", row.DuplicateOf, page.DuplicateOf)
+			}
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "pages")
+		export(t, "markdown", out)
+
+		doc, err := os.ReadFile(filepath.Join(out, markdownName(pages[1].Url)))
+		if err != nil {
+			t.Fatalf("F38: test case 13 failed, unexpected error: %v", err)
+		}
+		expected := strings.Join([]string{
+			"---",
+			`url: "https://www.google.com/b"`,
+			`content_type: "text/plain"`,
+			`language: ""`,
+			`duplicate_of: "https://www.google.com/a"`,
+			"created_at: 2025-01-01T00:00:00Z",
+			"updated_at: 2025-01-01T01:00:00Z",
+			"---",
+			"",
+			"b",
+			"",
+		}, "\n")
+		if string(doc) != expected {
+			t.Errorf("F38: test case 13 failed, %q != %q", doc, expected)
+		}
+
+		entries, err := os.ReadDir(out)
+		if err != nil || len(entries) != len(pages) {
+			t.Errorf("F38: test case 13 failed, %d files != %d, error %v", len(entries), len(pages), err)
+		}
+		if _, err := NewExporter("markdown", "-"); err == nil {
+			t.Errorf("F38: test case 14 failed, expected an error exporting markdown to stdout")
+		}
+	})
+
+	if _, err := NewExporter("xml", ""); err == nil {
+		t.Errorf("F38: test case 15 failed, expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,108 @@
+package src
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/junwei890/crawler/utils"
+)
+
+// FeedPoller polls feeds every interval for entries it hasn't seen. Entries
+// are marked seen in a Visited under a feed: key, so a persisted visited set
+// remembers them across runs.
+type FeedPoller struct {
+	mu       sync.Mutex
+	fetcher  Fetcher
+	logger   Logger
+	interval time.Duration
+	feeds    map[string]time.Time
+	seen     utils.Visited
+}
+
+func NewFeedPoller(fetcher Fetcher, interval time.Duration, seen utils.Visited, logger Logger) *FeedPoller {
+	return &FeedPoller{
+		fetcher:  fetcher,
+		logger:   logger,
+		interval: interval,
+		feeds:    map[string]time.Time{},
+		seen:     seen,
+	}
+}
+
+func (p *FeedPoller) Add(feedURL string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.feeds[feedURL]; !ok {
+		p.feeds[feedURL] = time.Time{}
+	}
+}
+
+func (p *FeedPoller) Due(now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, polled := range p.feeds {
+		if now.Sub(polled) >= p.interval {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns when the next feed is due, or false when there are no feeds.
+func (p *FeedPoller) Next() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next, ok := time.Time{}, false
+	for _, polled := range p.feeds {
+		if due := polled.Add(p.interval); !ok || due.Before(next) {
+			next, ok = due, true
+		}
+	}
+
+	return next, ok
+}
+
+// Poll fetches every feed that is due and returns entry links that haven't
+// been seen before.
+func (p *FeedPoller) Poll(now time.Time) []string {
+	p.mu.Lock()
+	due := []string{}
+	for feedURL, polled := range p.feeds {
+		if now.Sub(polled) >= p.interval {
+			due = append(due, feedURL)
+			p.feeds[feedURL] = now
+		}
+	}
+	p.mu.Unlock()
+
+	links := []string{}
+	for _, feedURL := range due {
+		page, err := p.fetcher.Fetch(feedURL)
+		if err != nil {
+			p.logger.Warn("couldn't fetch feed", slog.String("feed", feedURL), slog.String("error", err.Error()))
+			continue
+		}
+
+		feed, err := utils.ParseFeed(page.Body)
+		if err != nil {
+			p.logger.Warn("couldn't parse feed", slog.String("feed", feedURL), slog.String("error", err.Error()))
+			continue
+		}
+
+		p.mu.Lock()
+		for _, entry := range feed.Entries {
+			if entry.Link == "" || !p.seen.Visit("feed:"+entry.Link) {
+				continue
+			}
+			links = append(links, entry.Link)
+		}
+		p.mu.Unlock()
+	}
+
+	return links
+}
@@ -0,0 +1,45 @@
+package src
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/utils"
+)
+
+func TestFeedPoller(t *testing.T) {
+	// Feeds rarely say what charset they're in, and this one's entry title
+	// isn't ASCII.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<?xml version="1.0"?><rss><channel><title>News</title>` +
+			`<item><title>Café opens</title><link>https://www.google.com/1</link></item>` +
+			`<item><title>Second</title><link>https://www.google.com/2</link></item>` +
+			`</channel></rss>`))
+	}))
+	defer server.Close()
+
+	seen := utils.NewSeenSet(1<<10, 0.01, utils.NewMemoryStore())
+	poller := NewFeedPoller(HTTPFetcher{}, time.Minute, seen, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	poller.Add(server.URL + "/feed.xml")
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := []string{"https://www.google.com/1", "https://www.google.com/2"}
+	if links := poller.Poll(now); !slices.Equal(links, expected) {
+		t.Errorf("F49: test case 1 failed, %v != %v", links, expected)
+	}
+
+	if poller.Due(now.Add(time.Second)) {
+		t.Errorf("F49: test case 2 failed, %t != %t", true, false)
+	}
+
+	// Entries already seen aren't returned again.
+	if links := poller.Poll(now.Add(time.Minute)); len(links) != 0 {
+		t.Errorf("F49: test case 3 failed, %v != %v", links, []string{})
+	}
+}
@@ -0,0 +1,191 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+)
+
+// AttemptLog writes the fetch log behind the crawler, inserting attempts a
+// batch at a time in one transaction so that logging one isn't a round trip
+// to the store on the crawl's path. A batch that can't be written is logged
+// and dropped, since the fetch log only explains a crawl.
+type AttemptLog struct {
+	queries store.Store
+	size    int
+	clock   Clock
+	pending chan database.InsertFetchLogParams
+	stopped chan struct{}
+	logger  Logger
+}
+
+// NewAttemptLog buffers up to four batches, after which Add blocks like
+// Batcher.Add does.
+func NewAttemptLog(queries store.Store, size int, interval time.Duration, clock Clock, logger Logger) *AttemptLog {
+	size = max(size, 1)
+
+	l := &AttemptLog{
+		queries: queries,
+		size:    size,
+		clock:   clock,
+		pending: make(chan database.InsertFetchLogParams, size*4),
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+	// The ticker starts now, so the first tick is an interval after this.
+	go l.run(clock.NewTicker(interval))
+
+	return l
+}
+
+// logged reports whether an event gets a fetch log row. Links skipped as out
+// of scope or already visited come one per link found rather than per
+// attempt, so they're only counted in metrics.
+func logged(e Event) bool {
+	return e != EventSkippedScope && e != EventSkippedVisited && e != EventEnqueued
+}
+
+// Add queues an attempt at a URL in run runID for logging.
+func (l *AttemptLog) Add(runID int64, e URLEvent) {
+	if !logged(e.Event) {
+		return
+	}
+
+	host := ""
+	if parsed, err := url.Parse(e.URL); err == nil {
+		host = parsed.Hostname()
+	}
+
+	message := ""
+	if e.Err != nil {
+		message = e.Err.Error()
+	}
+
+	l.pending <- database.InsertFetchLogParams{
+		RunID:       runID,
+		Url:         e.URL,
+		Host:        host,
+		Seed:        e.Seed,
+		Status:      int64(e.Status),
+		ContentType: e.ContentType,
+		Size:        e.Size,
+		LatencyMs:   e.Duration.Milliseconds(),
+		Outcome:     string(e.Event),
+		Error:       message,
+		CreatedAt:   l.clock.Now(),
+	}
+}
+
+// Close writes whatever is still queued and waits for it. Add must not be
+// called after Close.
+func (l *AttemptLog) Close() {
+	close(l.pending)
+	<-l.stopped
+}
+
+func (l *AttemptLog) run(ticker Ticker) {
+	defer close(l.stopped)
+	defer ticker.Stop()
+
+	batch := make([]database.InsertFetchLogParams, 0, l.size)
+	for {
+		select {
+		case attempt, ok := <-l.pending:
+			if !ok {
+				l.flush(batch)
+				return
+			}
+			batch = append(batch, attempt)
+			if len(batch) >= l.size {
+				l.flush(batch)
+				batch = make([]database.InsertFetchLogParams, 0, l.size)
+			}
+		case <-ticker.C():
+			if len(batch) > 0 {
+				l.flush(batch)
+				batch = make([]database.InsertFetchLogParams, 0, l.size)
+			}
+		}
+	}
+}
+
+func (l *AttemptLog) flush(batch []database.InsertFetchLogParams) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := store.WithTx(context.TODO(), l.queries, func(w store.PageWriter) error {
+		for _, attempt := range batch {
+			if err := w.InsertFetchLog(context.TODO(), attempt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		l.logger.Error("couldn't write fetch log", slog.Int("attempts", len(batch)), slog.String("error", err.Error()))
+	}
+}
+
+// Failures prints, per host, how many attempts ended in each outcome other
+// than stored, followed by the 4xx and 5xx status codes seen.
+func Failures(queries store.Store, w io.Writer) error {
+	outcomes, err := queries.ListHostFailures(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	statuses, err := queries.ListHostErrorStatuses(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "host\toutcome\tattempts")
+	for _, row := range outcomes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", row.Host, row.Outcome, row.Attempts)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "host\tstatus\tattempts")
+	for _, row := range statuses {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", row.Host, row.Status, row.Attempts)
+	}
+
+	return tw.Flush()
+}
+
+// Attempts prints every logged attempt at rawURL, oldest first.
+func Attempts(queries store.Store, w io.Writer, rawURL string) error {
+	rows, err := queries.ListFetchAttempts(context.TODO(), rawURL)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "%s was never attempted\n", rawURL)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "time\trun\toutcome\tstatus\tcontent type\tsize\tlatency\terror")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%d\t%s\t%s\n",
+			row.CreatedAt.Format(time.RFC3339),
+			row.RunID,
+			row.Outcome,
+			row.Status,
+			row.ContentType,
+			row.Size,
+			time.Duration(row.LatencyMs)*time.Millisecond,
+			row.Error,
+		)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,167 @@
+package src
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+// storeQueueLease is how long a URL dequeued from a StoreQueue stays hidden
+// without being acked. It only matters once the process holding it is gone,
+// so it's long enough to outlast crawling any one URL, at the cost of a
+// resumed crawl waiting that long for the URLs in flight when it stopped.
+const storeQueueLease = 10 * time.Minute
+
+// StoreQueue is a frontier kept in a Store under a queue name, so a crawl
+// stopped partway resumes with the URLs it had queued. URLs come out by the
+// sum of its scorers, highest first, and a dequeued URL stays leased until
+// it's acked.
+type StoreQueue struct {
+	queries  store.Store
+	name     string
+	owner    string
+	scorers  []utils.Scorer
+	now      func() time.Time
+	inFlight map[string]bool
+	err      error
+}
+
+var _ utils.AckQueueOps = (*StoreQueue)(nil)
+
+func NewStoreQueue(queries store.Store, name string, clock Clock, scorers ...utils.Scorer) *StoreQueue {
+	return &StoreQueue{
+		queries:  queries,
+		name:     name,
+		owner:    rand.Text(),
+		scorers:  scorers,
+		now:      clock.Now,
+		inFlight: map[string]bool{},
+	}
+}
+
+func (q *StoreQueue) Enqueue(url string) {
+	q.EnqueueWith(utils.URLInfo{URL: url})
+}
+
+// EnqueueWith adds a URL unless it's already queued. A URL dequeued and not
+// yet acked is put back, as when a coordinator requeues an expired lease.
+// Write errors surface from the next dequeue since QueueOps gives Enqueue no
+// error to return.
+func (q *StoreQueue) EnqueueWith(info utils.URLInfo) {
+	if q.inFlight[info.URL] {
+		if err := q.Ack(info.URL); err != nil {
+			q.err = err
+			return
+		}
+	}
+
+	priority := info.Boost
+	for _, scorer := range q.scorers {
+		priority += scorer(info)
+	}
+
+	err := q.queries.EnqueueFrontier(context.TODO(), database.EnqueueFrontierParams{
+		Queue:    q.name,
+		Url:      info.URL,
+		Depth:    int64(info.Depth),
+		Priority: priority,
+	})
+	if err != nil {
+		q.err = err
+	}
+}
+
+func (q *StoreQueue) DequeueInfo() (utils.URLInfo, error) {
+	if q.err != nil {
+		return utils.URLInfo{}, q.err
+	}
+
+	now := q.now()
+	rows, err := q.queries.LeaseFrontier(context.TODO(), database.LeaseFrontierParams{
+		LeaseOwner:  q.owner,
+		LeasedUntil: now.Add(storeQueueLease).UnixMilli(),
+		Queue:       q.name,
+		Now:         now.UnixMilli(),
+		Limit:       1,
+	})
+	if err != nil {
+		return utils.URLInfo{}, err
+	}
+	if len(rows) == 0 {
+		return utils.URLInfo{}, errors.New("queue empty")
+	}
+	q.inFlight[rows[0].Url] = true
+
+	return utils.URLInfo{URL: rows[0].Url, Depth: int(rows[0].Depth)}, nil
+}
+
+func (q *StoreQueue) Dequeue() (string, error) {
+	info, err := q.DequeueInfo()
+	return info.URL, err
+}
+
+// Ack removes a dequeued URL from the store once it has been crawled.
+func (q *StoreQueue) Ack(url string) error {
+	delete(q.inFlight, url)
+
+	return q.queries.AckFrontier(context.TODO(), database.AckFrontierParams{
+		Queue:      q.name,
+		Url:        url,
+		LeaseOwner: q.owner,
+	})
+}
+
+func (q *StoreQueue) Peek() (string, error) {
+	top := q.Top(1)
+	if q.err != nil {
+		return "", q.err
+	}
+	if len(top) == 0 {
+		return "", errors.New("queue empty")
+	}
+
+	return top[0].URL, nil
+}
+
+// Top returns up to n queued URLs in the order they'd be dequeued.
+func (q *StoreQueue) Top(n int) []utils.URLInfo {
+	rows, err := q.queries.ListFrontier(context.TODO(), database.ListFrontierParams{
+		Queue: q.name,
+		Now:   q.now().UnixMilli(),
+		Limit: int64(n),
+	})
+	if err != nil {
+		q.err = err
+	}
+
+	top := []utils.URLInfo{}
+	for _, row := range rows {
+		top = append(top, utils.URLInfo{URL: row.Url, Depth: int(row.Depth)})
+	}
+
+	return top
+}
+
+func (q *StoreQueue) Empty() bool {
+	return q.Size() == 0
+}
+
+// Size counts the URLs waiting to be dequeued. A store that can't be read
+// counts as one URL, so the error surfaces from dequeuing it.
+func (q *StoreQueue) Size() int {
+	count, err := q.queries.CountFrontier(context.TODO(), database.CountFrontierParams{
+		Queue: q.name,
+		Now:   q.now().UnixMilli(),
+	})
+	if err != nil {
+		q.err = err
+		return 1
+	}
+
+	return int(count)
+}
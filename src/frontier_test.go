@@ -0,0 +1,93 @@
+package src
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+func TestStoreQueue(t *testing.T) {
+	dir := t.TempDir()
+	queries, err := store.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	queue := NewStoreQueue(queries, "https://www.google.com/", clock, utils.SitemapScorer(1))
+
+	queue.Enqueue("https://www.google.com/a")
+	queue.EnqueueWith(utils.URLInfo{URL: "https://www.google.com/b", Depth: 1, SitemapPriority: 0.9})
+	queue.Enqueue("https://www.google.com/c")
+	queue.Enqueue("https://www.google.com/a")
+	if queue.Size() != 3 {
+		t.Errorf("F34: test case 1 failed, %v != %v", queue.Size(), 3)
+	}
+
+	top := queue.Top(2)
+	expectedTop := []utils.URLInfo{{URL: "https://www.google.com/b", Depth: 1}, {URL: "https://www.google.com/a"}}
+	if !slices.Equal(top, expectedTop) {
+		t.Errorf("F34: test case 2 failed, %v != %v", top, expectedTop)
+	}
+
+	info, err := queue.DequeueInfo()
+	if err != nil || info != expectedTop[0] {
+		t.Errorf("F34: test case 3 failed, %v != %v, error %v", info, expectedTop[0], err)
+	}
+	if err := queue.Ack(info.URL); err != nil {
+		t.Fatalf("F34: test case 3 failed, unexpected error: %v", err)
+	}
+
+	// A URL dequeued without an ack is held back from a resumed crawl until
+	// its lease runs out.
+	if url, err := queue.Dequeue(); err != nil || url != "https://www.google.com/a" {
+		t.Errorf("F34: test case 4 failed, %v != %v, error %v", url, "https://www.google.com/a", err)
+	}
+	if err := queries.Close(); err != nil {
+		t.Fatalf("F34: test case 4 failed, unexpected error: %v", err)
+	}
+	queries, err = store.OpenFileStore(dir)
+	if err != nil {
+		t.Fatalf("F34: test case 4 failed, unexpected error: %v", err)
+	}
+	defer queries.Close()
+
+	resumed := NewStoreQueue(queries, "https://www.google.com/", clock)
+	if url, err := resumed.Peek(); err != nil || url != "https://www.google.com/c" || resumed.Size() != 1 {
+		t.Errorf("F34: test case 5 failed, %v != %v, size %v, error %v", url, "https://www.google.com/c", resumed.Size(), err)
+	}
+
+	clock.advance(storeQueueLease)
+	if resumed.Size() != 2 {
+		t.Errorf("F34: test case 6 failed, %v != %v", resumed.Size(), 2)
+	}
+
+	// Putting back a URL still in flight makes it available straight away.
+	url, err := resumed.Dequeue()
+	if err != nil {
+		t.Fatalf("F34: test case 7 failed, unexpected error: %v", err)
+	}
+	resumed.Enqueue(url)
+	if resumed.Size() != 2 {
+		t.Errorf("F34: test case 7 failed, %v != %v", resumed.Size(), 2)
+	}
+
+	for range 2 {
+		url, err := resumed.Dequeue()
+		if err != nil {
+			t.Fatalf("F34: test case 8 failed, unexpected error: %v", err)
+		}
+		if err := resumed.Ack(url); err != nil {
+			t.Fatalf("F34: test case 8 failed, unexpected error: %v", err)
+		}
+	}
+	if !resumed.Empty() {
+		t.Errorf("F34: test case 8 failed, %v != %v", resumed.Size(), 0)
+	}
+	if _, err := resumed.Dequeue(); err == nil {
+		t.Errorf("F34: test case 9 failed, expected an error dequeuing from an empty queue")
+	}
+}
@@ -0,0 +1,117 @@
+package src
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Metrics struct {
+	Events           *prometheus.CounterVec
+	Fetches          prometheus.Counter
+	StatusCodes      *prometheus.CounterVec
+	FetchLatency     prometheus.Histogram
+	WireBytes        prometheus.Counter
+	DecodedBytes     prometheus.Counter
+	QueueDepth       *prometheus.GaugeVec
+	DelayWait        *prometheus.CounterVec
+	InsertLatency    prometheus.Histogram
+	StoreRetries     prometheus.Counter
+	BackpressureWait prometheus.Counter
+}
+
+// NewMetrics registers the crawler's collectors with registerer. A nil
+// registerer leaves them unregistered, which is what Init does when metrics
+// aren't served.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "crawler_url_events_total",
+			Help: "URL decisions made by the crawler, by event.",
+		}, []string{"event"}),
+		Fetches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "crawler_pages_fetched_total",
+			Help: "Pages fetched successfully.",
+		}),
+		StatusCodes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "crawler_http_responses_total",
+			Help: "HTTP responses received, by status code.",
+		}, []string{"code"}),
+		FetchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "crawler_fetch_duration_seconds",
+			Help:    "Time taken to fetch and decode a page.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}),
+		WireBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "crawler_wire_bytes_total",
+			Help: "Bytes downloaded before decompression.",
+		}),
+		DecodedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "crawler_decoded_bytes_total",
+			Help: "Bytes downloaded after decompression.",
+		}),
+		QueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "crawler_queue_depth",
+			Help: "URLs waiting in each seed's frontier.",
+		}, []string{"seed"}),
+		DelayWait: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "crawler_delay_wait_seconds_total",
+			Help: "Time spent sleeping for robots.txt crawl delays, by host.",
+		}, []string{"host"}),
+		InsertLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "crawler_db_insert_duration_seconds",
+			Help:    "Time taken to write a batch of pages to the database.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		}),
+		StoreRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "crawler_db_batch_retries_total",
+			Help: "Batch writes retried after an error.",
+		}),
+		BackpressureWait: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "crawler_db_backpressure_seconds_total",
+			Help: "Time fetch workers spent blocked waiting for the database to catch up.",
+		}),
+	}
+
+	if registerer != nil {
+		registerer.MustRegister(
+			m.Events,
+			m.Fetches,
+			m.StatusCodes,
+			m.FetchLatency,
+			m.WireBytes,
+			m.DecodedBytes,
+			m.QueueDepth,
+			m.DelayWait,
+			m.InsertLatency,
+			m.StoreRetries,
+			m.BackpressureWait,
+		)
+	}
+
+	return m
+}
+
+// RegisterVisited exposes the size of visited when it can report one, as
+// SeenSet does.
+func RegisterVisited(registerer prometheus.Registerer, visited any) {
+	sized, ok := visited.(interface{ Size() int })
+	if !ok {
+		return
+	}
+
+	registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "crawler_visited_urls",
+		Help: "URLs in the shared visited set.",
+	}, func() float64 {
+		return float64(sized.Size())
+	}))
+}
+
+func (m *Metrics) observeFetch(status int, duration time.Duration) {
+	if status != 0 {
+		m.StatusCodes.WithLabelValues(strconv.Itoa(status)).Inc()
+	}
+	m.FetchLatency.Observe(duration.Seconds())
+}
@@ -0,0 +1,94 @@
+package src
+
+import (
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/fakeweb"
+	"github.com/junwei890/crawler/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	pages := map[string]string{
+		"http://fake.test/":  fakeweb.Render("/", fakeweb.Page{Links: []string{"/a", "/missing"}}),
+		"http://fake.test/a": fakeweb.Render("/a", fakeweb.Page{}),
+	}
+	fetcher := &fakeFetcher{clock: clock, pages: map[string]func(time.Time) (string, string){}}
+	decoded := 0
+	for url, body := range pages {
+		fetcher.pages[url] = func(time.Time) (string, string) {
+			return "text/html", body
+		}
+		decoded += len(body)
+	}
+
+	registry := prometheus.NewRegistry()
+	cfg := testConfig()
+	cfg.Metrics = NewMetrics(registry)
+	RegisterVisited(registry, cfg.Visited)
+
+	controller, err := NewController(memoryStore(t), cfg, WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	if _, err := controller.Submit(utils.Seed{URL: "http://fake.test/"}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	controller.Wait()
+	if err := controller.Close(); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		collector prometheus.Collector
+		expected  float64
+	}{
+		{name: "F46: test case 1", collector: cfg.Metrics.Fetches, expected: 2},
+		{name: "F46: test case 2", collector: cfg.Metrics.StatusCodes.WithLabelValues("200"), expected: 2},
+		{name: "F46: test case 3", collector: cfg.Metrics.StatusCodes.WithLabelValues("404"), expected: 1},
+		{name: "F46: test case 4", collector: cfg.Metrics.Events.WithLabelValues(string(EventEnqueued)), expected: 3},
+		{name: "F46: test case 5", collector: cfg.Metrics.Events.WithLabelValues(string(EventStored)), expected: 2},
+		{name: "F46: test case 6", collector: cfg.Metrics.Events.WithLabelValues(string(EventFetchError)), expected: 1},
+		{name: "F46: test case 7", collector: cfg.Metrics.DecodedBytes, expected: float64(decoded)},
+		{name: "F46: test case 8", collector: cfg.Metrics.DelayWait.WithLabelValues("fake.test"), expected: 2 * 30},
+	}
+	for _, testCase := range testCases {
+		if value := testutil.ToFloat64(testCase.collector); value != testCase.expected {
+			t.Errorf("%s failed, %v != %v", testCase.name, value, testCase.expected)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("F46: test case 9 failed, unexpected error: %v", err)
+	}
+	observed := map[string]uint64{}
+	gauges := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if histogram := metric.GetHistogram(); histogram != nil {
+				observed[family.GetName()] += histogram.GetSampleCount()
+			}
+			if gauge := metric.GetGauge(); gauge != nil {
+				gauges[family.GetName()] = gauge.GetValue()
+			}
+		}
+	}
+	if count := observed["crawler_fetch_duration_seconds"]; count != 3 {
+		t.Errorf("F46: test case 9 failed, %v != %v fetches timed", count, 3)
+	}
+	if count := observed["crawler_db_insert_duration_seconds"]; count == 0 {
+		t.Errorf("F46: test case 10 failed, no batch writes timed")
+	}
+	if visited := gauges["crawler_visited_urls"]; visited != 3 {
+		t.Errorf("F46: test case 11 failed, %v != %v", visited, 3)
+	}
+	// A finished seed's queue depth is dropped rather than left at zero.
+	if count := testutil.CollectAndCount(cfg.Metrics.QueueDepth); count != 0 {
+		t.Errorf("F46: test case 12 failed, %v != %v", count, 0)
+	}
+}
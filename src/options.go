@@ -0,0 +1,196 @@
+package src
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+// Fetcher fetches a URL, whether a page, a sitemap or a feed. Only pages
+// whose Raw a Fetcher sets are kept in Config.Blobs.
+type Fetcher interface {
+	Fetch(rawURL string) (utils.Page, error)
+}
+
+// RobotsProvider returns the robots.txt of the site a seed is on, empty when
+// the site has none.
+type RobotsProvider interface {
+	Robots(seedURL string) ([]byte, error)
+}
+
+// Clock is the time a crawl sees, deciding when feeds are due, waiting out
+// crawl delays and ticking periodic writes and lease renewals.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker ticks every period of the Clock it came from.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Logger is what a crawl logs through. *slog.Logger satisfies it.
+type Logger interface {
+	Enabled(ctx context.Context, level slog.Level) bool
+	LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// withAttrs returns a logger adding attrs to everything logger logs. slog's
+// own With returns a *slog.Logger, so it can't be part of Logger.
+func withAttrs(logger Logger, attrs ...slog.Attr) Logger {
+	if l, ok := logger.(*slog.Logger); ok {
+		args := []any{}
+		for _, attr := range attrs {
+			args = append(args, attr)
+		}
+		return l.With(args...)
+	}
+
+	return attrLogger{Logger: logger, attrs: attrs}
+}
+
+type attrLogger struct {
+	Logger
+	attrs []slog.Attr
+}
+
+func (l attrLogger) LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	l.Logger.LogAttrs(ctx, level, msg, append(l.attrs[:len(l.attrs):len(l.attrs)], attrs...)...)
+}
+
+func (l attrLogger) Info(msg string, args ...any) {
+	l.Logger.Info(msg, l.args(args)...)
+}
+
+func (l attrLogger) Warn(msg string, args ...any) {
+	l.Logger.Warn(msg, l.args(args)...)
+}
+
+func (l attrLogger) Error(msg string, args ...any) {
+	l.Logger.Error(msg, l.args(args)...)
+}
+
+func (l attrLogger) args(args []any) []any {
+	all := []any{}
+	for _, attr := range l.attrs {
+		all = append(all, attr)
+	}
+
+	return append(all, args...)
+}
+
+// HTTPFetcher fetches over the network, archiving every page fetched to
+// Archive when it isn't nil and keeping its raw response when KeepRaw is set.
+type HTTPFetcher struct {
+	Archive *utils.WARCWriter
+	KeepRaw bool
+}
+
+func (f HTTPFetcher) Fetch(rawURL string) (utils.Page, error) {
+	if f.KeepRaw {
+		return utils.FetchRaw(rawURL, f.Archive)
+	}
+
+	return utils.FetchArchived(rawURL, f.Archive)
+}
+
+// HTTPRobots fetches robots.txt from the seed's site.
+type HTTPRobots struct{}
+
+func (HTTPRobots) Robots(seedURL string) ([]byte, error) {
+	return utils.GetRobots(seedURL)
+}
+
+// SystemClock is the real time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Crawler crawls seeds with its dependencies injected, defaulting to the
+// network, the system clock and cfg.Logger.
+type Crawler struct {
+	queries store.Store
+	cfg     Config
+	fetcher Fetcher
+	// plain fetches sitemaps and feeds, which are only worth archiving when
+	// a custom Fetcher says so.
+	plain  Fetcher
+	robots RobotsProvider
+	clock  Clock
+}
+
+type Option func(*Crawler)
+
+// WithFetcher fetches pages, sitemaps and feeds with f.
+func WithFetcher(f Fetcher) Option {
+	return func(c *Crawler) {
+		c.fetcher = f
+		c.plain = f
+	}
+}
+
+func WithRobots(r RobotsProvider) Option {
+	return func(c *Crawler) {
+		c.robots = r
+	}
+}
+
+func WithClock(clock Clock) Option {
+	return func(c *Crawler) {
+		c.clock = clock
+	}
+}
+
+func WithLogger(logger Logger) Option {
+	return func(c *Crawler) {
+		c.cfg.Logger = logger
+	}
+}
+
+// NewCrawler returns a crawler storing pages in queries.
+func NewCrawler(queries store.Store, cfg Config, opts ...Option) *Crawler {
+	c := &Crawler{
+		queries: queries,
+		cfg:     cfg,
+		fetcher: HTTPFetcher{Archive: cfg.Archive, KeepRaw: cfg.Blobs != nil},
+		plain:   HTTPFetcher{},
+		robots:  HTTPRobots{},
+		clock:   SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.cfg.setDefaults()
+	if c.cfg.Archive != nil {
+		c.cfg.Archive.SetNow(c.clock.Now)
+	}
+
+	return c
+}
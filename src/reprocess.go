@@ -0,0 +1,263 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+// Reprocess re-runs extraction over the response records in WARC files, or
+// in the WARC files of a directory, storing the pages again without touching
+// the network. Seed language filters aren't applied. opts inject the clock
+// and logger.
+func Reprocess(queries store.Store, cfg Config, paths []string, w io.Writer, opts ...Option) error {
+	deps := NewCrawler(queries, cfg, opts...)
+	cfg = deps.cfg
+
+	files, err := warcFiles(paths)
+	if err != nil {
+		return err
+	}
+
+	if err := loadFingerprints(queries, cfg); err != nil {
+		return err
+	}
+
+	batcher := NewBatcher(queries, cfg.BatchSize, cfg.BatchInterval, deps.clock, cfg.Metrics, cfg.Logger)
+
+	records, skipped := 0, 0
+	stored, failed := atomic.Int64{}, atomic.Int64{}
+
+	for _, path := range files {
+		err := readWARC(path, func(record utils.WARCRecord) {
+			if record.Type() != "response" {
+				return
+			}
+			records++
+
+			event := URLEvent{URL: record.TargetURI()}
+			emit := func(e Event, err error) {
+				event.Event = e
+				event.Err = err
+				logEvent(cfg.Logger, event)
+			}
+
+			row, e, err := reprocessRecord(record, cfg, deps.clock.Now())
+			if err != nil {
+				skipped++
+				emit(EventParseError, err)
+				return
+			}
+			if e != "" {
+				skipped++
+				emit(e, nil)
+				return
+			}
+
+			batcher.Add(row, func(err error) {
+				if err != nil {
+					failed.Add(1)
+					emit(EventStoreError, err)
+					return
+				}
+				stored.Add(1)
+				indexFingerprint(cfg, row)
+				emit(EventStored, nil)
+			})
+		})
+		if err != nil {
+			batcher.Close()
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	batcher.Close()
+
+	fmt.Fprintf(w, "reprocessed %d responses from %d files: %d stored, %d skipped, %d failed to store\n", records, len(files), stored.Load(), skipped, failed.Load())
+
+	return nil
+}
+
+// Reextract re-runs extraction over the raw bytes kept in cfg.Blobs for every
+// stored page that has them, storing the results again. opts inject the
+// clock and logger.
+func Reextract(queries store.Store, cfg Config, w io.Writer, opts ...Option) error {
+	deps := NewCrawler(queries, cfg, opts...)
+	cfg = deps.cfg
+	if cfg.Blobs == nil {
+		return errors.New("no blob directory to reextract from")
+	}
+
+	pages, err := queries.ListRawPages(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	if err := loadFingerprints(queries, cfg); err != nil {
+		return err
+	}
+
+	batcher := NewBatcher(queries, cfg.BatchSize, cfg.BatchInterval, deps.clock, cfg.Metrics, cfg.Logger)
+
+	skipped := 0
+	stored, failed := atomic.Int64{}, atomic.Int64{}
+
+	for _, raw := range pages {
+		event := URLEvent{URL: raw.Url, ContentType: raw.ContentType}
+		emit := func(e Event, err error) {
+			event.Event = e
+			event.Err = err
+			logEvent(cfg.Logger, event)
+		}
+
+		row, e, err := reextractPage(raw, cfg, deps.clock.Now())
+		if err != nil {
+			skipped++
+			emit(EventParseError, err)
+			continue
+		}
+		if e != "" {
+			skipped++
+			emit(e, nil)
+			continue
+		}
+
+		batcher.Add(row, func(err error) {
+			if err != nil {
+				failed.Add(1)
+				emit(EventStoreError, err)
+				return
+			}
+			stored.Add(1)
+			indexFingerprint(cfg, row)
+			emit(EventStored, nil)
+		})
+	}
+	batcher.Close()
+
+	fmt.Fprintf(w, "reextracted %d pages: %d stored, %d skipped, %d failed to store\n", len(pages), stored.Load(), skipped, failed.Load())
+
+	return nil
+}
+
+func reextractPage(raw database.ListRawPagesRow, cfg Config, now time.Time) (database.InsertDataParams, Event, error) {
+	blob, err := cfg.Blobs.Get(raw.RawHash)
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+
+	page, err := utils.ReadRawPage(blob)
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+
+	row, skipped, err := extractRow(raw.Url, page, cfg, now)
+	row.RawHash = raw.RawHash
+
+	return row, skipped, err
+}
+
+func reprocessRecord(record utils.WARCRecord, cfg Config, now time.Time) (database.InsertDataParams, Event, error) {
+	res, err := record.Response()
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+	defer res.Body.Close()
+
+	page, err := utils.ReadPage(res)
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+
+	row, skipped, err := extractRow(record.TargetURI(), page, cfg, now)
+	if err != nil || skipped != "" || cfg.Blobs == nil {
+		return row, skipped, err
+	}
+
+	// The record's block is the response as it came off the wire.
+	row.RawHash, err = cfg.Blobs.Put(record.Block)
+
+	return row, skipped, err
+}
+
+// extractRow extracts a page that didn't come from a crawl, so there are no
+// links to follow and no seed whose filters apply.
+func extractRow(rawURL string, page utils.Page, cfg Config, now time.Time) (database.InsertDataParams, Event, error) {
+	dom, err := url.Parse(rawURL)
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+
+	doc, err := extract(dom, page)
+	if err != nil {
+		return database.InsertDataParams{}, "", err
+	}
+
+	row, skipped, _ := buildRow(rawURL, page, doc, utils.Seed{}, cfg, now)
+
+	return row, skipped, nil
+}
+
+func warcFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return []string{}, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return []string{}, err
+		}
+		for _, entry := range entries {
+			if name := entry.Name(); strings.HasSuffix(name, ".warc") || strings.HasSuffix(name, ".warc.gz") {
+				files = append(files, filepath.Join(path, name))
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return []string{}, errors.New("no warc files to reprocess")
+	}
+
+	return files, nil
+}
+
+func readWARC(path string, apply func(utils.WARCRecord)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := utils.NewWARCReader(file)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		apply(record)
+	}
+}
@@ -0,0 +1,199 @@
+package src
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/utils"
+)
+
+// article is a page long enough to be stored, its paragraph repeating text.
+func article(lang, text string) string {
+	return `<html lang="` + lang + `"><body><p>` + strings.Repeat(text+" ", 40) + `</p></body></html>`
+}
+
+func TestReprocess(t *testing.T) {
+	pages := map[string]string{
+		"/a":     article("en", "The first archived page talks about rivers."),
+		"/b":     article("en", "The second archived page talks about mountains and valleys."),
+		"/copy":  article("en", "The first archived page talks about rivers."),
+		"/short": "<html><body><p>Too short.</p></body></html>",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	// The copy is archived on its own, since a page is only matched against
+	// originals that have already been stored.
+	dir, copyDir := t.TempDir(), t.TempDir()
+	for archiveDir, paths := range map[string][]string{dir: {"/a", "/b", "/short"}, copyDir: {"/copy"}} {
+		archive, err := utils.NewWARCWriter(archiveDir, "test", 1<<30)
+		if err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		for _, path := range paths {
+			if _, err := utils.FetchArchived(server.URL+path, archive); err != nil {
+				t.Fatalf("error setting up test, unexpected error: %v", err)
+			}
+		}
+		if err := archive.Close(); err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+	}
+
+	queries := memoryStore(t)
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	out := &bytes.Buffer{}
+	if err := Reprocess(queries, testConfig(), []string{dir}, out, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 1 failed, unexpected error: %v", err)
+	}
+	if err := Reprocess(queries, testConfig(), []string{copyDir}, out, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 1 failed, unexpected error: %v", err)
+	}
+	if expected := "reprocessed 3 responses from 1 files: 2 stored, 1 skipped, 0 failed to store\nreprocessed 1 responses from 1 files: 1 stored, 0 skipped, 0 failed to store\n"; out.String() != expected {
+		t.Errorf("F42: test case 1 failed, %q != %q", out.String(), expected)
+	}
+
+	expected := []string{server.URL + "/a", server.URL + "/b", server.URL + "/copy"}
+	if urls := stored(t, queries); !slices.Equal(urls, expected) {
+		t.Fatalf("F42: test case 2 failed, %v != %v", urls, expected)
+	}
+
+	a, err := queries.GetPage(context.Background(), server.URL+"/a")
+	if err != nil {
+		t.Fatalf("F42: test case 3 failed, unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(a.Content, "The first archived page talks about rivers.") || a.Language != "en" || a.ContentType != "text/html" {
+		t.Errorf("F42: test case 3 failed, unexpected row %+v", a)
+	}
+	if !a.CreatedAt.Equal(clock.now) {
+		t.Errorf("F42: test case 4 failed, %v != %v", a.CreatedAt, clock.now)
+	}
+
+	copied, err := queries.GetPage(context.Background(), server.URL+"/copy")
+	if err != nil {
+		t.Fatalf("F42: test case 5 failed, unexpected error: %v", err)
+	}
+	if !copied.DuplicateOf.Valid || copied.DuplicateOf.String != server.URL+"/a" {
+		t.Errorf("F42: test case 5 failed, %v != %v", copied.DuplicateOf, server.URL+"/a")
+	}
+
+	// Reprocessing a page again doesn't make it a duplicate of itself.
+	cfg := testConfig()
+	if err := Reprocess(queries, cfg, []string{dir}, &bytes.Buffer{}, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 6 failed, unexpected error: %v", err)
+	}
+	if err := Reprocess(queries, cfg, []string{dir}, &bytes.Buffer{}, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 6 failed, unexpected error: %v", err)
+	}
+	if a, err := queries.GetPage(context.Background(), server.URL+"/a"); err != nil || a.DuplicateOf.Valid {
+		t.Errorf("F42: test case 6 failed, %v != %v, unexpected error: %v", a.DuplicateOf, sql.NullString{}, err)
+	}
+
+	// A page that failed to store isn't an original for later pages.
+	cfg = testConfig()
+	flaky := &flakyStore{Store: memoryStore(t), bad: server.URL + "/a"}
+	if err := Reprocess(flaky, cfg, []string{dir}, &bytes.Buffer{}, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 7 failed, unexpected error: %v", err)
+	}
+	if err := Reprocess(flaky, cfg, []string{copyDir}, &bytes.Buffer{}, WithClock(clock)); err != nil {
+		t.Fatalf("F42: test case 7 failed, unexpected error: %v", err)
+	}
+	if copied, err := flaky.GetPage(context.Background(), server.URL+"/copy"); err != nil || copied.DuplicateOf.Valid {
+		t.Errorf("F42: test case 7 failed, %v != %v, unexpected error: %v", copied.DuplicateOf, sql.NullString{}, err)
+	}
+}
+
+func TestReextract(t *testing.T) {
+	// Nothing in the text says what language it's in, so only the
+	// Content-Language header can.
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	writer.Write([]byte(`<html><body><p>` + strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 20) + `</p></body></html>`))
+	writer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Language", "de")
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	blobs, err := utils.NewBlobDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	cfg.Blobs = blobs
+
+	queries := memoryStore(t)
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: created}
+	controller, err := NewController(queries, cfg, WithClock(clock))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	if _, err := controller.Submit(utils.Seed{URL: server.URL + "/"}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	controller.Wait()
+	if err := controller.Close(); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	crawled, err := queries.GetPage(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("F43: test case 1 failed, unexpected error: %v", err)
+	}
+	raw, err := blobs.Get(crawled.RawHash)
+	if err != nil {
+		t.Fatalf("F43: test case 1 failed, unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(raw, []byte("HTTP/1.1 200 OK\r\n")) || !bytes.Contains(raw, []byte("Content-Language: de\r\n")) || !bytes.HasSuffix(raw, compressed.Bytes()) {
+		t.Errorf("F43: test case 1 failed, %q isn't the response as it came off the wire", raw)
+	}
+	if crawled.Language != "de" {
+		t.Errorf("F43: test case 2 failed, %v != %v", crawled.Language, "de")
+	}
+
+	// Reextracting goes by the kept headers, and the page keeps when it was
+	// first stored.
+	if _, err := queries.InsertData(context.Background(), database.InsertDataParams{Url: crawled.Url, Content: "stale", ContentType: crawled.ContentType, RawHash: crawled.RawHash, CreatedAt: created, UpdatedAt: created}); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	clock.advance(time.Hour)
+	out := &bytes.Buffer{}
+	if err := Reextract(queries, cfg, out, WithClock(clock)); err != nil {
+		t.Fatalf("F43: test case 3 failed, unexpected error: %v", err)
+	}
+	if expected := "reextracted 1 pages: 1 stored, 0 skipped, 0 failed to store\n"; out.String() != expected {
+		t.Errorf("F43: test case 3 failed, %q != %q", out.String(), expected)
+	}
+
+	page, err := queries.GetPage(context.Background(), crawled.Url)
+	if err != nil {
+		t.Fatalf("F43: test case 4 failed, unexpected error: %v", err)
+	}
+	if page.Content != crawled.Content || page.Language != "de" || page.RawHash != crawled.RawHash {
+		t.Errorf("F43: test case 4 failed, %+v != %+v", page, crawled)
+	}
+	if !page.CreatedAt.Equal(created) || !page.UpdatedAt.Equal(clock.now) {
+		t.Errorf("F43: test case 5 failed, created %v updated %v, expected %v and %v", page.CreatedAt, page.UpdatedAt, created, clock.now)
+	}
+}
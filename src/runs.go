@@ -0,0 +1,144 @@
+package src
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+	"github.com/junwei890/crawler/internal/store"
+)
+
+const (
+	RunRunning     = "running"
+	RunCompleted   = "completed"
+	RunFailed      = "failed"
+	RunInterrupted = "interrupted"
+)
+
+// HashSettings fingerprints the settings a run was started with so reports
+// can tell whether two runs are comparable.
+func HashSettings(settings map[string]string) string {
+	hasher := sha256.New()
+	for _, key := range slices.Sorted(maps.Keys(settings)) {
+		fmt.Fprintf(hasher, "%s=%s\n", key, settings[key])
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))[:12]
+}
+
+// seedStats is updated by both the crawler and the batcher, which reports
+// whether pages were stored.
+type seedStats struct {
+	started time.Time
+	fetched atomic.Int64
+	stored  atomic.Int64
+	failed  atomic.Int64
+	bytes   atomic.Int64
+}
+
+func (s *seedStats) record(e Event) {
+	switch e {
+	case EventStored:
+		s.stored.Add(1)
+	case EventFetchError, EventParseError, EventStoreError:
+		s.failed.Add(1)
+	}
+}
+
+// save records the stats of a seed's crawl, which lasted until now.
+func (s *seedStats) save(queries store.Store, runID int64, seed string, now time.Time) error {
+	return queries.InsertSeedStats(context.TODO(), database.InsertSeedStatsParams{
+		RunID:        runID,
+		Seed:         seed,
+		PagesFetched: s.fetched.Load(),
+		PagesStored:  s.stored.Load(),
+		PagesFailed:  s.failed.Load(),
+		Bytes:        s.bytes.Load(),
+		DurationMs:   now.Sub(s.started).Milliseconds(),
+	})
+}
+
+// Report prints the latest run's per-seed stats alongside the change since
+// the run before it.
+func Report(queries store.Store, w io.Writer) error {
+	runs, err := queries.ListLatestRuns(context.TODO(), 2)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "no crawl runs recorded")
+		return nil
+	}
+
+	latest, err := queries.ListSeedStats(context.TODO(), runs[0].ID)
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]database.SeedStat{}
+	if len(runs) > 1 {
+		rows, err := queries.ListSeedStats(context.TODO(), runs[1].ID)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			previous[row.Seed] = row
+		}
+	}
+
+	for i, run := range runs {
+		label := "latest"
+		if i > 0 {
+			label = "previous"
+		}
+		fmt.Fprintf(w, "%s run %d: %s, started %s, %s, config %s\n", label, run.ID, run.Status, run.StartedAt.Format(time.RFC3339), runDuration(run), run.ConfigHash)
+	}
+	if len(runs) > 1 && runs[0].ConfigHash != runs[1].ConfigHash {
+		fmt.Fprintln(w, "config changed between runs")
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "seed\tfetched\tstored\tfailed\tbytes\tduration")
+	for _, row := range latest {
+		before, ok := previous[row.Seed]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Seed,
+			compare(row.PagesFetched, before.PagesFetched, ok),
+			compare(row.PagesStored, before.PagesStored, ok),
+			compare(row.PagesFailed, before.PagesFailed, ok),
+			compare(row.Bytes, before.Bytes, ok),
+			time.Duration(row.DurationMs)*time.Millisecond,
+		)
+		delete(previous, row.Seed)
+	}
+	for _, seed := range slices.Sorted(maps.Keys(previous)) {
+		fmt.Fprintf(tw, "%s\tnot crawled\t\t\t\t\n", seed)
+	}
+
+	return tw.Flush()
+}
+
+func runDuration(run database.CrawlRun) string {
+	if !run.FinishedAt.Valid {
+		return "unfinished"
+	}
+
+	return run.FinishedAt.Time.Sub(run.StartedAt).Round(time.Second).String()
+}
+
+func compare(current, previous int64, ok bool) string {
+	if !ok {
+		return fmt.Sprintf("%d (new)", current)
+	}
+
+	return fmt.Sprintf("%d (%+d)", current, current-previous)
+}
@@ -0,0 +1,82 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/database"
+)
+
+func TestReport(t *testing.T) {
+	queries := memoryStore(t)
+	ctx := context.Background()
+
+	out := &bytes.Buffer{}
+	if err := Report(queries, out); err != nil {
+		t.Fatalf("F47: test case 1 failed, unexpected error: %v", err)
+	}
+	if expected := "no crawl runs recorded\n"; out.String() != expected {
+		t.Errorf("F47: test case 1 failed, %q != %q", out.String(), expected)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs := []struct {
+		config string
+		stats  []database.InsertSeedStatsParams
+	}{
+		{
+			config: "abc",
+			stats: []database.InsertSeedStatsParams{
+				{Seed: "https://arxiv.org/", PagesFetched: 10, PagesStored: 8, PagesFailed: 2, Bytes: 1000, DurationMs: 60000},
+				{Seed: "https://www.bing.com/", PagesFetched: 5, PagesStored: 5, Bytes: 500, DurationMs: 30000},
+			},
+		},
+		{
+			config: "def",
+			stats: []database.InsertSeedStatsParams{
+				{Seed: "https://arxiv.org/", PagesFetched: 12, PagesStored: 7, PagesFailed: 5, Bytes: 1500, DurationMs: 90000},
+				{Seed: "https://www.google.com/", PagesFetched: 3, PagesStored: 3, Bytes: 300, DurationMs: 1500},
+			},
+		},
+	}
+	for i, run := range runs {
+		started := start.Add(time.Duration(i) * 24 * time.Hour)
+		id, err := queries.StartRun(ctx, database.StartRunParams{StartedAt: started, ConfigHash: run.config})
+		if err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		for _, stats := range run.stats {
+			stats.RunID = id
+			if err := queries.InsertSeedStats(ctx, stats); err != nil {
+				t.Fatalf("error setting up test, unexpected error: %v", err)
+			}
+		}
+		if i == 0 {
+			finished := sql.NullTime{Time: started.Add(90 * time.Second), Valid: true}
+			if err := queries.FinishRun(ctx, database.FinishRunParams{FinishedAt: finished, Status: RunCompleted, ID: id}); err != nil {
+				t.Fatalf("error setting up test, unexpected error: %v", err)
+			}
+		}
+	}
+
+	// The latest run is compared seed by seed with the one before it, which
+	// had a different config and a seed the latest didn't crawl.
+	out.Reset()
+	if err := Report(queries, out); err != nil {
+		t.Fatalf("F47: test case 2 failed, unexpected error: %v", err)
+	}
+	expected := "latest run 2: running, started 2025-01-02T00:00:00Z, unfinished, config def\n" +
+		"previous run 1: completed, started 2025-01-01T00:00:00Z, 1m30s, config abc\n" +
+		"config changed between runs\n" +
+		"\n" +
+		"seed                     fetched      stored   failed   bytes        duration\n" +
+		"https://arxiv.org/       12 (+2)      7 (-1)   5 (+3)   1500 (+500)  1m30s\n" +
+		"https://www.google.com/  3 (new)      3 (new)  0 (new)  300 (new)    1.5s\n" +
+		"https://www.bing.com/    not crawled                                 \n"
+	if out.String() != expected {
+		t.Errorf("F47: test case 2 failed, %q != %q", out.String(), expected)
+	}
+}
@@ -0,0 +1,28 @@
+package src
+
+import (
+	"context"
+
+	"github.com/junwei890/crawler/internal/store"
+)
+
+type DBStore struct {
+	queries store.Store
+}
+
+func NewDBStore(queries store.Store) *DBStore {
+	return &DBStore{queries: queries}
+}
+
+func (d *DBStore) Has(key string) (bool, error) {
+	exists, err := d.queries.HasVisited(context.TODO(), key)
+	if err != nil {
+		return false, err
+	}
+
+	return exists == 1, nil
+}
+
+func (d *DBStore) Add(key string) error {
+	return d.queries.InsertVisited(context.TODO(), key)
+}
@@ -0,0 +1,205 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/junwei890/crawler/utils"
+)
+
+// defaultFrontier is how many queued URLs the frontier endpoint lists when
+// no limit is given.
+const defaultFrontier = 50
+
+// SeedRequest is the body of a crawl submission, the JSON form of a line in
+// links.txt. Mode is full, the default, or feeds.
+type SeedRequest struct {
+	URL   string   `json:"url"`
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	Feeds []string `json:"feeds,omitempty"`
+	Mode  string   `json:"mode,omitempty"`
+}
+
+func (r SeedRequest) seed() (utils.Seed, error) {
+	seed := utils.Seed{URL: r.URL, Feeds: r.Feeds}
+	for _, language := range r.Allow {
+		seed.AllowLanguages = append(seed.AllowLanguages, utils.PrimaryLanguage(language))
+	}
+	for _, language := range r.Deny {
+		seed.DenyLanguages = append(seed.DenyLanguages, utils.PrimaryLanguage(language))
+	}
+
+	switch r.Mode {
+	case "", "full":
+	case "feeds":
+		seed.FeedsOnly = true
+	default:
+		return utils.Seed{}, fmt.Errorf("unknown seed mode: %s", r.Mode)
+	}
+
+	return seed, nil
+}
+
+func seedRequest(seed utils.Seed) SeedRequest {
+	request := SeedRequest{URL: seed.URL, Allow: seed.AllowLanguages, Deny: seed.DenyLanguages, Feeds: seed.Feeds}
+	if seed.FeedsOnly {
+		request.Mode = "feeds"
+	}
+
+	return request
+}
+
+// FrontierResponse lists the start of a crawl's queue.
+type FrontierResponse struct {
+	Size int             `json:"size"`
+	Next []FrontierEntry `json:"next"`
+}
+
+type FrontierEntry struct {
+	URL     string  `json:"url"`
+	Depth   int     `json:"depth"`
+	InLinks int     `json:"in_links"`
+	Boost   float64 `json:"boost,omitempty"`
+}
+
+// NewServer returns the admin API over c, alongside a dashboard under /ui/:
+//
+//	POST /crawls                 submit a seed, a SeedRequest
+//	GET  /crawls                 every crawl in this run with its progress
+//	GET  /crawls/{id}            one crawl's progress
+//	POST /crawls/{id}/pause      stop dequeuing until resumed
+//	POST /crawls/{id}/resume
+//	POST /crawls/{id}/cancel
+//	GET  /crawls/{id}/frontier   queued URLs, ?limit= of them
+//	GET  /crawls/{id}/results    the most recent fetch results
+func NewServer(c *Controller) http.Handler {
+	s := &server{controller: c, logger: c.cfg.Logger}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /crawls", s.submit)
+	mux.HandleFunc("GET /crawls", s.list)
+	mux.HandleFunc("GET /crawls/{id}", s.withJob(func(w http.ResponseWriter, r *http.Request, job *Job) {
+		s.respond(w, http.StatusOK, job.Status())
+	}))
+	mux.HandleFunc("POST /crawls/{id}/pause", s.withJob(s.control((*Job).Pause)))
+	mux.HandleFunc("POST /crawls/{id}/resume", s.withJob(s.control((*Job).Resume)))
+	mux.HandleFunc("POST /crawls/{id}/cancel", s.withJob(s.control((*Job).Cancel)))
+	mux.HandleFunc("GET /crawls/{id}/frontier", s.withJob(s.frontier))
+	mux.HandleFunc("GET /crawls/{id}/results", s.withJob(func(w http.ResponseWriter, r *http.Request, job *Job) {
+		s.respond(w, http.StatusOK, job.Results())
+	}))
+
+	mux.Handle("GET /{$}", http.RedirectHandler("/ui/", http.StatusFound))
+	mux.HandleFunc("GET /ui/{$}", s.crawls)
+	mux.HandleFunc("GET /ui/crawls/{id}", s.crawl)
+	mux.HandleFunc("GET /ui/pages", s.pages)
+	mux.HandleFunc("GET /ui/pages/view", s.page)
+
+	return mux
+}
+
+type server struct {
+	controller *Controller
+	logger     Logger
+}
+
+func (s *server) submit(w http.ResponseWriter, r *http.Request) {
+	request := SeedRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	seed, err := request.seed()
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := s.controller.Submit(seed)
+	if errors.Is(err, ErrJobState) {
+		s.fail(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		s.fail(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.respond(w, http.StatusCreated, job.Status())
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	statuses := []JobStatus{}
+	for _, job := range s.controller.Jobs() {
+		statuses = append(statuses, job.Status())
+	}
+
+	s.respond(w, http.StatusOK, statuses)
+}
+
+func (s *server) frontier(w http.ResponseWriter, r *http.Request, job *Job) {
+	limit := defaultFrontier
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			s.fail(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %s", value))
+			return
+		}
+		limit = parsed
+	}
+
+	size, next := job.Frontier(limit)
+	response := FrontierResponse{Size: size, Next: []FrontierEntry{}}
+	for _, info := range next {
+		response.Next = append(response.Next, FrontierEntry{URL: info.URL, Depth: info.Depth, InLinks: info.InLinks, Boost: info.Boost})
+	}
+
+	s.respond(w, http.StatusOK, response)
+}
+
+func (s *server) control(action func(*Job) error) func(http.ResponseWriter, *http.Request, *Job) {
+	return func(w http.ResponseWriter, r *http.Request, job *Job) {
+		if err := action(job); err != nil {
+			s.fail(w, http.StatusConflict, err)
+			return
+		}
+
+		s.respond(w, http.StatusOK, job.Status())
+	}
+}
+
+func (s *server) withJob(handler func(http.ResponseWriter, *http.Request, *Job)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			s.fail(w, http.StatusNotFound, ErrJobNotFound)
+			return
+		}
+
+		job, err := s.controller.Job(id)
+		if err != nil {
+			s.fail(w, http.StatusNotFound, err)
+			return
+		}
+
+		handler(w, r, job)
+	}
+}
+
+func (s *server) respond(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.Warn("couldn't write response", slog.String("error", err.Error()))
+	}
+}
+
+func (s *server) fail(w http.ResponseWriter, status int, err error) {
+	s.respond(w, status, map[string]string{"error": err.Error()})
+}
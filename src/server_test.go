@@ -0,0 +1,195 @@
+package src
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/junwei890/crawler/internal/fakeweb"
+	"github.com/junwei890/crawler/utils"
+)
+
+// gatedFetcher holds every fetch until the test releases it, reporting each
+// URL on fetching as it starts.
+type gatedFetcher struct {
+	*fakeFetcher
+	fetching chan string
+	release  chan struct{}
+}
+
+func (f *gatedFetcher) Fetch(rawURL string) (utils.Page, error) {
+	f.fetching <- rawURL
+	<-f.release
+
+	return f.fakeFetcher.Fetch(rawURL)
+}
+
+// call sends body to the admin API, decoding the response into out when
+// it's not nil, and returns the status code.
+func call(t *testing.T, method, url, body string, out any) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error calling %s %s, unexpected error: %v", method, url, err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error calling %s %s, unexpected error: %v", method, url, err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("error decoding %s %s, unexpected error: %v", method, url, err)
+		}
+	}
+
+	return res.StatusCode
+}
+
+// eventually polls check until it holds, failing the test after a few
+// seconds.
+func eventually(t *testing.T, name string, check func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !check(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s failed, timed out waiting", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	html := func(path string, links ...string) func(time.Time) (string, string) {
+		return func(time.Time) (string, string) {
+			return "text/html", fakeweb.Render(path, fakeweb.Page{Links: links})
+		}
+	}
+	fetcher := &gatedFetcher{
+		fakeFetcher: &fakeFetcher{clock: clock, pages: map[string]func(time.Time) (string, string){
+			"http://fake.test/":  html("/", "/a", "/b"),
+			"http://fake.test/a": html("/a"),
+			"http://fake.test/b": html("/b"),
+		}},
+		fetching: make(chan string, 10),
+		release:  make(chan struct{}),
+	}
+
+	controller, err := NewController(memoryStore(t), testConfig(), WithFetcher(fetcher), WithRobots(fetcher), WithClock(clock))
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	server := httptest.NewServer(NewServer(controller))
+	defer server.Close()
+
+	rejected := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{name: "F39: test case 1", method: http.MethodPost, path: "/crawls", body: "{", expected: http.StatusBadRequest},
+		{name: "F39: test case 2", method: http.MethodPost, path: "/crawls", body: `{"url": "http://fake.test/", "mode": "sometimes"}`, expected: http.StatusBadRequest},
+		{name: "F39: test case 3", method: http.MethodPost, path: "/crawls", body: `{"url": "ftp://fake.test/"}`, expected: http.StatusBadRequest},
+		{name: "F39: test case 4", method: http.MethodGet, path: "/crawls/1", expected: http.StatusNotFound},
+		{name: "F39: test case 5", method: http.MethodPost, path: "/crawls/one/pause", expected: http.StatusNotFound},
+	}
+	for _, test := range rejected {
+		if status := call(t, test.method, server.URL+test.path, test.body, nil); status != test.expected {
+			t.Errorf("%s failed, %v != %v", test.name, status, test.expected)
+		}
+	}
+
+	status := JobStatus{}
+	if code := call(t, http.MethodPost, server.URL+"/crawls", `{"url": "http://fake.test/"}`, &status); code != http.StatusCreated || status.ID != 1 {
+		t.Fatalf("F39: test case 6 failed, %v != %v, id %v", code, http.StatusCreated, status.ID)
+	}
+	if url := <-fetcher.fetching; url != "http://fake.test/" {
+		t.Errorf("F39: test case 7 failed, %v != %v", url, "http://fake.test/")
+	}
+
+	// The crawl is running, blocked fetching its seed.
+	if code := call(t, http.MethodPost, server.URL+"/crawls", `{"url": "http://fake.test/"}`, nil); code != http.StatusConflict {
+		t.Errorf("F39: test case 8 failed, %v != %v", code, http.StatusConflict)
+	}
+	if code := call(t, http.MethodGet, server.URL+"/crawls/1", "", &status); code != http.StatusOK || status.State != SeedRunning {
+		t.Errorf("F39: test case 9 failed, %v %v != %v %v", code, status.State, http.StatusOK, SeedRunning)
+	}
+	if code := call(t, http.MethodPost, server.URL+"/crawls/1/resume", "", nil); code != http.StatusConflict {
+		t.Errorf("F39: test case 10 failed, %v != %v", code, http.StatusConflict)
+	}
+	if code := call(t, http.MethodPost, server.URL+"/crawls/1/pause", "", &status); code != http.StatusOK || status.State != SeedPaused {
+		t.Errorf("F39: test case 11 failed, %v %v != %v %v", code, status.State, http.StatusOK, SeedPaused)
+	}
+	if code := call(t, http.MethodPost, server.URL+"/crawls/1/pause", "", nil); code != http.StatusConflict {
+		t.Errorf("F39: test case 12 failed, %v != %v", code, http.StatusConflict)
+	}
+
+	// Paused, the crawl finishes the seed and queues its links but fetches
+	// nothing else.
+	fetcher.release <- struct{}{}
+	frontier := FrontierResponse{}
+	eventually(t, "F39: test case 13", func() bool {
+		call(t, http.MethodGet, server.URL+"/crawls/1/frontier", "", &frontier)
+		return frontier.Size == 2
+	})
+	if code := call(t, http.MethodGet, server.URL+"/crawls/1/frontier?limit=1", "", &frontier); code != http.StatusOK || len(frontier.Next) != 1 {
+		t.Errorf("F39: test case 14 failed, %v != %v, next %v", code, http.StatusOK, frontier.Next)
+	}
+	if code := call(t, http.MethodGet, server.URL+"/crawls/1/frontier?limit=-1", "", nil); code != http.StatusBadRequest {
+		t.Errorf("F39: test case 15 failed, %v != %v", code, http.StatusBadRequest)
+	}
+	results := []FetchResult{}
+	eventually(t, "F39: test case 16", func() bool {
+		call(t, http.MethodGet, server.URL+"/crawls/1/results", "", &results)
+		return len(results) == 1 && results[0].URL == "http://fake.test/" && results[0].Event == EventStored
+	})
+	select {
+	case url := <-fetcher.fetching:
+		t.Errorf("F39: test case 17 failed, %s fetched while paused", url)
+	default:
+	}
+
+	if code := call(t, http.MethodPost, server.URL+"/crawls/1/resume", "", &status); code != http.StatusOK || status.State != SeedRunning {
+		t.Errorf("F39: test case 18 failed, %v %v != %v %v", code, status.State, http.StatusOK, SeedRunning)
+	}
+	<-fetcher.fetching
+	if code := call(t, http.MethodPost, server.URL+"/crawls/1/cancel", "", nil); code != http.StatusOK {
+		t.Errorf("F39: test case 19 failed, %v != %v", code, http.StatusOK)
+	}
+	fetcher.release <- struct{}{}
+	eventually(t, "F39: test case 20", func() bool {
+		call(t, http.MethodGet, server.URL+"/crawls/1", "", &status)
+		return status.State == SeedCancelled
+	})
+	for _, action := range []string{"pause", "resume", "cancel"} {
+		if code := call(t, http.MethodPost, server.URL+"/crawls/1/"+action, "", nil); code != http.StatusConflict {
+			t.Errorf("F39: test case 21 failed, %s: %v != %v", action, code, http.StatusConflict)
+		}
+	}
+
+	// Once cancelled, the seed can be submitted again.
+	close(fetcher.release)
+	if code := call(t, http.MethodPost, server.URL+"/crawls", `{"url": "http://fake.test/"}`, &status); code != http.StatusCreated || status.ID != 2 {
+		t.Errorf("F39: test case 22 failed, %v != %v, id %v", code, http.StatusCreated, status.ID)
+	}
+	controller.Wait()
+	statuses := []JobStatus{}
+	if code := call(t, http.MethodGet, server.URL+"/crawls", "", &statuses); code != http.StatusOK || len(statuses) != 2 || statuses[1].State != SeedCompleted {
+		t.Errorf("F39: test case 23 failed, %v != %v, statuses %+v", code, http.StatusOK, statuses)
+	}
+
+	if err := controller.Close(); err != nil {
+		t.Fatalf("F39: test case 24 failed, unexpected error: %v", err)
+	}
+	if _, err := controller.Submit(utils.Seed{URL: "http://fake.test/"}); err == nil {
+		t.Errorf("F39: test case 24 failed, expected an error submitting to a closed controller")
+	}
+}
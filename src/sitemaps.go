@@ -0,0 +1,37 @@
+package src
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/junwei890/crawler/utils"
+)
+
+const maxSitemaps = 50
+
+func loadSitemaps(fetcher Fetcher, sitemaps []string, logger Logger) []utils.URLInfo {
+	entries := []utils.URLInfo{}
+	pending := slices.Clone(sitemaps)
+
+	for fetched := 0; len(pending) > 0 && fetched < maxSitemaps; fetched++ {
+		sitemapURL := pending[0]
+		pending = pending[1:]
+
+		page, err := fetcher.Fetch(sitemapURL)
+		if err != nil {
+			logger.Warn("couldn't fetch sitemap", slog.String("sitemap", sitemapURL), slog.String("error", err.Error()))
+			continue
+		}
+
+		found, nested, err := utils.ParseSitemap(page.Body)
+		if err != nil {
+			logger.Warn("couldn't parse sitemap", slog.String("sitemap", sitemapURL), slog.String("error", err.Error()))
+			continue
+		}
+
+		entries = append(entries, found...)
+		pending = append(pending, nested...)
+	}
+
+	return entries
+}
@@ -0,0 +1,41 @@
+package src
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/junwei890/crawler/utils"
+)
+
+func TestLoadSitemaps(t *testing.T) {
+	// Neither sitemap says what charset it's in, in its Content-Type or an
+	// XML declaration.
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<sitemapindex><sitemap><loc>` + server.URL + `/news.xml</loc></sitemap></sitemapindex>`))
+		case "/news.xml":
+			w.Header().Set("Content-Type", "text/xml")
+			w.Write([]byte(`<?xml version="1.0"?><urlset><url><loc>https://www.google.com/café</loc></url><url><loc>https://www.google.com/b</loc><priority>0.8</priority></url></urlset>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	entries := loadSitemaps(HTTPFetcher{}, []string{server.URL + "/sitemap.xml"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	expected := []utils.URLInfo{
+		{URL: "https://www.google.com/café"},
+		{URL: "https://www.google.com/b", SitemapPriority: 0.8},
+	}
+	if !slices.Equal(entries, expected) {
+		t.Errorf("F50: test case 1 failed, %v != %v", entries, expected)
+	}
+}
@@ -0,0 +1,25 @@
+package src
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+type Stats struct {
+	Pages        atomic.Int64
+	WireBytes    atomic.Int64
+	DecodedBytes atomic.Int64
+}
+
+func (s *Stats) Record(wireBytes, decodedBytes int64) {
+	s.Pages.Add(1)
+	s.WireBytes.Add(wireBytes)
+	s.DecodedBytes.Add(decodedBytes)
+}
+
+func (s *Stats) String() string {
+	wire := s.WireBytes.Load()
+	decoded := s.DecodedBytes.Load()
+
+	return fmt.Sprintf("fetched %d pages, %d bytes over the wire, %d bytes decoded, %d bytes saved by compression", s.Pages.Load(), wire, decoded, decoded-wire)
+}
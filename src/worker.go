@@ -0,0 +1,367 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junwei890/crawler/internal/store"
+	"github.com/junwei890/crawler/utils"
+)
+
+// idlePoll is how long a worker waits before asking again when there's
+// nothing leased to it or the coordinator can't be reached.
+const idlePoll = time.Second
+
+// coordinatorAttempts is how many requests in a row a worker lets fail
+// before giving up on the coordinator.
+const coordinatorAttempts = 30
+
+// Worker crawls the URLs a Coordinator leases it, storing pages itself and
+// reporting what it found back.
+type Worker struct {
+	id          string
+	coordinator string
+	client      *http.Client
+	crawler     *Crawler
+	queries     store.Store
+	cfg         Config
+
+	mu        sync.Mutex
+	robots    map[string]utils.Rules
+	lastFetch map[string]time.Time
+}
+
+// NewWorker returns a worker identified to the coordinator at the base URL
+// coordinator by id, which must be unique among its workers. It crawls with
+// the dependencies opts inject.
+func NewWorker(id, coordinator string, queries store.Store, cfg Config, opts ...Option) *Worker {
+	crawler := NewCrawler(queries, cfg, opts...)
+
+	return &Worker{
+		id:          id,
+		coordinator: strings.TrimRight(coordinator, "/"),
+		client:      &http.Client{Timeout: 30 * time.Second},
+		crawler:     crawler,
+		queries:     queries,
+		cfg:         crawler.cfg,
+		robots:      map[string]utils.Rules{},
+		lastFetch:   map[string]time.Time{},
+	}
+}
+
+// Run crawls leases of up to size URLs until the coordinator says the crawl
+// is done or ctx is cancelled.
+func (w *Worker) Run(ctx context.Context, size int) error {
+	if size <= 0 {
+		return ErrLeaseSize
+	}
+	if err := loadFingerprints(w.queries, w.cfg); err != nil {
+		return err
+	}
+
+	attempts := NewAttemptLog(w.queries, w.cfg.BatchSize, w.cfg.BatchInterval, w.crawler.clock, w.cfg.Logger)
+	defer attempts.Close()
+	batcher := NewBatcher(w.queries, w.cfg.BatchSize, w.cfg.BatchInterval, w.crawler.clock, w.cfg.Metrics, w.cfg.Logger)
+	defer batcher.Close()
+
+	failures := 0
+	for ctx.Err() == nil {
+		lease := Lease{}
+		if err := w.post(ctx, "/lease", LeaseRequest{Worker: w.id, Max: size}, &lease); err != nil {
+			if failures++; failures == coordinatorAttempts {
+				return fmt.Errorf("couldn't reach coordinator: %w", err)
+			}
+			w.cfg.Logger.Warn("couldn't lease urls", slog.String("error", err.Error()))
+			sleep(ctx, w.crawler.clock, idlePoll)
+			continue
+		}
+		failures = 0
+
+		if lease.Done {
+			return nil
+		}
+		if lease.ID == "" {
+			sleep(ctx, w.crawler.clock, idlePoll)
+			continue
+		}
+
+		results := w.crawl(ctx, lease, batcher, attempts)
+		if err := w.complete(lease, results); err != nil {
+			w.cfg.Logger.Error("couldn't complete lease", slog.String("lease", lease.ID), slog.String("error", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// crawl works through a lease, one goroutine per host, renewing it until
+// every stored page has been written. URLs left when ctx is cancelled have
+// no result, so the coordinator queues them again.
+func (w *Worker) crawl(ctx context.Context, lease Lease, batcher *Batcher, attempts *AttemptLog) []LeaseResult {
+	renewing, stop := context.WithCancel(context.Background())
+	defer stop()
+	go w.renew(renewing, lease)
+
+	byHost := map[string][]int{}
+	for i, leased := range lease.URLs {
+		host := ""
+		if parsed, err := url.Parse(leased.URL); err == nil {
+			host = parsed.Hostname()
+		}
+		byHost[host] = append(byHost[host], i)
+	}
+
+	results := make([]LeaseResult, len(lease.URLs))
+	writes := &sync.WaitGroup{}
+	hosts := &sync.WaitGroup{}
+	for _, indexes := range byHost {
+		hosts.Add(1)
+		go func() {
+			defer hosts.Done()
+			for _, i := range indexes {
+				if ctx.Err() != nil {
+					return
+				}
+				results[i].URL = lease.URLs[i].URL
+				w.crawlURL(ctx, lease.RunID, lease.URLs[i], batcher, attempts, writes, &results[i])
+			}
+		}()
+	}
+	hosts.Wait()
+	batcher.Flush()
+	writes.Wait()
+
+	done := []LeaseResult{}
+	for _, result := range results {
+		if result.Event != "" {
+			done = append(done, result)
+		}
+	}
+
+	return done
+}
+
+func (w *Worker) crawlURL(ctx context.Context, runID int64, leased LeasedURL, batcher *Batcher, attempts *AttemptLog, writes *sync.WaitGroup, result *LeaseResult) {
+	event := URLEvent{Seed: leased.Seed.URL, URL: leased.URL}
+	emit := func(e Event, err error) {
+		event.Event = e
+		event.Err = err
+		result.Event = e
+		w.cfg.Metrics.Events.WithLabelValues(string(e)).Inc()
+		logEvent(w.cfg.Logger, event)
+		attempts.Add(runID, event)
+	}
+
+	seed, err := leased.Seed.seed()
+	if err != nil {
+		emit(EventSkippedScope, err)
+		return
+	}
+	dom, err := url.Parse(seed.URL)
+	if err != nil {
+		emit(EventSkippedScope, err)
+		return
+	}
+	logger := withAttrs(w.cfg.Logger, slog.String("seed", seed.URL))
+
+	rules, sitemaps, err := w.rulesFor(seed, dom, logger)
+	if err != nil {
+		emit(EventFetchError, err)
+		return
+	}
+	for _, info := range sitemaps {
+		result.Links = append(result.Links, Discovered{URL: info.URL, Depth: info.Depth, SitemapPriority: info.SitemapPriority, LastModified: info.LastModified})
+	}
+
+	normURL, err := utils.Normalize(leased.URL)
+	if err != nil {
+		emit(EventSkippedScope, err)
+		return
+	}
+	if !utils.CheckRobots(rules, normURL) {
+		emit(EventSkippedRobots, nil)
+		return
+	}
+
+	w.wait(ctx, dom.Hostname(), time.Duration(rules.Delay)*time.Second)
+
+	page, err := w.crawler.fetch(leased.URL, logger, &event)
+	result.Bytes = page.WireSize
+	if err != nil {
+		emit(EventFetchError, err)
+		return
+	}
+	result.Fetched = true
+
+	doc, err := extract(dom, page)
+	if err != nil {
+		emit(EventParseError, err)
+		return
+	}
+
+	if !seed.FeedsOnly {
+		for _, link := range doc.Links {
+			result.Links = append(result.Links, Discovered{URL: link, Depth: leased.Depth + 1})
+		}
+	}
+
+	row, skipped, attrs := buildRow(leased.URL, page, doc, seed, w.cfg, w.crawler.clock.Now())
+	event.Attrs = attrs
+	if skipped != "" {
+		emit(skipped, nil)
+		return
+	}
+	row.RawHash = keepRaw(leased.URL, page, w.cfg, logger)
+
+	writes.Add(1)
+	batcher.Add(row, func(err error) {
+		defer writes.Done()
+		if err != nil {
+			emit(EventStoreError, err)
+			return
+		}
+		indexFingerprint(w.cfg, row)
+		emit(EventStored, nil)
+	})
+}
+
+// rulesFor returns the robots rules for a seed's host, fetching them the
+// first time along with the URLs in its sitemaps.
+func (w *Worker) rulesFor(seed utils.Seed, dom *url.URL, logger Logger) (utils.Rules, []utils.URLInfo, error) {
+	w.mu.Lock()
+	rules, ok := w.robots[dom.Hostname()]
+	w.mu.Unlock()
+	if ok {
+		return rules, []utils.URLInfo{}, nil
+	}
+
+	file, err := w.crawler.robots.Robots(seed.URL)
+	if err != nil {
+		return utils.Rules{}, []utils.URLInfo{}, err
+	}
+	normURL, err := utils.Normalize(seed.URL)
+	if err != nil {
+		return utils.Rules{}, []utils.URLInfo{}, err
+	}
+	rules, err = utils.ParseRobots(normURL, file)
+	if err != nil {
+		return utils.Rules{}, []utils.URLInfo{}, err
+	}
+
+	sitemaps := []utils.URLInfo{}
+	if !seed.FeedsOnly {
+		sitemaps = loadSitemaps(w.crawler.plain, rules.Sitemaps, logger)
+	}
+
+	w.mu.Lock()
+	w.robots[dom.Hostname()] = rules
+	w.mu.Unlock()
+
+	return rules, sitemaps, nil
+}
+
+// wait holds a fetch back until the host's crawl delay has passed since the
+// last one, across leases.
+func (w *Worker) wait(ctx context.Context, host string, delay time.Duration) {
+	now := w.crawler.clock.Now()
+	w.mu.Lock()
+	next := w.lastFetch[host].Add(delay)
+	if next.Before(now) {
+		next = now
+	}
+	w.lastFetch[host] = next
+	w.mu.Unlock()
+
+	pause := next.Sub(now)
+	w.cfg.Metrics.DelayWait.WithLabelValues(host).Add(pause.Seconds())
+	sleep(ctx, w.crawler.clock, pause)
+}
+
+// renew keeps a lease alive until ctx is done, or until the coordinator says
+// it has expired and renewing it again could never succeed.
+func (w *Worker) renew(ctx context.Context, lease Lease) {
+	ticker := w.crawler.clock.NewTicker(time.Duration(lease.TTLMs) * time.Millisecond / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			err := w.post(ctx, "/renew", RenewRequest{Worker: w.id, Lease: lease.ID}, nil)
+			if errors.Is(err, ErrLeaseExpired) {
+				w.cfg.Logger.Warn("lease expired", slog.String("lease", lease.ID))
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				w.cfg.Logger.Warn("couldn't renew lease", slog.String("lease", lease.ID), slog.String("error", err.Error()))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// complete reports a lease's results, retrying since its pages are already
+// stored and a lost report would have them crawled again. ErrLeaseExpired
+// isn't retried, since no later attempt could succeed.
+func (w *Worker) complete(lease Lease, results []LeaseResult) error {
+	var err error
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = w.post(ctx, "/complete", CompleteRequest{Worker: w.id, Lease: lease.ID, Results: results}, nil)
+		cancel()
+		if err == nil || errors.Is(err, ErrLeaseExpired) {
+			return err
+		}
+		<-w.crawler.clock.After(idlePoll)
+	}
+
+	return err
+}
+
+func (w *Worker) post(ctx context.Context, path string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.coordinator+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusGone {
+		return ErrLeaseExpired
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("coordinator responded %s", res.Status)
+	}
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// sleep waits for d on clock, returning early when ctx is cancelled.
+func sleep(ctx context.Context, clock Clock, d time.Duration) {
+	select {
+	case <-clock.After(d):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BlobDir is a content addressed store of gzipped blobs, each kept at
+// <dir>/<first two hex digits>/<sha256>.gz so no directory grows too large.
+type BlobDir struct {
+	dir string
+}
+
+func NewBlobDir(dir string) (*BlobDir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &BlobDir{dir: dir}, nil
+}
+
+func (b *BlobDir) path(hash string) string {
+	return filepath.Join(b.dir, hash[:2], hash+".gz")
+}
+
+// Put stores content and returns its hash. Content that is already stored
+// isn't written again.
+func (b *BlobDir) Put(content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	path := b.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write(content); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	// Writing to a temporary file first means a crash never leaves a
+	// truncated blob under a valid hash.
+	temp, err := os.CreateTemp(filepath.Dir(path), "blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(compressed.Bytes()); err != nil {
+		temp.Close()
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		return "", err
+	}
+
+	return hash, os.Rename(temp.Name(), path)
+}
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+func (b *BlobDir) Get(hash string) ([]byte, error) {
+	if len(hash) < 2 {
+		return []byte{}, ErrBlobNotFound
+	}
+
+	file, err := os.Open(b.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []byte{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return []byte{}, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlobDir(t *testing.T) {
+	dir := t.TempDir()
+	blobs, err := NewBlobDir(dir)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{
+			name:    "F29: test case 1",
+			content: []byte("<html><body><p>hello world</p></body></html>"),
+		},
+		{
+			name:    "F29: test case 2",
+			content: []byte{},
+		},
+		{
+			name:    "F29: test case 3",
+			content: bytes.Repeat([]byte{0x00, 0xff}, 1<<16),
+		},
+	}
+
+	for _, test := range tests {
+		hash, err := blobs.Put(test.content)
+		if err != nil {
+			t.Errorf("%s failed, unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(hash) != 64 {
+			t.Errorf("%s failed, %d != %d", test.name, len(hash), 64)
+		}
+
+		again, err := blobs.Put(test.content)
+		if err != nil || again != hash {
+			t.Errorf("%s failed, %s != %s, error %v", test.name, again, hash, err)
+		}
+
+		content, err := blobs.Get(hash)
+		if err != nil {
+			t.Errorf("%s failed, unexpected error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(content, test.content) {
+			t.Errorf("%s failed, content changed after a round trip", test.name)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	if err != nil || len(files) != len(tests) {
+		t.Errorf("F29: test case 4 failed, %d != %d, error %v", len(files), len(tests), err)
+	}
+
+	if _, err := blobs.Get("0000"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("F29: test case 5 failed, %v != %v", err, ErrBlobNotFound)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "ab"), 0o755); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ab", "ab.gz"), []byte("not gzip"), 0o644); err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	if _, err := blobs.Get("ab"); err == nil {
+		t.Errorf("F29: test case 6 failed, expected an error reading a corrupt blob")
+	}
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	AcceptEncoding = "gzip, deflate, br"
+	MaxBodySize    = 10 << 20
+	MaxPDFSize     = 50 << 20
+)
+
+// Decompress undoes every coding listed in Content-Encoding, last applied
+// first. Closing the reader closes its decoders but not body.
+func Decompress(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	codings := strings.Split(contentEncoding, ",")
+	slices.Reverse(codings)
+
+	decoded := &decoders{Reader: body}
+	for _, coding := range codings {
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			reader, err := gzip.NewReader(decoded.Reader)
+			if err != nil {
+				return nil, errors.Join(err, decoded.Close())
+			}
+			decoded.push(reader)
+		case "deflate":
+			reader, err := inflate(decoded.Reader)
+			if err != nil {
+				return nil, errors.Join(err, decoded.Close())
+			}
+			decoded.push(reader)
+		case "br":
+			decoded.Reader = brotli.NewReader(decoded.Reader)
+		default:
+			return nil, errors.Join(fmt.Errorf("unsupported content encoding: %s", coding), decoded.Close())
+		}
+	}
+
+	return decoded, nil
+}
+
+// decoders reads from the last of a chain of decoders.
+type decoders struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decoders) push(reader io.ReadCloser) {
+	d.Reader = reader
+	d.closers = append(d.closers, reader)
+}
+
+// Close closes the decoders, outermost first.
+func (d *decoders) Close() error {
+	errs := []error{}
+	for _, closer := range slices.Backward(d.closers) {
+		errs = append(errs, closer.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// inflate handles deflate bodies with and without the zlib wrapper, since
+// plenty of servers send raw deflate despite RFC 9110.
+func inflate(body io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+
+	return flate.NewReader(buffered), nil
+}
+
+func ReadLimited(body io.Reader, limit int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return []byte{}, err
+	}
+	if int64(len(content)) > limit {
+		return []byte{}, fmt.Errorf("body exceeds %d bytes", limit)
+	}
+
+	return content, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
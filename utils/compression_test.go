@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, coding string, content []byte) []byte {
+	buffer := &bytes.Buffer{}
+
+	var writer io.WriteCloser
+	switch coding {
+	case "gzip":
+		writer = gzip.NewWriter(buffer)
+	case "zlib":
+		writer = zlib.NewWriter(buffer)
+	case "deflate":
+		writer, _ = flate.NewWriter(buffer, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(buffer)
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	page := []byte(strings.Repeat("<p>hello world</p>", 100))
+
+	testCases := []struct {
+		name            string
+		body            []byte
+		contentEncoding string
+		limit           int64
+		expected        []byte
+		errorPresent    bool
+	}{
+		{
+			name:            "F14: test case 1",
+			body:            page,
+			contentEncoding: "",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 2",
+			body:            compress(t, "gzip", page),
+			contentEncoding: "gzip",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 3",
+			body:            compress(t, "zlib", page),
+			contentEncoding: "deflate",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 4",
+			body:            compress(t, "deflate", page),
+			contentEncoding: "deflate",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 5",
+			body:            compress(t, "br", page),
+			contentEncoding: "br",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 6",
+			body:            compress(t, "br", compress(t, "gzip", page)),
+			contentEncoding: "gzip, br",
+			limit:           MaxBodySize,
+			expected:        page,
+			errorPresent:    false,
+		},
+		{
+			name:            "F14: test case 7",
+			body:            compress(t, "gzip", page),
+			contentEncoding: "gzip",
+			limit:           100,
+			expected:        []byte{},
+			errorPresent:    true,
+		},
+		{
+			name:            "F14: test case 8",
+			body:            page,
+			contentEncoding: "compress",
+			limit:           MaxBodySize,
+			expected:        []byte{},
+			errorPresent:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := []byte{}
+
+			body, err := Decompress(bytes.NewReader(testCase.body), testCase.contentEncoding)
+			if err == nil {
+				result, err = ReadLimited(body, testCase.limit)
+				if closeErr := body.Close(); closeErr != nil {
+					t.Errorf("%s failed, unexpected error closing: %v", testCase.name, closeErr)
+				}
+			}
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if !bytes.Equal(result, testCase.expected) {
+				t.Errorf("%s failed, %d bytes != %d bytes", testCase.name, len(result), len(testCase.expected))
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// bandRead is how many entries are read from a band's file at a time.
+	bandRead = 64
+	// compactAt is how much of a band's file has to have been read before
+	// the rest is moved to a fresh file, once that's over half of it.
+	compactAt = 1 << 20
+)
+
+// DiskQueue is a priority queue, scored like Frontier, that keeps at most
+// limit entries in memory and pages the rest to disk, so the frontier of a
+// huge crawl doesn't have to fit in RAM. Entries that don't fit are spilled
+// to one append-only file per priority band, a band being a whole point of
+// score, so entries come out in band order but, within a band, those in
+// memory come out by score and those on disk in the order they were
+// spilled. Band files are only open while they're written or read, so
+// however many bands there are, the queue holds no file open between calls.
+// With no scorers it's a plain FIFO queue. Only entries in memory merge with
+// URLs enqueued again.
+type DiskQueue struct {
+	path      string
+	limit     int
+	memory    *Frontier
+	bands     map[int]*band
+	compactAt int64
+	err       error
+}
+
+// band is a priority band's spilled entries: a file read from readOffset
+// and appended to at writeOffset, plus the next few entries already read.
+type band struct {
+	path        string
+	read        []URLInfo
+	readOffset  int64
+	writeOffset int64
+	tail        int
+}
+
+var _ InfoQueueOps = (*DiskQueue)(nil)
+
+// NewDiskQueue spills to files named path.<band>.
+func NewDiskQueue(path string, limit int, scorers ...Scorer) (*DiskQueue, error) {
+	// Make sure spilling will work before the crawl depends on it.
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.Join(file.Close(), os.Remove(path)); err != nil {
+		return nil, err
+	}
+
+	return &DiskQueue{
+		path:      path,
+		limit:     max(limit, 1),
+		memory:    NewFrontier(scorers...),
+		bands:     map[int]*band{},
+		compactAt: compactAt,
+	}, nil
+}
+
+func bandOf(score float64) int {
+	return int(math.Floor(score))
+}
+
+func (q *DiskQueue) Enqueue(url string) {
+	q.EnqueueWith(URLInfo{URL: url})
+}
+
+// EnqueueWith keeps info in memory while there's room, unless entries of
+// its band are already on disk, which it has to queue behind.
+func (q *DiskQueue) EnqueueWith(info URLInfo) {
+	b := bandOf(q.memory.score(info))
+	_, queued := q.memory.queued[info.URL]
+	if queued || (q.memory.Size() < q.limit && q.bands[b] == nil) {
+		q.memory.EnqueueWith(info)
+		return
+	}
+
+	if err := q.spill(b, info); err != nil {
+		q.err = err
+	}
+}
+
+func (q *DiskQueue) spill(b int, info URLInfo) error {
+	line, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	// A band's file is started afresh, in case an earlier crawl left one.
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	spilled, ok := q.bands[b]
+	if !ok {
+		flags |= os.O_TRUNC
+		spilled = &band{path: fmt.Sprintf("%s.%d", q.path, b)}
+	}
+
+	file, err := os.OpenFile(spilled.path, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	q.bands[b] = spilled
+
+	n, err := file.Write(line)
+	spilled.writeOffset += int64(n)
+	if err := errors.Join(err, file.Close()); err != nil {
+		return err
+	}
+	spilled.tail++
+
+	return nil
+}
+
+// next returns the band the next entry comes from, or false when it comes
+// from memory. Write errors from EnqueueWith surface here since QueueOps
+// gives Enqueue no error to return.
+func (q *DiskQueue) next() (int, bool, error) {
+	if q.err != nil {
+		return 0, false, q.err
+	}
+	if len(q.bands) == 0 {
+		return 0, false, nil
+	}
+
+	top := slices.Max(slices.Collect(maps.Keys(q.bands)))
+	if !q.memory.Empty() && bandOf(q.memory.items[0].score) >= top {
+		return 0, false, nil
+	}
+
+	return top, true, q.bands[top].fill()
+}
+
+// fill reads the band's next few entries into memory when none are left.
+func (b *band) fill() (err error) {
+	if len(b.read) > 0 {
+		return nil
+	}
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	reader := bufio.NewReader(io.NewSectionReader(file, b.readOffset, b.writeOffset-b.readOffset))
+	for len(b.read) < bandRead && b.tail > 0 {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		b.readOffset += int64(len(line))
+		b.tail--
+
+		info := URLInfo{}
+		if err := json.Unmarshal(line, &info); err != nil {
+			return err
+		}
+		b.read = append(b.read, info)
+	}
+
+	return nil
+}
+
+// compact moves what's left to read of the band's file to a fresh one, so a
+// band that's never fully drained doesn't grow forever.
+func (b *band) compact() error {
+	old, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer old.Close()
+
+	fresh, err := os.CreateTemp(filepath.Dir(b.path), filepath.Base(b.path)+"-*")
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(fresh, io.NewSectionReader(old, b.readOffset, b.writeOffset-b.readOffset))
+	err = errors.Join(err, fresh.Close())
+	if err == nil {
+		err = os.Rename(fresh.Name(), b.path)
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(fresh.Name()))
+	}
+
+	b.readOffset = 0
+	b.writeOffset = n
+
+	return nil
+}
+
+func (q *DiskQueue) DequeueInfo() (URLInfo, error) {
+	top, onDisk, err := q.next()
+	if err != nil {
+		return URLInfo{}, err
+	}
+	if !onDisk {
+		return q.memory.DequeueInfo()
+	}
+
+	spilled := q.bands[top]
+	popped := spilled.read[0]
+	spilled.read = spilled.read[1:]
+
+	// A drained band's file is removed, and one mostly read is compacted.
+	switch {
+	case len(spilled.read) == 0 && spilled.tail == 0:
+		delete(q.bands, top)
+		err = os.Remove(spilled.path)
+	case spilled.readOffset >= q.compactAt && spilled.readOffset*2 >= spilled.writeOffset:
+		err = spilled.compact()
+	}
+	if err != nil {
+		q.err = err
+	}
+
+	return popped, nil
+}
+
+func (q *DiskQueue) Dequeue() (string, error) {
+	info, err := q.DequeueInfo()
+	return info.URL, err
+}
+
+func (q *DiskQueue) Peek() (string, error) {
+	top, onDisk, err := q.next()
+	if err != nil {
+		return "", err
+	}
+	if !onDisk {
+		return q.memory.Peek()
+	}
+
+	return q.bands[top].read[0].URL, nil
+}
+
+func (q *DiskQueue) Empty() bool {
+	return q.Size() == 0
+}
+
+func (q *DiskQueue) Size() int {
+	size := q.memory.Size()
+	for _, spilled := range q.bands {
+		size += len(spilled.read) + spilled.tail
+	}
+
+	return size
+}
+
+func (q *DiskQueue) Close() error {
+	errs := []error{}
+	for _, spilled := range q.bands {
+		errs = append(errs, os.Remove(spilled.path))
+	}
+	q.bands = map[int]*band{}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestDiskQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.queue")
+
+	queue, err := NewDiskQueue(path, 2)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	expected := []URLInfo{}
+	for i := range 5 {
+		info := URLInfo{URL: fmt.Sprintf("https://www.google.com/%d", i), Depth: i}
+		queue.EnqueueWith(info)
+		expected = append(expected, info)
+	}
+
+	if size := queue.Size(); size != 5 {
+		t.Errorf("F23: test case 1 failed, %d != %d", size, 5)
+	}
+	if size := queue.memory.Size(); size != 2 {
+		t.Errorf("F23: test case 2 failed, %d != %d", size, 2)
+	}
+
+	result := []URLInfo{}
+	for range 3 {
+		info, err := queue.DequeueInfo()
+		if err != nil {
+			t.Errorf("F23: test case 3 failed, unexpected error: %v", err)
+		}
+		result = append(result, info)
+	}
+
+	queue.Enqueue("https://www.google.com/5")
+	expected = append(expected, URLInfo{URL: "https://www.google.com/5"})
+
+	first, err := queue.Peek()
+	if err != nil || first != "https://www.google.com/3" {
+		t.Errorf("F23: test case 4 failed, %s != %s, unexpected error: %v", first, "https://www.google.com/3", err)
+	}
+
+	for !queue.Empty() {
+		info, err := queue.DequeueInfo()
+		if err != nil {
+			t.Errorf("F23: test case 5 failed, unexpected error: %v", err)
+		}
+		result = append(result, info)
+	}
+
+	if comp := reflect.DeepEqual(result, expected); !comp {
+		t.Errorf("F23: test case 6 failed, %v != %v", result, expected)
+	}
+
+	if _, err := queue.Dequeue(); err == nil {
+		t.Errorf("F23: test case 7 failed, expected error")
+	}
+
+	if _, err := os.Stat(path + ".0"); !os.IsNotExist(err) {
+		t.Errorf("F23: test case 8 failed, drained band file not removed")
+	}
+
+	if err := queue.Close(); err != nil {
+		t.Errorf("F23: test case 9 failed, unexpected error: %v", err)
+	}
+	if files, _ := filepath.Glob(path + "*"); len(files) != 0 {
+		t.Errorf("F23: test case 10 failed, queue files %v not removed", files)
+	}
+}
+
+func TestDiskQueueBands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.queue")
+
+	queue, err := NewDiskQueue(path, 2)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer queue.Close()
+
+	// Spilled entries come out by band, and in the order they were spilled
+	// within one.
+	for _, info := range []URLInfo{
+		{URL: "a"},
+		{URL: "b"},
+		{URL: "c"},
+		{URL: "d", Boost: 2.5},
+		{URL: "e", Boost: 2.2},
+		{URL: "f", Boost: -1},
+		{URL: "g"},
+		{URL: "a", Depth: 1},
+	} {
+		queue.EnqueueWith(info)
+	}
+	if size := queue.Size(); size != 7 {
+		t.Errorf("F44: test case 1 failed, %d != %d", size, 7)
+	}
+	if first, err := queue.Peek(); err != nil || first != "d" {
+		t.Errorf("F44: test case 2 failed, %s != %s, unexpected error: %v", first, "d", err)
+	}
+
+	result := []string{}
+	for !queue.Empty() {
+		url, err := queue.Dequeue()
+		if err != nil {
+			t.Fatalf("F44: test case 3 failed, unexpected error: %v", err)
+		}
+		result = append(result, url)
+	}
+	if expected := []string{"d", "e", "a", "b", "c", "g", "f"}; !slices.Equal(result, expected) {
+		t.Errorf("F44: test case 3 failed, %v != %v", result, expected)
+	}
+	if files, _ := filepath.Glob(path + ".*"); len(files) != 0 {
+		t.Errorf("F44: test case 4 failed, drained band files %v not removed", files)
+	}
+
+	// A band that's mostly been read is moved to a fresh file, without
+	// losing what's still to come.
+	queue.compactAt = 1
+	for i := range 4 {
+		queue.Enqueue(fmt.Sprintf("%d", i))
+	}
+	if url, err := queue.Dequeue(); err != nil || url != "0" {
+		t.Errorf("F44: test case 5 failed, %s != %s, unexpected error: %v", url, "0", err)
+	}
+	if url, err := queue.Dequeue(); err != nil || url != "1" {
+		t.Errorf("F44: test case 5 failed, %s != %s, unexpected error: %v", url, "1", err)
+	}
+	if url, err := queue.Dequeue(); err != nil || url != "2" {
+		t.Errorf("F44: test case 5 failed, %s != %s, unexpected error: %v", url, "2", err)
+	}
+	stat, err := os.Stat(path + ".0")
+	if err != nil || stat.Size() != 0 {
+		t.Errorf("F44: test case 6 failed, band file not compacted, unexpected error: %v", err)
+	}
+
+	queue.Enqueue("4")
+	result = []string{}
+	for !queue.Empty() {
+		url, err := queue.Dequeue()
+		if err != nil {
+			t.Fatalf("F44: test case 7 failed, unexpected error: %v", err)
+		}
+		result = append(result, url)
+	}
+	if expected := []string{"3", "4"}; !slices.Equal(result, expected) {
+		t.Errorf("F44: test case 7 failed, %v != %v", result, expected)
+	}
+
+	// However many bands are spilled to, no file is left open.
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("can't count open files here")
+	}
+	queue.Enqueue("filler")
+	queue.Enqueue("filler too")
+	for i := range 100 {
+		queue.EnqueueWith(URLInfo{URL: fmt.Sprintf("band %d", i), Boost: float64(i)})
+	}
+	if open, err := os.ReadDir("/proc/self/fd"); err != nil || len(open) != len(fds) {
+		t.Errorf("F44: test case 8 failed, %d files open != %d, unexpected error: %v", len(open), len(fds), err)
+	}
+	if url, err := queue.Dequeue(); err != nil || url != "band 99" {
+		t.Errorf("F44: test case 9 failed, %s != %s, unexpected error: %v", url, "band 99", err)
+	}
+}
+
+func TestDequeueInfo(t *testing.T) {
+	queue := &Queue{}
+	EnqueueInfo(queue, URLInfo{URL: "a", Depth: 3})
+
+	info, err := DequeueInfo(queue)
+	if err != nil {
+		t.Errorf("F24: test case 1 failed, unexpected error: %v", err)
+	}
+	if comp := reflect.DeepEqual(info, URLInfo{URL: "a"}); !comp {
+		t.Errorf("F24: test case 2 failed, %v != %v", info, URLInfo{URL: "a"})
+	}
+
+	frontier := NewFrontier()
+	EnqueueInfo(frontier, URLInfo{URL: "a", Depth: 3})
+
+	info, err = DequeueInfo(frontier)
+	if err != nil {
+		t.Errorf("F24: test case 3 failed, unexpected error: %v", err)
+	}
+	if comp := reflect.DeepEqual(info, URLInfo{URL: "a", Depth: 3}); !comp {
+		t.Errorf("F24: test case 4 failed, %v != %v", info, URLInfo{URL: "a", Depth: 3})
+	}
+}
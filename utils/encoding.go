@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrUnknownEncoding = errors.New("couldn't determine encoding")
+
+	xmlDeclaration = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:\-]+)["']`)
+	utf8BOM        = []byte("\xef\xbb\xbf")
+)
+
+// DecodeUTF8 transcodes page to UTF-8 following the WHATWG encoding sniffing
+// order: BOM, Content-Type charset, <meta> prescan, then UTF-8 validation.
+// XML goes by its declaration instead of <meta>, and JSON, which is always
+// UTF-8, isn't sniffed at all. Pages that only match the windows-1252
+// fallback are read as UTF-8 when all of them is valid UTF-8, and rejected
+// instead of guessed otherwise.
+func DecodeUTF8(page []byte, contentType string) ([]byte, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if label, ok := params["charset"]; ok {
+			if e, _ := charset.Lookup(label); e == nil {
+				return []byte{}, "", fmt.Errorf("%w: unknown charset %s", ErrUnknownEncoding, label)
+			}
+		}
+	}
+	_, declared := params["charset"]
+
+	switch {
+	case !declared && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")):
+		page = bytes.TrimPrefix(page, utf8BOM)
+		if !utf8.Valid(page) {
+			return []byte{}, "", fmt.Errorf("%w: JSON isn't valid UTF-8", ErrUnknownEncoding)
+		}
+		return page, "utf-8", nil
+	case !declared && isXML(mediaType):
+		if match := xmlDeclaration.FindSubmatch(page); match != nil {
+			if e, _ := charset.Lookup(string(match[1])); e == nil {
+				return []byte{}, "", fmt.Errorf("%w: unknown charset %s", ErrUnknownEncoding, match[1])
+			}
+			contentType = mime.FormatMediaType(mediaType, map[string]string{"charset": string(match[1])})
+		}
+	}
+
+	e, name, certain := charset.DetermineEncoding(page, contentType)
+	if !certain && name == "windows-1252" && !declaresCharset(page) {
+		if !utf8.Valid(page) {
+			return []byte{}, "", ErrUnknownEncoding
+		}
+		return page, "utf-8", nil
+	}
+
+	decoded, err := e.NewDecoder().Bytes(page)
+	if err != nil {
+		return []byte{}, "", fmt.Errorf("%w: %v", ErrUnknownEncoding, err)
+	}
+
+	return decoded, name, nil
+}
+
+func isXML(mediaType string) bool {
+	return strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml")
+}
+
+func declaresCharset(page []byte) bool {
+	if len(page) > 1024 {
+		page = page[:1024]
+	}
+
+	tokens := html.NewTokenizer(bytes.NewReader(page))
+
+	for {
+		tn := tokens.Next()
+
+		if tn == html.ErrorToken {
+			if tokens.Err() == io.EOF {
+				break
+			}
+			return false
+		}
+		if tn != html.StartTagToken && tn != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := tokens.Token()
+		if t.DataAtom != atom.Meta {
+			continue
+		}
+
+		httpEquiv := false
+		content := ""
+		for _, attr := range t.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "charset":
+				if e, _ := charset.Lookup(attr.Val); e != nil {
+					return true
+				}
+			case "http-equiv":
+				httpEquiv = strings.EqualFold(attr.Val, "content-type")
+			case "content":
+				content = attr.Val
+			}
+		}
+
+		if httpEquiv {
+			if _, params, err := mime.ParseMediaType(content); err == nil {
+				if e, _ := charset.Lookup(params["charset"]); e != nil {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestDecodeUTF8(t *testing.T) {
+	shiftJIS, err := japanese.ShiftJIS.NewEncoder().String("<html><body><p>日本語のページ</p></body></html>")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(`<html><head><meta charset="gbk"></head><body><p>中文页面</p></body></html>`)
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		page         []byte
+		contentType  string
+		expected     string
+		charset      string
+		errorPresent bool
+	}{
+		{
+			name:         "F13: test case 1",
+			page:         []byte("<p>café</p>"),
+			contentType:  "text/html",
+			expected:     "<p>café</p>",
+			charset:      "utf-8",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 2",
+			page:         []byte(shiftJIS),
+			contentType:  "text/html; charset=Shift_JIS",
+			expected:     "<html><body><p>日本語のページ</p></body></html>",
+			charset:      "shift_jis",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 3",
+			page:         []byte(gbk),
+			contentType:  "text/html",
+			expected:     `<html><head><meta charset="gbk"></head><body><p>中文页面</p></body></html>`,
+			charset:      "gbk",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 4",
+			page:         []byte("<p>caf\xe9</p>"),
+			contentType:  "text/html; charset=windows-1252",
+			expected:     "<p>café</p>",
+			charset:      "windows-1252",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 5",
+			page:         []byte("<p>caf\xe9</p>"),
+			contentType:  "text/html",
+			expected:     "",
+			charset:      "",
+			errorPresent: true,
+		},
+		{
+			name:         "F13: test case 6",
+			page:         []byte("<p>cafe</p>"),
+			contentType:  "text/html; charset=klingon",
+			expected:     "",
+			charset:      "",
+			errorPresent: true,
+		},
+		{
+			name:         "F13: test case 7",
+			page:         []byte("<html><body><p>plain ascii</p></body></html>"),
+			contentType:  "text/html",
+			expected:     "<html><body><p>plain ascii</p></body></html>",
+			charset:      "utf-8",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 8",
+			page:         []byte(`{"title": "<meta charset=\"gbk\">", "text": "café"}`),
+			contentType:  "application/json",
+			expected:     `{"title": "<meta charset=\"gbk\">", "text": "café"}`,
+			charset:      "utf-8",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 9",
+			page:         []byte("{\"text\": \"caf\xe9\"}"),
+			contentType:  "application/json",
+			expected:     "",
+			charset:      "",
+			errorPresent: true,
+		},
+		{
+			name:         "F13: test case 10",
+			page:         []byte(`<?xml version="1.0"?><rss><channel><title>News</title></channel></rss>`),
+			contentType:  "application/rss+xml",
+			expected:     `<?xml version="1.0"?><rss><channel><title>News</title></channel></rss>`,
+			charset:      "utf-8",
+			errorPresent: false,
+		},
+		{
+			name:         "F13: test case 11",
+			page:         []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><urlset><loc>caf\xe9</loc></urlset>"),
+			contentType:  "application/xml",
+			expected:     `<?xml version="1.0" encoding="ISO-8859-1"?><urlset><loc>café</loc></urlset>`,
+			charset:      "windows-1252",
+			errorPresent: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, name, err := DecodeUTF8(testCase.page, testCase.contentType)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if string(result) != testCase.expected {
+				t.Errorf("%s failed, %s != %s", testCase.name, result, testCase.expected)
+			}
+			if name != testCase.charset {
+				t.Errorf("%s failed, %s != %s", testCase.name, name, testCase.charset)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type Document struct {
+	Content  []string
+	Links    []string
+	Feeds    []string
+	Metadata map[string]string
+}
+
+type Extractor interface {
+	Extract(base *url.URL, body []byte) (Document, error)
+}
+
+type ExtractorFunc func(base *url.URL, body []byte) (Document, error)
+
+func (f ExtractorFunc) Extract(base *url.URL, body []byte) (Document, error) {
+	return f(base, body)
+}
+
+type Registry struct {
+	mu         sync.RWMutex
+	extractors map[string]Extractor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{extractors: map[string]Extractor{}}
+}
+
+func (r *Registry) Register(mediaType string, extractor Extractor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.extractors[strings.ToLower(mediaType)] = extractor
+}
+
+func (r *Registry) Lookup(mediaType string) (Extractor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	extractor, ok := r.extractors[strings.ToLower(mediaType)]
+	return extractor, ok
+}
+
+func (r *Registry) MediaTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mediaTypes := []string{}
+	for mediaType := range r.extractors {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	slices.Sort(mediaTypes)
+
+	return mediaTypes
+}
+
+var Extractors = NewRegistry()
+
+func init() {
+	Extractors.Register("text/html", ExtractorFunc(ExtractHTML))
+	Extractors.Register("application/xhtml+xml", ExtractorFunc(ExtractHTML))
+	Extractors.Register("text/plain", ExtractorFunc(ExtractText))
+	Extractors.Register("text/markdown", ExtractorFunc(ExtractMarkdown))
+	Extractors.Register("text/x-markdown", ExtractorFunc(ExtractMarkdown))
+	Extractors.Register("application/rss+xml", ExtractorFunc(ExtractFeed))
+	Extractors.Register("application/atom+xml", ExtractorFunc(ExtractFeed))
+	Extractors.Register("application/xml", ExtractorFunc(ExtractFeed))
+	Extractors.Register("text/xml", ExtractorFunc(ExtractFeed))
+	Extractors.Register("application/json", ExtractorFunc(ExtractJSON))
+	Extractors.Register("application/pdf", ExtractorFunc(func(_ *url.URL, body []byte) (Document, error) {
+		content, err := ExtractPDF(body)
+		return Document{Content: content}, err
+	}))
+}
+
+func ExtractHTML(base *url.URL, body []byte) (Document, error) {
+	res, err := ParseHTML(base, body)
+	if err != nil {
+		return Document{}, err
+	}
+
+	return Document{
+		Content:  res.Content,
+		Links:    res.Links,
+		Feeds:    res.Feeds,
+		Metadata: map[string]string{"lang": res.Lang},
+	}, nil
+}
+
+func ExtractText(_ *url.URL, body []byte) (Document, error) {
+	doc := Document{}
+
+	for _, block := range splitBlocks(string(body)) {
+		if text := CollapseWhitespace(block); text != "" {
+			doc.Content = append(doc.Content, text)
+		}
+	}
+
+	return doc, nil
+}
+
+var (
+	markdownLink     = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	markdownAutolink = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	markdownPrefix   = regexp.MustCompile(`(?m)^\s{0,3}(?:#{1,6}\s+|>\s?|[-*+]\s+|\d+[.)]\s+)`)
+	markdownEmphasis = strings.NewReplacer("**", "", "__", "", "`", "")
+)
+
+func ExtractMarkdown(base *url.URL, body []byte) (Document, error) {
+	doc := Document{}
+	seen := map[string]struct{}{}
+
+	addLink := func(rawURL string) {
+		structure, err := url.Parse(rawURL)
+		if err != nil {
+			return
+		}
+		if base != nil && structure.Hostname() == "" {
+			rawURL = base.ResolveReference(structure).String()
+		}
+
+		if _, ok := seen[rawURL]; !ok {
+			seen[rawURL] = struct{}{}
+			doc.Links = append(doc.Links, rawURL)
+		}
+	}
+
+	for _, block := range splitBlocks(string(body)) {
+		if code, ok := strings.CutPrefix(block, "```"); ok {
+			_, code, _ = strings.Cut(code, "\n")
+			if code = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(code, "\n"), "```"), "\n"); code != "" {
+				doc.Content = append(doc.Content, code)
+			}
+			continue
+		}
+
+		for _, match := range markdownLink.FindAllStringSubmatch(block, -1) {
+			if !strings.HasPrefix(match[0], "!") {
+				addLink(match[2])
+			}
+		}
+		for _, match := range markdownAutolink.FindAllStringSubmatch(block, -1) {
+			addLink(match[1])
+		}
+
+		text := markdownLink.ReplaceAllString(block, "$1")
+		text = markdownAutolink.ReplaceAllString(text, "$1")
+		text = markdownPrefix.ReplaceAllString(text, "")
+		text = CollapseWhitespace(markdownEmphasis.Replace(text))
+		if text != "" {
+			doc.Content = append(doc.Content, text)
+		}
+	}
+
+	return doc, nil
+}
+
+// splitBlocks splits text on blank lines, keeping fenced code blocks whole.
+func splitBlocks(text string) []string {
+	blocks := []string{}
+	block := strings.Builder{}
+	fenced := false
+
+	flush := func() {
+		if strings.TrimSpace(block.String()) != "" {
+			blocks = append(blocks, strings.TrimSpace(block.String()))
+		}
+		block.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxBodySize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !fenced {
+				flush()
+			}
+			fenced = !fenced
+			block.WriteString(line + "\n")
+			if !fenced {
+				flush()
+			}
+			continue
+		}
+
+		if !fenced && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		block.WriteString(line + "\n")
+	}
+	flush()
+
+	return blocks
+}
+
+func ExtractFeed(_ *url.URL, body []byte) (Document, error) {
+	feed, err := ParseFeed(body)
+	if err != nil {
+		return Document{}, err
+	}
+
+	doc := Document{Metadata: map[string]string{"title": feed.Title}}
+	for _, entry := range feed.Entries {
+		if text := CollapseWhitespace(strings.TrimSpace(entry.Title + "\n" + entry.Summary)); text != "" {
+			doc.Content = append(doc.Content, text)
+		}
+		if entry.Link != "" {
+			doc.Links = append(doc.Links, entry.Link)
+		}
+	}
+
+	return doc, nil
+}
+
+func ExtractJSON(_ *url.URL, body []byte) (Document, error) {
+	var value any
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return Document{}, err
+	}
+
+	doc := Document{}
+	walkJSON(value, &doc, map[string]struct{}{})
+
+	return doc, nil
+}
+
+func walkJSON(value any, doc *Document, seen map[string]struct{}) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			walkJSON(v[key], doc, seen)
+		}
+	case []any:
+		for _, item := range v {
+			walkJSON(item, doc, seen)
+		}
+	case string:
+		text := strings.TrimSpace(v)
+		if text == "" {
+			return
+		}
+
+		if structure, err := url.Parse(text); err == nil && (structure.Scheme == "http" || structure.Scheme == "https") && structure.Host != "" {
+			if _, ok := seen[text]; !ok {
+				seen[text] = struct{}{}
+				doc.Links = append(doc.Links, text)
+			}
+			return
+		}
+		doc.Content = append(doc.Content, text)
+	}
+}
+
+// IsText reports whether bodies of mediaType should be transcoded to UTF-8
+// before extraction.
+func IsText(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/xml" ||
+		mediaType == "application/json"
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractors(t *testing.T) {
+	rss, err := os.ReadFile("./test_files/example.rss")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	atom, err := os.ReadFile("./test_files/example.atom")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	base, err := url.Parse("https://www.google.com")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		mediaType    string
+		body         []byte
+		expected     Document
+		errorPresent bool
+	}{
+		{
+			name:      "F16: test case 1",
+			mediaType: "text/plain",
+			body:      []byte("First  paragraph\nstill first.\n\n\nSecond paragraph."),
+			expected: Document{
+				Content: []string{"First paragraph still first.", "Second paragraph."},
+			},
+			errorPresent: false,
+		},
+		{
+			name:      "F16: test case 2",
+			mediaType: "text/markdown",
+			body:      []byte("# Title\n\nSee the **[docs](/docs)** and <https://www.github.com>.\n\n```go\nfunc main() {\n\n}\n```\n\n- ![logo](/logo.png) item"),
+			expected: Document{
+				Content: []string{"Title", "See the docs and https://www.github.com.", "func main() {\n\n}", "logo item"},
+				Links:   []string{"https://www.google.com/docs", "https://www.github.com"},
+			},
+			errorPresent: false,
+		},
+		{
+			name:      "F16: test case 3",
+			mediaType: "application/rss+xml",
+			body:      rss,
+			expected: Document{
+				Content: []string{
+					"Attention Is All You Need We propose a new simple network architecture, the Transformer.",
+					"BERT We introduce a new language representation model.",
+				},
+				Links:    []string{"https://arxiv.org/abs/1706.03762", "https://arxiv.org/abs/1810.04805"},
+				Metadata: map[string]string{"title": "arXiv cs.CL updates"},
+			},
+			errorPresent: false,
+		},
+		{
+			name:      "F16: test case 4",
+			mediaType: "APPLICATION/ATOM+XML",
+			body:      atom,
+			expected: Document{
+				Content: []string{
+					"CRISPR screening in primary cells Genome wide screens in primary human T cells.",
+					"Protein folding Structure prediction at scale.",
+				},
+				Links:    []string{"https://pubmed.ncbi.nlm.nih.gov/1/", "https://pubmed.ncbi.nlm.nih.gov/2/"},
+				Metadata: map[string]string{"title": "PubMed updates"},
+			},
+			errorPresent: false,
+		},
+		{
+			name:      "F16: test case 5",
+			mediaType: "application/json",
+			body:      []byte(`{"title": "A paper", "id": 42, "refs": ["https://arxiv.org/abs/1", "ftp://nope"], "abstract": "Some text."}`),
+			expected: Document{
+				Content: []string{"Some text.", "ftp://nope", "A paper"},
+				Links:   []string{"https://arxiv.org/abs/1"},
+			},
+			errorPresent: false,
+		},
+		{
+			name:         "F16: test case 6",
+			mediaType:    "application/json",
+			body:         []byte(`{"title": `),
+			expected:     Document{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			extractor, ok := Extractors.Lookup(testCase.mediaType)
+			if !ok {
+				t.Fatalf("%s failed, no extractor for %s", testCase.name, testCase.mediaType)
+			}
+
+			result, err := extractor.Extract(base, testCase.body)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if comp := reflect.DeepEqual(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %q != %q", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+
+	if _, ok := registry.Lookup("text/csv"); ok {
+		t.Errorf("F17: test case 1 failed, %t != %t", ok, false)
+	}
+
+	registry.Register("Text/CSV", ExtractorFunc(func(_ *url.URL, _ []byte) (Document, error) {
+		return Document{}, errors.New("csv")
+	}))
+
+	extractor, ok := registry.Lookup("text/csv")
+	if !ok {
+		t.Fatalf("F17: test case 2 failed, %t != %t", ok, true)
+	}
+	if _, err := extractor.Extract(nil, []byte{}); err == nil || err.Error() != "csv" {
+		t.Errorf("F17: test case 3 failed, unexpected error: %v", err)
+	}
+
+	if comp := reflect.DeepEqual(registry.MediaTypes(), []string{"text/csv"}); !comp {
+		t.Errorf("F17: test case 4 failed, %v != %v", registry.MediaTypes(), []string{"text/csv"})
+	}
+}
+
+func TestReadPageExtract(t *testing.T) {
+	base, err := url.Parse("https://www.google.com")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	// None of these say what charset they're in, as most pages don't.
+	testCases := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    Document
+	}{
+		{
+			name:        "F48: test case 1",
+			contentType: "application/json",
+			body:        `{"abstract": "Résumé of the paper.", "refs": ["https://arxiv.org/abs/1"]}`,
+			expected: Document{
+				Content: []string{"Résumé of the paper."},
+				Links:   []string{"https://arxiv.org/abs/1"},
+			},
+		},
+		{
+			name:        "F48: test case 2",
+			contentType: "application/rss+xml",
+			body:        `<?xml version="1.0"?><rss><channel><title>News</title><item><title>First</title><link>https://www.google.com/1</link></item></channel></rss>`,
+			expected: Document{
+				Content:  []string{"First"},
+				Links:    []string{"https://www.google.com/1"},
+				Metadata: map[string]string{"title": "News"},
+			},
+		},
+		{
+			name:        "F48: test case 3",
+			contentType: "text/plain",
+			body:        "Just some plain text.",
+			expected: Document{
+				Content: []string{"Just some plain text."},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {testCase.contentType}},
+				Body:       io.NopCloser(strings.NewReader(testCase.body)),
+			}
+
+			page, err := ReadPage(res)
+			if err != nil {
+				t.Fatalf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			extractor, ok := Extractors.Lookup(page.MediaType)
+			if !ok {
+				t.Fatalf("%s failed, no extractor for %s", testCase.name, page.MediaType)
+			}
+
+			result, err := extractor.Extract(base, page.Body)
+			if err != nil {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if comp := reflect.DeepEqual(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %q != %q", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
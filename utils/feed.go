@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+type Feed struct {
+	Title   string
+	Entries []FeedEntry
+}
+
+type FeedEntry struct {
+	Title     string
+	Link      string
+	Summary   string
+	Published time.Time
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+func ParseFeed(file []byte) (Feed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(file))
+	decoder.Strict = false
+	decoder.CharsetReader = utf8Reader
+
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return Feed{}, errors.New("couldn't find feed root")
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start.Name.Local
+			break
+		}
+	}
+
+	switch root {
+	case "rss":
+		return parseRSS(file)
+	case "feed":
+		return parseAtom(file)
+	}
+
+	return Feed{}, errors.New("not an rss or atom feed")
+}
+
+func parseRSS(file []byte) (Feed, error) {
+	rss := rssFeed{}
+	if err := unmarshalFeed(file, &rss); err != nil {
+		return Feed{}, err
+	}
+
+	feed := Feed{Title: strings.TrimSpace(rss.Channel.Title)}
+	for _, item := range rss.Channel.Items {
+		published, _ := time.Parse(time.RFC1123Z, strings.TrimSpace(item.PubDate))
+		if published.IsZero() {
+			published, _ = time.Parse(time.RFC1123, strings.TrimSpace(item.PubDate))
+		}
+
+		feed.Entries = append(feed.Entries, FeedEntry{
+			Title:     strings.TrimSpace(item.Title),
+			Link:      strings.TrimSpace(item.Link),
+			Summary:   strings.TrimSpace(item.Description),
+			Published: published,
+		})
+	}
+
+	return feed, nil
+}
+
+func parseAtom(file []byte) (Feed, error) {
+	atom := atomFeed{}
+	if err := unmarshalFeed(file, &atom); err != nil {
+		return Feed{}, err
+	}
+
+	feed := Feed{Title: strings.TrimSpace(atom.Title)}
+	for _, entry := range atom.Entries {
+		link := ""
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+
+		summary := entry.Summary
+		if summary == "" {
+			summary = entry.Content
+		}
+
+		published, _ := time.Parse(time.RFC3339, strings.TrimSpace(entry.Published))
+		if published.IsZero() {
+			published, _ = time.Parse(time.RFC3339, strings.TrimSpace(entry.Updated))
+		}
+
+		feed.Entries = append(feed.Entries, FeedEntry{
+			Title:     strings.TrimSpace(entry.Title),
+			Link:      strings.TrimSpace(link),
+			Summary:   strings.TrimSpace(summary),
+			Published: published,
+		})
+	}
+
+	return feed, nil
+}
+
+func unmarshalFeed(file []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(file))
+	decoder.Strict = false
+	decoder.CharsetReader = utf8Reader
+
+	return decoder.Decode(v)
+}
+
+// utf8Reader ignores the encoding in the XML declaration, ReadPage has
+// already transcoded the body to UTF-8 by it, or read it as UTF-8 when it
+// declared none.
+func utf8Reader(_ string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFeed(t *testing.T) {
+	rss, err := os.ReadFile("./test_files/example.rss")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	atom, err := os.ReadFile("./test_files/example.atom")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		file         []byte
+		expected     Feed
+		errorPresent bool
+	}{
+		{
+			name: "F18: test case 1",
+			file: rss,
+			expected: Feed{
+				Title: "arXiv cs.CL updates",
+				Entries: []FeedEntry{
+					{
+						Title:     "Attention Is All You Need",
+						Link:      "https://arxiv.org/abs/1706.03762",
+						Summary:   "We propose a new simple network architecture, the Transformer.",
+						Published: time.Date(2017, 6, 12, 17, 57, 34, 0, time.FixedZone("", 0)),
+					},
+					{
+						Title:     "BERT",
+						Link:      "https://arxiv.org/abs/1810.04805",
+						Summary:   "We introduce a new language representation model.",
+						Published: time.Date(2018, 10, 11, 0, 50, 1, 0, time.FixedZone("", 0)),
+					},
+				},
+			},
+			errorPresent: false,
+		},
+		{
+			name: "F18: test case 2",
+			file: atom,
+			expected: Feed{
+				Title: "PubMed updates",
+				Entries: []FeedEntry{
+					{
+						Title:     "CRISPR screening in primary cells",
+						Link:      "https://pubmed.ncbi.nlm.nih.gov/1/",
+						Summary:   "Genome wide screens in primary human T cells.",
+						Published: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+					},
+					{
+						Title:     "Protein folding",
+						Link:      "https://pubmed.ncbi.nlm.nih.gov/2/",
+						Summary:   "Structure prediction at scale.",
+						Published: time.Date(2025, 2, 3, 4, 5, 6, 0, time.UTC),
+					},
+				},
+			},
+			errorPresent: false,
+		},
+		{
+			name:         "F18: test case 3",
+			file:         []byte(`<?xml version="1.0"?><urlset></urlset>`),
+			expected:     Feed{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ParseFeed(testCase.file)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if len(result.Entries) != len(testCase.expected.Entries) || result.Title != testCase.expected.Title {
+				t.Fatalf("%s failed, %v != %v", testCase.name, result, testCase.expected)
+			}
+			for i, entry := range result.Entries {
+				expected := testCase.expected.Entries[i]
+				if !entry.Published.Equal(expected.Published) {
+					t.Errorf("%s failed, %v != %v", testCase.name, entry.Published, expected.Published)
+				}
+				entry.Published, expected.Published = time.Time{}, time.Time{}
+				if comp := reflect.DeepEqual(entry, expected); !comp {
+					t.Errorf("%s failed, %v != %v", testCase.name, entry, expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type URLInfo struct {
+	URL             string
+	Depth           int
+	SitemapPriority float64
+	LastModified    time.Time
+	InLinks         int
+	Boost           float64
+}
+
+type Scorer func(URLInfo) float64
+
+func DepthScorer(weight float64) Scorer {
+	return func(info URLInfo) float64 {
+		return -weight * float64(info.Depth)
+	}
+}
+
+// SitemapScorer treats URLs without a sitemap entry as the sitemap default
+// priority of 0.5.
+func SitemapScorer(weight float64) Scorer {
+	return func(info URLInfo) float64 {
+		if info.SitemapPriority <= 0 {
+			return weight * 0.5
+		}
+		return weight * info.SitemapPriority
+	}
+}
+
+func InLinkScorer(weight float64) Scorer {
+	return func(info URLInfo) float64 {
+		return weight * math.Log1p(float64(info.InLinks))
+	}
+}
+
+// FreshnessScorer halves the score of a page for every halfLife since it was
+// last modified.
+func FreshnessScorer(weight float64, halfLife time.Duration, now func() time.Time) Scorer {
+	return func(info URLInfo) float64 {
+		if info.LastModified.IsZero() {
+			return 0
+		}
+		age := max(now().Sub(info.LastModified), 0)
+		return weight * math.Exp2(-float64(age)/float64(halfLife))
+	}
+}
+
+func PatternScorer(weights map[string]float64) (Scorer, error) {
+	patterns := map[*regexp.Regexp]float64{}
+	for pattern, weight := range weights {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		patterns[compiled] = weight
+	}
+
+	return func(info URLInfo) float64 {
+		score := 0.0
+		for pattern, weight := range patterns {
+			if pattern.MatchString(info.URL) {
+				score += weight
+			}
+		}
+		return score
+	}, nil
+}
+
+// ParseWeights reads comma separated pattern=weight pairs, e.g.
+// "/abs/=3,/login=-5".
+func ParseWeights(spec string) (map[string]float64, error) {
+	weights := map[string]float64{}
+
+	for pair := range strings.SplitSeq(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		pattern, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return map[string]float64{}, fmt.Errorf("invalid url weight: %s", pair)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return map[string]float64{}, err
+		}
+		weights[strings.TrimSpace(pattern)] = weight
+	}
+
+	return weights, nil
+}
+
+type frontierItem struct {
+	info  URLInfo
+	score float64
+	seq   uint64
+	index int
+}
+
+type frontierHeap []*frontierItem
+
+func (h frontierHeap) Len() int { return len(h) }
+
+func (h frontierHeap) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h frontierHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *frontierHeap) Push(x any) {
+	item := x.(*frontierItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *frontierHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Frontier is a priority queue of URLs ordered by the sum of its scorers,
+// falling back to FIFO order between equal scores.
+type Frontier struct {
+	scorers []Scorer
+	items   frontierHeap
+	queued  map[string]*frontierItem
+	seq     uint64
+}
+
+var _ InfoQueueOps = (*Frontier)(nil)
+
+func NewFrontier(scorers ...Scorer) *Frontier {
+	return &Frontier{
+		scorers: scorers,
+		queued:  map[string]*frontierItem{},
+	}
+}
+
+func (f *Frontier) score(info URLInfo) float64 {
+	score := info.Boost
+	for _, scorer := range f.scorers {
+		score += scorer(info)
+	}
+	return score
+}
+
+func (f *Frontier) Enqueue(url string) {
+	f.EnqueueWith(URLInfo{URL: url})
+}
+
+// EnqueueWith adds a URL, or merges info into the queued entry when the URL
+// is already waiting, counting the extra in-link.
+func (f *Frontier) EnqueueWith(info URLInfo) {
+	if item, ok := f.queued[info.URL]; ok {
+		item.info.InLinks++
+		item.info.Depth = min(item.info.Depth, info.Depth)
+		item.info.SitemapPriority = max(item.info.SitemapPriority, info.SitemapPriority)
+		item.info.Boost = max(item.info.Boost, info.Boost)
+		if info.LastModified.After(item.info.LastModified) {
+			item.info.LastModified = info.LastModified
+		}
+		item.score = f.score(item.info)
+		heap.Fix(&f.items, item.index)
+		return
+	}
+
+	item := &frontierItem{info: info, score: f.score(info), seq: f.seq}
+	f.seq++
+	f.queued[info.URL] = item
+	heap.Push(&f.items, item)
+}
+
+func (f *Frontier) DequeueInfo() (URLInfo, error) {
+	if len(f.items) == 0 {
+		return URLInfo{}, errors.New("queue empty")
+	}
+
+	item := heap.Pop(&f.items).(*frontierItem)
+	delete(f.queued, item.info.URL)
+
+	return item.info, nil
+}
+
+func (f *Frontier) Dequeue() (string, error) {
+	info, err := f.DequeueInfo()
+	return info.URL, err
+}
+
+func (f *Frontier) Peek() (string, error) {
+	if len(f.items) == 0 {
+		return "", errors.New("queue empty")
+	}
+
+	return f.items[0].info.URL, nil
+}
+
+func (f *Frontier) Empty() bool {
+	return len(f.items) == 0
+}
+
+func (f *Frontier) Size() int {
+	return len(f.items)
+}
+
+// Top returns up to n queued URLs in the order they'd be dequeued, leaving
+// them queued.
+func (f *Frontier) Top(n int) []URLInfo {
+	items := slices.Clone(f.items)
+	slices.SortFunc(items, func(a, b *frontierItem) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.seq, b.seq))
+	})
+
+	top := []URLInfo{}
+	for _, item := range items[:min(n, len(items))] {
+		top = append(top, item.info)
+	}
+
+	return top
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFrontier(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	patterns, err := PatternScorer(map[string]float64{"/abs/": 3, "/login": -5})
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		scorers  []Scorer
+		infos    []URLInfo
+		expected []string
+	}{
+		{
+			name:    "F19: test case 1",
+			scorers: []Scorer{},
+			infos: []URLInfo{
+				{URL: "a"}, {URL: "b"}, {URL: "c"},
+			},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:    "F19: test case 2",
+			scorers: []Scorer{DepthScorer(1)},
+			infos: []URLInfo{
+				{URL: "a", Depth: 2}, {URL: "b", Depth: 0}, {URL: "c", Depth: 1},
+			},
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			name:    "F19: test case 3",
+			scorers: []Scorer{SitemapScorer(1)},
+			infos: []URLInfo{
+				{URL: "a", SitemapPriority: 0.1}, {URL: "b"}, {URL: "c", SitemapPriority: 0.9},
+			},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:    "F19: test case 4",
+			scorers: []Scorer{patterns},
+			infos: []URLInfo{
+				{URL: "https://arxiv.org/login"}, {URL: "https://arxiv.org/list"}, {URL: "https://arxiv.org/abs/1"},
+			},
+			expected: []string{"https://arxiv.org/abs/1", "https://arxiv.org/list", "https://arxiv.org/login"},
+		},
+		{
+			name:    "F19: test case 5",
+			scorers: []Scorer{InLinkScorer(1)},
+			infos: []URLInfo{
+				{URL: "a"}, {URL: "b"}, {URL: "b"}, {URL: "c"}, {URL: "c"}, {URL: "c"},
+			},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:    "F19: test case 6",
+			scorers: []Scorer{FreshnessScorer(1, 24*time.Hour, func() time.Time { return now })},
+			infos: []URLInfo{
+				{URL: "a"}, {URL: "b", LastModified: now.Add(-72 * time.Hour)}, {URL: "c", LastModified: now.Add(-time.Hour)},
+			},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:    "F19: test case 7",
+			scorers: []Scorer{DepthScorer(1)},
+			infos: []URLInfo{
+				{URL: "a"}, {URL: "b", Depth: 3}, {URL: "c", Depth: 5, Boost: 100},
+			},
+			expected: []string{"c", "a", "b"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			frontier := NewFrontier(testCase.scorers...)
+			for _, info := range testCase.infos {
+				frontier.EnqueueWith(info)
+			}
+
+			result := []string{}
+			for !frontier.Empty() {
+				url, err := frontier.Dequeue()
+				if err != nil {
+					t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+				}
+				result = append(result, url)
+			}
+
+			if comp := reflect.DeepEqual(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %v != %v", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestFrontierOps(t *testing.T) {
+	frontier := NewFrontier()
+
+	if _, err := frontier.Dequeue(); err == nil {
+		t.Errorf("F20: test case 1 failed, expected error")
+	}
+	if _, err := frontier.Peek(); err == nil {
+		t.Errorf("F20: test case 2 failed, expected error")
+	}
+
+	frontier.Enqueue("a")
+	frontier.Enqueue("b")
+	frontier.Enqueue("a")
+	if size := frontier.Size(); size != 2 {
+		t.Errorf("F20: test case 3 failed, %d != %d", size, 2)
+	}
+
+	first, err := frontier.Peek()
+	if err != nil || first != "a" {
+		t.Errorf("F20: test case 4 failed, %s != %s, unexpected error: %v", first, "a", err)
+	}
+
+	frontier.EnqueueWith(URLInfo{URL: "c", Boost: 1})
+	top := []string{}
+	for _, info := range frontier.Top(2) {
+		top = append(top, info.URL)
+	}
+	if !slices.Equal(top, []string{"c", "a"}) || frontier.Size() != 3 {
+		t.Errorf("F20: test case 5 failed, %v != %v", top, []string{"c", "a"})
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	testCases := []struct {
+		name         string
+		spec         string
+		expected     map[string]float64
+		errorPresent bool
+	}{
+		{
+			name:         "F21: test case 1",
+			spec:         "/abs/=3, /login=-5",
+			expected:     map[string]float64{"/abs/": 3, "/login": -5},
+			errorPresent: false,
+		},
+		{
+			name:         "F21: test case 2",
+			spec:         "",
+			expected:     map[string]float64{},
+			errorPresent: false,
+		},
+		{
+			name:         "F21: test case 3",
+			spec:         "/abs/",
+			expected:     map[string]float64{},
+			errorPresent: true,
+		},
+		{
+			name:         "F21: test case 4",
+			spec:         "/abs/=high",
+			expected:     map[string]float64{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ParseWeights(testCase.spec)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if comp := reflect.DeepEqual(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %v != %v", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
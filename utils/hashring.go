@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// HashRing assigns keys to nodes by consistent hashing, so adding or removing
+// a node only moves the keys that land next to it on the ring. Each node is
+// placed on the ring replicas times to spread keys evenly.
+type HashRing struct {
+	replicas int
+	points   []uint64
+	owners   map[uint64]string
+	nodes    map[string]struct{}
+}
+
+func NewHashRing(replicas int) *HashRing {
+	return &HashRing{
+		replicas: max(replicas, 1),
+		owners:   map[uint64]string{},
+		nodes:    map[string]struct{}{},
+	}
+}
+
+func ringHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func (r *HashRing) Add(node string) {
+	if _, ok := r.nodes[node]; ok {
+		return
+	}
+	r.nodes[node] = struct{}{}
+
+	for i := range r.replicas {
+		point := ringHash(fmt.Sprintf("%s#%d", node, i))
+		r.owners[point] = node
+		r.points = append(r.points, point)
+	}
+	slices.Sort(r.points)
+}
+
+func (r *HashRing) Remove(node string) {
+	if _, ok := r.nodes[node]; !ok {
+		return
+	}
+	delete(r.nodes, node)
+
+	r.points = slices.DeleteFunc(r.points, func(point uint64) bool {
+		if r.owners[point] != node {
+			return false
+		}
+		delete(r.owners, point)
+		return true
+	})
+}
+
+// Get returns the node that owns key, or false when the ring is empty.
+func (r *HashRing) Get(key string) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]], true
+}
+
+func (r *HashRing) Nodes() []string {
+	return slices.Sorted(maps.Keys(r.nodes))
+}
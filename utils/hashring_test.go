@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestHashRing(t *testing.T) {
+	ring := NewHashRing(64)
+
+	if _, ok := ring.Get("example.com"); ok {
+		t.Errorf("F30: test case 1 failed, expected no owner on an empty ring")
+	}
+
+	ring.Add("a")
+	ring.Add("b")
+	ring.Add("c")
+	ring.Add("a")
+	if nodes := ring.Nodes(); !slices.Equal(nodes, []string{"a", "b", "c"}) {
+		t.Errorf("F30: test case 2 failed, %v != %v", nodes, []string{"a", "b", "c"})
+	}
+
+	keys := []string{}
+	for i := range 1000 {
+		keys = append(keys, fmt.Sprintf("host%d.example.com", i))
+	}
+
+	before := map[string]string{}
+	counts := map[string]int{}
+	for _, key := range keys {
+		owner, _ := ring.Get(key)
+		before[key] = owner
+		counts[owner]++
+	}
+	for _, node := range ring.Nodes() {
+		if counts[node] < 200 {
+			t.Errorf("F30: test case 3 failed, %s owns %d of %d keys", node, counts[node], len(keys))
+		}
+	}
+
+	for _, key := range keys {
+		if owner, _ := ring.Get(key); owner != before[key] {
+			t.Errorf("F30: test case 4 failed, %s moved from %s to %s", key, before[key], owner)
+			break
+		}
+	}
+
+	ring.Remove("b")
+	for _, key := range keys {
+		owner, _ := ring.Get(key)
+		if owner == "b" {
+			t.Errorf("F30: test case 5 failed, %s still owned by a removed node", key)
+			break
+		}
+		if before[key] != "b" && owner != before[key] {
+			t.Errorf("F30: test case 6 failed, %s moved from %s to %s", key, before[key], owner)
+			break
+		}
+	}
+
+	ring.Remove("a")
+	ring.Remove("c")
+	if _, ok := ring.Get("example.com"); ok {
+		t.Errorf("F30: test case 7 failed, expected no owner once every node is removed")
+	}
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "be", "this", "by", "which", "from", "have", "or", "were", "an", "not", "we", "these", "been", "their", "has", "than"},
+	"fr": {"le", "la", "les", "et", "des", "du", "une", "est", "que", "dans", "qui", "pour", "pas", "sur", "au", "avec", "ce", "il", "sont", "aux", "par", "plus", "ou", "mais", "nous", "cette", "été", "leur", "ont", "entre"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des", "auf", "für", "im", "dem", "werden", "wird", "auch", "wurde", "bei", "einer", "oder", "nach", "durch", "sind", "über", "zwischen"},
+	"es": {"el", "los", "las", "del", "que", "y", "en", "una", "por", "con", "para", "es", "se", "al", "lo", "como", "más", "pero", "sus", "fue", "este", "entre", "ha", "son", "también", "sobre", "esta", "muy", "donde", "estos"},
+	"it": {"il", "di", "che", "della", "per", "un", "è", "del", "non", "una", "sono", "gli", "nel", "alla", "delle", "anche", "con", "dei", "questo", "nella", "come", "più", "stato", "essere", "ma", "tra", "degli", "sulla", "questa", "hanno"},
+	"pt": {"de", "que", "não", "do", "da", "em", "um", "para", "com", "uma", "os", "no", "se", "na", "por", "mais", "as", "dos", "como", "mas", "foi", "ao", "das", "são", "pelo", "pela", "também", "entre", "está", "seu"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "zijn", "niet", "met", "voor", "die", "wordt", "er", "aan", "ook", "bij", "worden", "naar", "door", "om", "kan", "werd", "deze", "uit", "tussen", "maar", "hun"},
+}
+
+var scripts = []struct {
+	language string
+	table    *unicode.RangeTable
+}{
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ru", unicode.Cyrillic},
+	{"ar", unicode.Arabic},
+	{"el", unicode.Greek},
+	{"he", unicode.Hebrew},
+	{"hi", unicode.Devanagari},
+	{"th", unicode.Thai},
+}
+
+// DetectLanguage returns the ISO 639-1 code of text, or an empty string when
+// it can't tell. Hints such as <html lang> and Content-Language are only
+// trusted when the text itself is inconclusive.
+func DetectLanguage(text string, hints ...string) string {
+	if language := detectScript(text); language != "" {
+		return language
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	counts := map[string]int{}
+	for _, word := range words {
+		counts[word]++
+	}
+
+	best := ""
+	bestScore := 0
+	for language, list := range stopwords {
+		score := 0
+		for _, stopword := range list {
+			score += counts[stopword]
+		}
+		if score > bestScore || (score == bestScore && language < best) {
+			best = language
+			bestScore = score
+		}
+	}
+
+	if bestScore >= 3 && bestScore*20 >= len(words) {
+		return best
+	}
+
+	for _, hint := range hints {
+		if language := PrimaryLanguage(hint); language != "" {
+			return language
+		}
+	}
+
+	return ""
+}
+
+func detectScript(text string) string {
+	letters := 0
+	counts := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+
+		for _, script := range scripts {
+			if unicode.Is(script.table, r) {
+				counts[script.language]++
+				break
+			}
+		}
+	}
+
+	if counts["ja"] > 0 && counts["ja"]*10 >= letters {
+		return "ja"
+	}
+
+	for _, script := range scripts {
+		if counts[script.language]*2 > letters {
+			return script.language
+		}
+	}
+
+	return ""
+}
+
+func PrimaryLanguage(tag string) string {
+	tag, _, _ = strings.Cut(tag, ",")
+	tag, _, _ = strings.Cut(strings.TrimSpace(tag), ";")
+	tag, _, _ = strings.Cut(tag, "-")
+	tag, _, _ = strings.Cut(tag, "_")
+
+	return strings.ToLower(strings.TrimSpace(tag))
+}
@@ -0,0 +1,69 @@
+package utils
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		hints    []string
+		expected string
+	}{
+		{
+			name:     "F10: test case 1",
+			text:     "The results of the trial show that the treatment was effective for most of the patients in the study.",
+			hints:    []string{},
+			expected: "en",
+		},
+		{
+			name:     "F10: test case 2",
+			text:     "Les résultats de cette étude montrent que le traitement est efficace pour la plupart des patients.",
+			hints:    []string{"en"},
+			expected: "fr",
+		},
+		{
+			name:     "F10: test case 3",
+			text:     "Die Ergebnisse der Studie zeigen, dass die Behandlung für die meisten Patienten wirksam ist und nicht schadet.",
+			hints:    []string{},
+			expected: "de",
+		},
+		{
+			name:     "F10: test case 4",
+			text:     "本研究的结果表明该治疗对大多数患者有效。",
+			hints:    []string{"en"},
+			expected: "zh",
+		},
+		{
+			name:     "F10: test case 5",
+			text:     "この研究の結果は、治療がほとんどの患者に有効であることを示しています。",
+			hints:    []string{},
+			expected: "ja",
+		},
+		{
+			name:     "F10: test case 6",
+			text:     "Результаты исследования показывают эффективность лечения.",
+			hints:    []string{},
+			expected: "ru",
+		},
+		{
+			name:     "F10: test case 7",
+			text:     "PubMed Central",
+			hints:    []string{"", "en-US, fr;q=0.5"},
+			expected: "en",
+		},
+		{
+			name:     "F10: test case 8",
+			text:     "PubMed Central",
+			hints:    []string{},
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := DetectLanguage(testCase.text, testCase.hints...); result != testCase.expected {
+				t.Errorf("%s failed, %s != %s", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ExtractPDF returns the plain text of every page as its own block. The pdf
+// package panics on some malformed files, so panics are turned into errors.
+func ExtractPDF(file []byte) (content []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			content = []string{}
+			err = fmt.Errorf("couldn't parse pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		return []string{}, err
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		text, err := reader.Page(i).GetPlainText(nil)
+		if err != nil {
+			return []string{}, err
+		}
+
+		if text = strings.TrimSpace(text); text != "" {
+			content = append(content, text)
+		}
+	}
+
+	return content, nil
+}
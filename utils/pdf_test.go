@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestExtractPDF(t *testing.T) {
+	file, err := os.ReadFile("./test_files/example.pdf")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		file         []byte
+		expected     []string
+		errorPresent bool
+	}{
+		{
+			name: "F15: test case 1",
+			file: file,
+			expected: []string{
+				"Attention is all you need.",
+				"Second page of the paper.",
+			},
+			errorPresent: false,
+		},
+		{
+			name:         "F15: test case 2",
+			file:         []byte("<html>not a pdf</html>"),
+			expected:     []string{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ExtractPDF(testCase.file)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if comp := slices.Equal(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %q != %q", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
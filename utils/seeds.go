@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Seed struct {
+	URL            string
+	AllowLanguages []string
+	DenyLanguages  []string
+	Feeds          []string
+	FeedsOnly      bool
+}
+
+// ParseSeeds reads one seed per line, a URL followed by optional key=value
+// options, e.g. "https://arxiv.org/ allow=en deny=zh,ja mode=feeds".
+func ParseSeeds(file []byte) ([]Seed, error) {
+	seeds := []Seed{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		seed := Seed{URL: fields[0]}
+		for _, option := range fields[1:] {
+			key, value, ok := strings.Cut(option, "=")
+			if !ok {
+				return []Seed{}, fmt.Errorf("invalid seed option: %s", option)
+			}
+
+			switch key {
+			case "allow":
+				seed.AllowLanguages = splitLanguages(value)
+			case "deny":
+				seed.DenyLanguages = splitLanguages(value)
+			case "feeds":
+				seed.Feeds = strings.Split(value, ",")
+			case "mode":
+				switch value {
+				case "full":
+					seed.FeedsOnly = false
+				case "feeds":
+					seed.FeedsOnly = true
+				default:
+					return []Seed{}, fmt.Errorf("unknown seed mode: %s", value)
+				}
+			default:
+				return []Seed{}, fmt.Errorf("unknown seed option: %s", key)
+			}
+		}
+
+		seeds = append(seeds, seed)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return []Seed{}, err
+	}
+
+	return seeds, nil
+}
+
+func splitLanguages(value string) []string {
+	languages := []string{}
+	for language := range strings.SplitSeq(value, ",") {
+		if language = PrimaryLanguage(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+
+	return languages
+}
+
+func (s Seed) AcceptsLanguage(language string) bool {
+	if slices.Contains(s.DenyLanguages, language) {
+		return false
+	}
+	if len(s.AllowLanguages) > 0 && !slices.Contains(s.AllowLanguages, language) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSeeds(t *testing.T) {
+	testCases := []struct {
+		name         string
+		file         []byte
+		expected     []Seed
+		errorPresent bool
+	}{
+		{
+			name: "F11: test case 1",
+			file: []byte("https://www.google.com/\n\n# comment\nhttps://www.github.com/ allow=en,fr-CA deny=ZH\n"),
+			expected: []Seed{
+				{URL: "https://www.google.com/"},
+				{URL: "https://www.github.com/", AllowLanguages: []string{"en", "fr"}, DenyLanguages: []string{"zh"}},
+			},
+			errorPresent: false,
+		},
+		{
+			name: "F11: test case 4",
+			file: []byte("https://arxiv.org/ mode=feeds feeds=https://export.arxiv.org/rss/cs,https://export.arxiv.org/rss/q-bio"),
+			expected: []Seed{
+				{URL: "https://arxiv.org/", Feeds: []string{"https://export.arxiv.org/rss/cs", "https://export.arxiv.org/rss/q-bio"}, FeedsOnly: true},
+			},
+			errorPresent: false,
+		},
+		{
+			name:         "F11: test case 5",
+			file:         []byte("https://arxiv.org/ mode=fast"),
+			expected:     []Seed{},
+			errorPresent: true,
+		},
+		{
+			name:         "F11: test case 2",
+			file:         []byte("https://www.google.com/ allow"),
+			expected:     []Seed{},
+			errorPresent: true,
+		},
+		{
+			name:         "F11: test case 3",
+			file:         []byte("https://www.google.com/ depth=3"),
+			expected:     []Seed{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, err := ParseSeeds(testCase.file)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if comp := reflect.DeepEqual(result, testCase.expected); !comp {
+				t.Errorf("%s failed, %v != %v", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestAcceptsLanguage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seed     Seed
+		language string
+		expected bool
+	}{
+		{
+			name:     "F12: test case 1",
+			seed:     Seed{},
+			language: "",
+			expected: true,
+		},
+		{
+			name:     "F12: test case 2",
+			seed:     Seed{AllowLanguages: []string{"en"}},
+			language: "fr",
+			expected: false,
+		},
+		{
+			name:     "F12: test case 3",
+			seed:     Seed{AllowLanguages: []string{"en"}},
+			language: "",
+			expected: false,
+		},
+		{
+			name:     "F12: test case 4",
+			seed:     Seed{DenyLanguages: []string{"zh"}},
+			language: "zh",
+			expected: false,
+		},
+		{
+			name:     "F12: test case 5",
+			seed:     Seed{DenyLanguages: []string{"zh"}},
+			language: "en",
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if result := testCase.seed.AcceptsLanguage(testCase.language); result != testCase.expected {
+				t.Errorf("%s failed, %t != %t", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+type Visited interface {
+	Visit(normURL string) bool
+}
+
+type VisitedMap map[string]struct{}
+
+func (v VisitedMap) Visit(normURL string) bool {
+	if _, ok := v[normURL]; ok {
+		return false
+	}
+	v[normURL] = struct{}{}
+
+	return true
+}
+
+type BloomFilter struct {
+	Bits     []uint64
+	Hashes   int
+	Capacity int
+	Count    int
+}
+
+func NewBloomFilter(capacity int, falsePositive float64) *BloomFilter {
+	capacity = max(capacity, 1)
+
+	m := math.Ceil(-float64(capacity) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	k := max(int(math.Round(m/float64(capacity)*math.Ln2)), 1)
+
+	return &BloomFilter{
+		Bits:     make([]uint64, (int(m)+63)/64),
+		Hashes:   k,
+		Capacity: capacity,
+	}
+}
+
+// locations uses double hashing, which is as good as k independent hashes
+// for a bloom filter and stable across runs so filters can be persisted.
+func (b *BloomFilter) locations(key string) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write([]byte(key))
+	h2 := fnv.New64()
+	h2.Write([]byte(key))
+
+	a, c := h1.Sum64(), h2.Sum64()|1
+	size := uint64(len(b.Bits) * 64)
+
+	locations := make([]uint64, b.Hashes)
+	for i := range locations {
+		locations[i] = (a + uint64(i)*c) % size
+	}
+
+	return locations
+}
+
+func (b *BloomFilter) Add(key string) {
+	for _, location := range b.locations(key) {
+		b.Bits[location/64] |= 1 << (location % 64)
+	}
+	b.Count++
+}
+
+func (b *BloomFilter) Test(key string) bool {
+	for _, location := range b.locations(key) {
+		if b.Bits[location/64]&(1<<(location%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+type ExactStore interface {
+	Has(key string) (bool, error)
+	Add(key string) error
+}
+
+// KeyLister is an ExactStore that can list every key it holds, so a
+// SeenSet's filters can be rebuilt from it.
+type KeyLister interface {
+	Keys(add func(key string)) error
+}
+
+type MemoryStore struct {
+	keys map[string]struct{}
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]struct{}{}}
+}
+
+func (m *MemoryStore) Has(key string) (bool, error) {
+	_, ok := m.keys[key]
+	return ok, nil
+}
+
+func (m *MemoryStore) Add(key string) error {
+	m.keys[key] = struct{}{}
+	return nil
+}
+
+// DiskStore keeps keys in a SQLite file, so the exact store of a large
+// crawl's visited set lives on disk rather than in memory. It persists
+// itself, so reopening the same path picks up where it left off.
+type DiskStore struct {
+	db *sql.DB
+}
+
+func OpenDiskStore(path string) (*DiskStore, error) {
+	// Losing the last few keys in a crash only costs refetching those pages,
+	// so writes aren't synced.
+	db, err := sql.Open("sqlite", path+"?_pragma=journal_mode(WAL)&_pragma=synchronous(OFF)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS seen (key TEXT PRIMARY KEY) WITHOUT ROWID"); err != nil {
+		return nil, errors.Join(err, db.Close())
+	}
+
+	return &DiskStore{db: db}, nil
+}
+
+func (d *DiskStore) Has(key string) (bool, error) {
+	seen := false
+	err := d.db.QueryRow("SELECT EXISTS (SELECT 1 FROM seen WHERE key = ?)", key).Scan(&seen)
+
+	return seen, err
+}
+
+func (d *DiskStore) Add(key string) error {
+	_, err := d.db.Exec("INSERT INTO seen (key) VALUES (?) ON CONFLICT DO NOTHING", key)
+	return err
+}
+
+func (d *DiskStore) Keys(add func(key string)) error {
+	rows, err := d.db.Query("SELECT key FROM seen")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key := ""
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+		add(key)
+	}
+
+	return rows.Err()
+}
+
+func (d *DiskStore) Close() error {
+	return d.db.Close()
+}
+
+// SeenSet is a URL-seen test shared by every worker. A scalable bloom filter
+// answers most "never seen" checks without touching the exact store, which
+// is only consulted to rule out false positives. That only holds while the
+// filters have every key the exact store does, so until they're rebuilt
+// from a store that persists itself, the store is asked about every URL.
+type SeenSet struct {
+	mu            sync.Mutex
+	filters       []*BloomFilter
+	falsePositive float64
+	exact         ExactStore
+	complete      bool
+}
+
+func NewSeenSet(capacity int, falsePositive float64, exact ExactStore) *SeenSet {
+	// A MemoryStore starts out empty, like the filters.
+	_, complete := exact.(*MemoryStore)
+
+	return &SeenSet{
+		filters:       []*BloomFilter{NewBloomFilter(capacity, falsePositive/2)},
+		falsePositive: falsePositive / 2,
+		exact:         exact,
+		complete:      complete,
+	}
+}
+
+// Visit reports whether normURL hasn't been visited before, marking it
+// visited. When the exact store fails, the error is logged and the URL is
+// treated as new, since fetching a page twice beats never fetching it.
+func (s *SeenSet) Visit(normURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filtered := slices.ContainsFunc(s.filters, func(filter *BloomFilter) bool {
+		return filter.Test(normURL)
+	})
+	if filtered || !s.complete {
+		seen, err := s.exact.Has(normURL)
+		if err != nil {
+			log.Printf("couldn't check visited store for %s: %v", normURL, err)
+		}
+		if seen {
+			if !filtered {
+				s.add(normURL)
+			}
+			return false
+		}
+	}
+
+	if err := s.exact.Add(normURL); err != nil {
+		log.Printf("couldn't add %s to visited store: %v", normURL, err)
+	}
+	s.add(normURL)
+
+	return true
+}
+
+// Rebuild refills the filters from the exact store when it's a KeyLister,
+// after which the store is only asked about URLs the filters have seen.
+// Other stores are left to be asked about every URL.
+func (s *SeenSet) Rebuild() error {
+	lister, ok := s.exact.(KeyLister)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start over from the first filter's size and rate.
+	falsePositive := s.falsePositive
+	for range s.filters[1:] {
+		falsePositive *= 2
+	}
+	s.filters = []*BloomFilter{NewBloomFilter(s.filters[0].Capacity, falsePositive)}
+	s.falsePositive = falsePositive
+	if err := lister.Keys(s.add); err != nil {
+		return err
+	}
+	s.complete = true
+
+	return nil
+}
+
+// add grows the filter chain once the newest filter is full, halving the
+// false positive rate of each new filter so the overall rate stays bounded.
+func (s *SeenSet) add(key string) {
+	current := s.filters[len(s.filters)-1]
+	if current.Count >= current.Capacity {
+		s.falsePositive /= 2
+		current = NewBloomFilter(current.Capacity*2, s.falsePositive)
+		s.filters = append(s.filters, current)
+	}
+
+	current.Add(key)
+}
+
+func (s *SeenSet) Size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := 0
+	for _, filter := range s.filters {
+		size += filter.Count
+	}
+
+	return size
+}
+
+type seenSnapshot struct {
+	Filters       []*BloomFilter
+	FalsePositive float64
+	Exact         []string
+}
+
+// Save writes the filters to path, along with the exact store when it is a
+// MemoryStore. Other exact stores are expected to persist themselves.
+func (s *SeenSet) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := seenSnapshot{Filters: s.filters, FalsePositive: s.falsePositive}
+	if memory, ok := s.exact.(*MemoryStore); ok {
+		for key := range memory.keys {
+			snapshot.Exact = append(snapshot.Exact, key)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := gob.NewEncoder(file).Encode(snapshot); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+func (s *SeenSet) Load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snapshot := seenSnapshot{}
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return err
+	}
+	if len(snapshot.Filters) == 0 {
+		return errors.New("no filters in seen set file")
+	}
+
+	// A snapshot is only as complete as the exact store it was saved with,
+	// and other stores may have kept keys added after it was saved.
+	s.filters = snapshot.Filters
+	s.falsePositive = snapshot.FalsePositive
+	_, s.complete = s.exact.(*MemoryStore)
+	for _, key := range snapshot.Exact {
+		if err := s.exact.Add(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := NewBloomFilter(1000, 0.01)
+
+	for i := range 1000 {
+		filter.Add(fmt.Sprintf("www.google.com/%d", i))
+	}
+
+	for i := range 1000 {
+		if !filter.Test(fmt.Sprintf("www.google.com/%d", i)) {
+			t.Fatalf("F25: test case 1 failed, false negative for %d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 1000; i < 11000; i++ {
+		if filter.Test(fmt.Sprintf("www.google.com/%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("F25: test case 2 failed, %d false positives out of 10000", falsePositives)
+	}
+}
+
+func TestSeenSet(t *testing.T) {
+	seen := NewSeenSet(10, 0.01, NewMemoryStore())
+
+	for i := range 100 {
+		if ok := seen.Visit(fmt.Sprintf("www.google.com/%d", i)); !ok {
+			t.Fatalf("F26: test case 1 failed, %d reported as already visited", i)
+		}
+	}
+	for i := range 100 {
+		if ok := seen.Visit(fmt.Sprintf("www.google.com/%d", i)); ok {
+			t.Fatalf("F26: test case 2 failed, %d reported as unvisited", i)
+		}
+	}
+
+	if size := seen.Size(); size != 100 {
+		t.Errorf("F26: test case 3 failed, %d != %d", size, 100)
+	}
+	if len(seen.filters) < 2 {
+		t.Errorf("F26: test case 4 failed, filter chain didn't grow")
+	}
+
+	path := filepath.Join(t.TempDir(), "visited.gob")
+	if err := seen.Save(path); err != nil {
+		t.Fatalf("F26: test case 5 failed, unexpected error: %v", err)
+	}
+
+	loaded := NewSeenSet(10, 0.01, NewMemoryStore())
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("F26: test case 6 failed, unexpected error: %v", err)
+	}
+	if ok := loaded.Visit("www.google.com/42"); ok {
+		t.Errorf("F26: test case 7 failed, loaded set forgot a visited url")
+	}
+	if ok := loaded.Visit("www.google.com/new"); !ok {
+		t.Errorf("F26: test case 8 failed, loaded set reported a new url as visited")
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "visited.db")
+	exact, err := OpenDiskStore(path)
+	if err != nil {
+		t.Fatalf("F26: test case 9 failed, unexpected error: %v", err)
+	}
+
+	seen := NewSeenSet(10, 0.01, exact)
+	for i := range 100 {
+		if ok := seen.Visit(fmt.Sprintf("www.google.com/%d", i)); !ok {
+			t.Fatalf("F26: test case 9 failed, %d reported as already visited", i)
+		}
+	}
+	if ok := seen.Visit("www.google.com/42"); ok {
+		t.Errorf("F26: test case 10 failed, disk store forgot a visited url")
+	}
+	if err := exact.Close(); err != nil {
+		t.Fatalf("F26: test case 11 failed, unexpected error: %v", err)
+	}
+
+	// The keys outlive the process without being saved with the filters.
+	exact, err = OpenDiskStore(path)
+	if err != nil {
+		t.Fatalf("F26: test case 11 failed, unexpected error: %v", err)
+	}
+	defer exact.Close()
+	for key, expected := range map[string]bool{"www.google.com/42": true, "www.google.com/new": false} {
+		if has, err := exact.Has(key); err != nil || has != expected {
+			t.Errorf("F26: test case 11 failed, %s: %v != %v, error %v", key, has, expected, err)
+		}
+	}
+
+	// Without a snapshot the filters start empty, so the store is asked
+	// until they're rebuilt from it.
+	seen = NewSeenSet(10, 0.01, exact)
+	if ok := seen.Visit("www.google.com/42"); ok {
+		t.Errorf("F26: test case 12 failed, reopened store forgot a visited url")
+	}
+	seen = NewSeenSet(10, 0.01, exact)
+	if err := seen.Rebuild(); err != nil {
+		t.Fatalf("F26: test case 13 failed, unexpected error: %v", err)
+	}
+	if size := seen.Size(); size != 100 {
+		t.Errorf("F26: test case 13 failed, %d != %d", size, 100)
+	}
+	for key, expected := range map[string]bool{"www.google.com/7": false, "www.google.com/99": false, "www.google.com/new": true} {
+		if ok := seen.Visit(key); ok != expected {
+			t.Errorf("F26: test case 13 failed, %s: %v != %v", key, ok, expected)
+		}
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Has(string) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingStore) Add(string) error {
+	return errors.New("store unavailable")
+}
+
+func TestSeenSetErrors(t *testing.T) {
+	out := &bytes.Buffer{}
+	log.SetOutput(out)
+	defer log.SetOutput(os.Stderr)
+
+	// A URL isn't skipped because the store can't say whether it's been
+	// visited, and the failure is logged.
+	seen := NewSeenSet(10, 0.01, failingStore{})
+	if ok := seen.Visit("www.google.com/a"); !ok {
+		t.Errorf("F26: test case 14 failed, %t != %t", ok, true)
+	}
+	for _, expected := range []string{"couldn't check visited store for www.google.com/a: store unavailable", "couldn't add www.google.com/a to visited store: store unavailable"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("F26: test case 15 failed, %q not in %q", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const shingleSize = 3
+
+// DefaultSimDistance is the Hamming distance within which two pages count as
+// near duplicates unless configured otherwise.
+const DefaultSimDistance = 3
+
+func SimHash(text string) uint64 {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) == 0 {
+		return 0
+	}
+
+	weights := [64]int{}
+	for i := 0; i < max(len(words)-shingleSize+1, 1); i++ {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(strings.Join(words[i:min(i+shingleSize, len(words))], " ")))
+		feature := hasher.Sum64()
+
+		for bit := range 64 {
+			if feature&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	fingerprint := uint64(0)
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+
+	return fingerprint
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+type fingerprint struct {
+	url  string
+	hash uint64
+}
+
+// SimIndex finds fingerprints within a hamming distance using LSH banding.
+// Splitting the hash into distance+1 bands guarantees any two fingerprints
+// within the distance share at least one identical band.
+type SimIndex struct {
+	mu       sync.Mutex
+	distance int
+	bands    [][2]int
+	buckets  []map[uint64][]fingerprint
+	hashes   map[string]uint64
+}
+
+func NewSimIndex(distance int) *SimIndex {
+	distance = min(max(distance, 0), 63)
+
+	index := &SimIndex{distance: distance, hashes: map[string]uint64{}}
+
+	count := distance + 1
+	width := 64 / count
+	for i := range count {
+		start := i * width
+		end := start + width
+		if i == count-1 {
+			end = 64
+		}
+		index.bands = append(index.bands, [2]int{start, end})
+		index.buckets = append(index.buckets, map[uint64][]fingerprint{})
+	}
+
+	return index
+}
+
+func (s *SimIndex) key(band [2]int, hash uint64) uint64 {
+	width := band[1] - band[0]
+	if width == 64 {
+		return hash
+	}
+
+	return (hash >> band[0]) & (1<<width - 1)
+}
+
+// Add indexes url's fingerprint, replacing the one it had before so a
+// recrawled page is only ever in the index once.
+func (s *SimIndex) Add(url string, hash uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(url)
+	s.hashes[url] = hash
+	for i, band := range s.bands {
+		key := s.key(band, hash)
+		s.buckets[i][key] = append(s.buckets[i][key], fingerprint{url: url, hash: hash})
+	}
+}
+
+// Remove drops url's fingerprint, if it has one.
+func (s *SimIndex) Remove(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(url)
+}
+
+func (s *SimIndex) remove(url string) {
+	hash, ok := s.hashes[url]
+	if !ok {
+		return
+	}
+	delete(s.hashes, url)
+
+	for i, band := range s.bands {
+		key := s.key(band, hash)
+		bucket := slices.DeleteFunc(s.buckets[i][key], func(candidate fingerprint) bool {
+			return candidate.url == url
+		})
+		if len(bucket) == 0 {
+			delete(s.buckets[i], key)
+		} else {
+			s.buckets[i][key] = bucket
+		}
+	}
+}
+
+// Match returns the closest near duplicate of url, never url itself, so a
+// recrawled page isn't flagged as a copy of its earlier fetch.
+func (s *SimIndex) Match(url string, hash uint64) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.match(url, hash)
+}
+
+func (s *SimIndex) match(url string, hash uint64) (string, bool) {
+	closest := ""
+	distance := s.distance + 1
+	for i, band := range s.bands {
+		for _, candidate := range s.buckets[i][s.key(band, hash)] {
+			if candidate.url == url {
+				continue
+			}
+
+			if d := HammingDistance(candidate.hash, hash); d < distance {
+				closest = candidate.url
+				distance = d
+			}
+		}
+	}
+
+	return closest, closest != ""
+}
@@ -0,0 +1,124 @@
+package utils
+
+import "testing"
+
+func TestSimHash(t *testing.T) {
+	original := "Deep learning models for protein structure prediction have improved rapidly over the past decade, driven by larger datasets and attention based architectures that capture long range residue interactions."
+	mirror := "Deep learning models for protein structure prediction have improved rapidly over the past decade, driven by larger datasets and attention based architectures that capture long range residue interactions. Print view."
+	unrelated := "The city council approved a new budget for road maintenance, with most of the funding going to resurfacing arterial streets and repairing bridges damaged during the winter storms."
+
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		within   int
+		expected bool
+	}{
+		{
+			name:     "F8: test case 1",
+			a:        original,
+			b:        original,
+			within:   0,
+			expected: true,
+		},
+		{
+			name:     "F8: test case 2",
+			a:        original,
+			b:        mirror,
+			within:   DefaultSimDistance,
+			expected: true,
+		},
+		{
+			name:     "F8: test case 3",
+			a:        original,
+			b:        unrelated,
+			within:   DefaultSimDistance,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			distance := HammingDistance(SimHash(testCase.a), SimHash(testCase.b))
+			if comp := distance <= testCase.within; comp != testCase.expected {
+				t.Errorf("%s failed, distance %d within %d is %t", testCase.name, distance, testCase.within, comp)
+			}
+		})
+	}
+}
+
+func TestSimIndex(t *testing.T) {
+	index := NewSimIndex(3)
+	index.Add("https://www.google.com/a", 0b1111)
+
+	testCases := []struct {
+		name     string
+		url      string
+		hash     uint64
+		expected string
+		found    bool
+	}{
+		{
+			name:     "F9: test case 1",
+			url:      "https://www.google.com/b",
+			hash:     0b1111,
+			expected: "https://www.google.com/a",
+			found:    true,
+		},
+		{
+			name:     "F9: test case 2",
+			url:      "https://www.google.com/b",
+			hash:     0b0111 | 1<<40 | 1<<63,
+			expected: "https://www.google.com/a",
+			found:    true,
+		},
+		{
+			name:     "F9: test case 3",
+			url:      "https://www.google.com/b",
+			hash:     1<<20 | 1<<40 | 1<<63,
+			expected: "",
+			found:    false,
+		},
+		{
+			name:     "F9: test case 4",
+			url:      "https://www.google.com/a",
+			hash:     0b1111,
+			expected: "",
+			found:    false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			result, found := index.Match(testCase.url, testCase.hash)
+			if found != testCase.found {
+				t.Errorf("%s failed, %t != %t", testCase.name, found, testCase.found)
+			}
+			if result != testCase.expected {
+				t.Errorf("%s failed, %s != %s", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+
+	// Adding a URL again replaces its fingerprint rather than indexing both.
+	index.Add("https://www.google.com/c", 1<<50)
+	index.Add("https://www.google.com/c", 1<<20|1<<30|1<<40)
+	if result, found := index.Match("https://www.google.com/d", 1<<50|1); found {
+		t.Errorf("F9: test case 5 failed, %s matched a replaced fingerprint", result)
+	}
+	if result, _ := index.Match("https://www.google.com/d", 1<<20|1<<30|1<<40|1); result != "https://www.google.com/c" {
+		t.Errorf("F9: test case 6 failed, %s != %s", result, "https://www.google.com/c")
+	}
+	count := 0
+	for _, bucket := range index.buckets[0] {
+		count += len(bucket)
+	}
+	if count != 2 {
+		t.Errorf("F9: test case 7 failed, %d != %d", count, 2)
+	}
+
+	index.Remove("https://www.google.com/c")
+	if result, found := index.Match("https://www.google.com/d", 1<<20|1<<30|1<<40|1); found {
+		t.Errorf("F9: test case 8 failed, %s matched a removed fingerprint", result)
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type sitemapFile struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc      string `xml:"loc"`
+		LastMod  string `xml:"lastmod"`
+		Priority string `xml:"priority"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// ParseSitemap reads a urlset or a sitemapindex, returning page entries and
+// nested sitemap URLs respectively.
+func ParseSitemap(file []byte) ([]URLInfo, []string, error) {
+	sitemap := sitemapFile{}
+
+	decoder := xml.NewDecoder(bytes.NewReader(file))
+	decoder.Strict = false
+	decoder.CharsetReader = utf8Reader
+	if err := decoder.Decode(&sitemap); err != nil {
+		return []URLInfo{}, []string{}, err
+	}
+
+	entries := []URLInfo{}
+	for _, entry := range sitemap.URLs {
+		info := URLInfo{URL: strings.TrimSpace(entry.Loc)}
+		if info.URL == "" {
+			continue
+		}
+
+		if priority, err := strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64); err == nil {
+			info.SitemapPriority = min(max(priority, 0), 1)
+		}
+		info.LastModified = parseLastMod(strings.TrimSpace(entry.LastMod))
+
+		entries = append(entries, info)
+	}
+
+	sitemaps := []string{}
+	for _, nested := range sitemap.Sitemaps {
+		if loc := strings.TrimSpace(nested.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+
+	return entries, sitemaps, nil
+}
+
+func parseLastMod(value string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed
+		}
+	}
+
+	return time.Time{}
+}
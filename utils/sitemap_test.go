@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSitemap(t *testing.T) {
+	urlset, err := os.ReadFile("./test_files/example_sitemap.xml")
+	if err != nil {
+		t.Errorf("error setting up test, unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		file         []byte
+		entries      []URLInfo
+		sitemaps     []string
+		errorPresent bool
+	}{
+		{
+			name: "F22: test case 1",
+			file: urlset,
+			entries: []URLInfo{
+				{URL: "https://www.google.com/", SitemapPriority: 1, LastModified: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+				{URL: "https://www.google.com/about", LastModified: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+				{URL: "https://www.google.com/archive", SitemapPriority: 0.2},
+			},
+			sitemaps:     []string{},
+			errorPresent: false,
+		},
+		{
+			name:         "F22: test case 2",
+			file:         []byte(`<sitemapindex><sitemap><loc>https://www.google.com/a.xml</loc></sitemap><sitemap><loc>https://www.google.com/b.xml</loc></sitemap></sitemapindex>`),
+			entries:      []URLInfo{},
+			sitemaps:     []string{"https://www.google.com/a.xml", "https://www.google.com/b.xml"},
+			errorPresent: false,
+		},
+		{
+			name:         "F22: test case 3",
+			file:         []byte(``),
+			entries:      []URLInfo{},
+			sitemaps:     []string{},
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			entries, sitemaps, err := ParseSitemap(testCase.file)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if len(entries) != len(testCase.entries) {
+				t.Fatalf("%s failed, %v != %v", testCase.name, entries, testCase.entries)
+			}
+			for i, entry := range entries {
+				expected := testCase.entries[i]
+				if !entry.LastModified.Equal(expected.LastModified) {
+					t.Errorf("%s failed, %v != %v", testCase.name, entry.LastModified, expected.LastModified)
+				}
+				entry.LastModified, expected.LastModified = time.Time{}, time.Time{}
+				if comp := reflect.DeepEqual(entry, expected); !comp {
+					t.Errorf("%s failed, %v != %v", testCase.name, entry, expected)
+				}
+			}
+			if comp := reflect.DeepEqual(sitemaps, testCase.sitemaps); !comp {
+				t.Errorf("%s failed, %v != %v", testCase.name, sitemaps, testCase.sitemaps)
+			}
+		})
+	}
+}
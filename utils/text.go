@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type TextStage func(string) string
+
+var textStages = map[string]TextStage{
+	"lowercase":   Lowercase,
+	"nfkc":        NFKC,
+	"punctuation": StripPunctuation,
+	"whitespace":  CollapseWhitespace,
+}
+
+func Lowercase(text string) string {
+	return strings.ToLower(text)
+}
+
+func NFKC(text string) string {
+	return norm.NFKC.String(text)
+}
+
+func StripPunctuation(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func CollapseWhitespace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func ParsePipeline(spec string) ([]TextStage, error) {
+	pipeline := []TextStage{}
+
+	for name := range strings.SplitSeq(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		stage, ok := textStages[name]
+		if !ok {
+			return []TextStage{}, fmt.Errorf("unknown text stage: %s", name)
+		}
+		pipeline = append(pipeline, stage)
+	}
+
+	return pipeline, nil
+}
+
+func ApplyPipeline(text string, pipeline []TextStage) string {
+	for _, stage := range pipeline {
+		text = stage(text)
+	}
+
+	return text
+}
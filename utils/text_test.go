@@ -0,0 +1,61 @@
+package utils
+
+import "testing"
+
+func TestApplyPipeline(t *testing.T) {
+	testCases := []struct {
+		name         string
+		spec         string
+		input        string
+		expected     string
+		errorPresent bool
+	}{
+		{
+			name:         "F7: test case 1",
+			spec:         "",
+			input:        "NASA's  API\n\nReturns JSON.",
+			expected:     "NASA's  API\n\nReturns JSON.",
+			errorPresent: false,
+		},
+		{
+			name:         "F7: test case 2",
+			spec:         "lowercase",
+			input:        "NASA's API",
+			expected:     "nasa's api",
+			errorPresent: false,
+		},
+		{
+			name:         "F7: test case 3",
+			spec:         "nfkc, punctuation",
+			input:        "ﬁle №1, done!",
+			expected:     "file No1 done",
+			errorPresent: false,
+		},
+		{
+			name:         "F7: test case 4",
+			spec:         "lowercase,punctuation,whitespace",
+			input:        "Hello,  World!\n\nNew paragraph.",
+			expected:     "hello world new paragraph",
+			errorPresent: false,
+		},
+		{
+			name:         "F7: test case 5",
+			spec:         "lowercase,stem",
+			input:        "Hello",
+			expected:     "Hello",
+			errorPresent: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			pipeline, err := ParsePipeline(testCase.spec)
+			if (err != nil) != testCase.errorPresent {
+				t.Errorf("%s failed, unexpected error: %v", testCase.name, err)
+			}
+			if result := ApplyPipeline(testCase.input, pipeline); result != testCase.expected {
+				t.Errorf("%s failed, %q != %q", testCase.name, result, testCase.expected)
+			}
+		})
+	}
+}
@@ -28,43 +28,201 @@ func Normalize(rawURL string) (string, error) {
 	return structure.Host + strings.TrimRight(structure.Path, "/"), nil
 }
 
-func GetHTML(rawURL string) ([]byte, error) {
+type Page struct {
+	Status    int
+	Body      []byte
+	Header    http.Header
+	MediaType string
+	Charset   string
+	Size      int64
+	WireSize  int64
+	// Raw is the response as it came off the wire, status line and headers
+	// included and the body still encoded, when the fetch kept it.
+	Raw []byte
+}
+
+var sizeLimits = map[string]int64{
+	"application/pdf": MaxPDFSize,
+}
+
+var ErrArchive = errors.New("couldn't archive exchange")
+
+func Fetch(rawURL string) (Page, error) {
+	return fetch(rawURL, nil, false)
+}
+
+// FetchArchived is Fetch, also writing the exchange to archive when it isn't
+// nil. Only fetches that succeed are archived, and a failure to archive is
+// returned wrapping ErrArchive alongside the fetched page.
+func FetchArchived(rawURL string, archive *WARCWriter) (Page, error) {
+	return fetch(rawURL, archive, false)
+}
+
+// FetchRaw is FetchArchived, also keeping the response on the page's Raw.
+// Raw is left empty whenever archiving would fail.
+func FetchRaw(rawURL string, archive *WARCWriter) (Page, error) {
+	return fetch(rawURL, archive, true)
+}
+
+func fetch(rawURL string, archive *WARCWriter, keepRaw bool) (Page, error) {
 	client := &http.Client{}
 
-	res, err := client.Get(rawURL)
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
 	if err != nil {
-		return []byte{}, err
+		return Page{}, err
+	}
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Page{}, err
 	}
 	defer res.Body.Close()
 
+	wire := &bytes.Buffer{}
+	if archive != nil || keepRaw {
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(res.Body, wire), res.Body}
+	}
+
+	fetched, err := ReadPage(res)
+	if err != nil || (archive == nil && !keepRaw) {
+		return fetched, err
+	}
+
+	// Decoders can stop before the end of the body, which the archive still
+	// needs in full, up to the same limit the page is read to.
+	limit := sizeLimit(fetched.MediaType)
+	if _, err := io.Copy(io.Discard, io.LimitReader(res.Body, limit+1)); err != nil {
+		return fetched, fmt.Errorf("%w: %w", ErrArchive, err)
+	}
+	if int64(wire.Len()) > limit {
+		return fetched, fmt.Errorf("%w: body exceeds %d bytes", ErrArchive, limit)
+	}
+
+	if keepRaw {
+		fetched.Raw = dumpResponse(res, wire.Bytes())
+	}
+	if archive == nil {
+		return fetched, nil
+	}
+	if err := archive.WriteExchange(req, res, wire.Bytes()); err != nil {
+		return fetched, fmt.Errorf("%w: %w", ErrArchive, err)
+	}
+
+	return fetched, nil
+}
+
+// dumpResponse writes res out as it came off the wire, with body as its
+// still encoded payload.
+func dumpResponse(res *http.Response, body []byte) []byte {
+	dumped := &bytes.Buffer{}
+	fmt.Fprintf(dumped, "HTTP/%d.%d %s\r\n", res.ProtoMajor, res.ProtoMinor, res.Status)
+	res.Header.Write(dumped)
+	dumped.WriteString("\r\n")
+	dumped.Write(body)
+
+	return dumped.Bytes()
+}
+
+// ReadRawPage decodes a response kept on a page's Raw.
+func ReadRawPage(raw []byte) (Page, error) {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(raw)), nil)
+	if err != nil {
+		return Page{}, err
+	}
+	defer res.Body.Close()
+
+	return ReadPage(res)
+}
+
+// sizeLimit is the most bytes read of a mediaType's body.
+func sizeLimit(mediaType string) int64 {
+	if limit, ok := sizeLimits[mediaType]; ok {
+		return limit
+	}
+
+	return MaxBodySize
+}
+
+// ReadPage decodes a response into a Page, whether it came from the network
+// or an archive.
+func ReadPage(res *http.Response) (Page, error) {
+	fetched := Page{Header: res.Header, Status: res.StatusCode}
+
 	if res.StatusCode >= 400 && res.StatusCode < 500 {
-		return []byte{}, errors.New("400+ status code")
+		return fetched, errors.New("400+ status code")
 	}
 
 	mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
 	if err != nil {
-		return []byte{}, err
+		return fetched, err
 	}
-	if mediaType != "text/html" {
-		return []byte{}, errors.New("content type not text/html")
+	fetched.MediaType = mediaType
+
+	if _, ok := Extractors.Lookup(mediaType); !ok {
+		return fetched, fmt.Errorf("unsupported content type: %s", mediaType)
 	}
 
-	page, err := io.ReadAll(res.Body)
+	limit := sizeLimit(mediaType)
+
+	wire := &countingReader{reader: res.Body}
+
+	body, err := Decompress(wire, res.Header.Get("Content-Encoding"))
 	if err != nil {
-		return []byte{}, err
+		return fetched, err
+	}
+	defer body.Close()
+
+	page, err := ReadLimited(body, limit)
+	fetched.WireSize = wire.count
+	if err != nil {
+		return fetched, err
+	}
+
+	fetched.Body = page
+	fetched.Size = int64(len(page))
+
+	if IsText(mediaType) {
+		fetched.Body, fetched.Charset, err = DecodeUTF8(page, res.Header.Get("Content-Type"))
+		if err != nil {
+			fetched.Body = []byte{}
+			return fetched, err
+		}
 	}
 
-	return page, nil
+	return fetched, nil
 }
 
 type Response struct {
 	Content []string
 	Links   []string
+	Feeds   []string
+	Lang    string
 }
 
 func ParseHTML(domain *url.URL, page []byte) (Response, error) {
 	response := Response{}
+	links := map[string]struct{}{}
 	skip := true
+	pre := false
+	block := strings.Builder{}
+
+	flush := func() {
+		text := block.String()
+		block.Reset()
+
+		if pre {
+			text = strings.TrimRight(strings.TrimLeft(text, "\r\n"), " \t\r\n")
+		} else {
+			text = strings.Join(strings.Fields(text), " ")
+		}
+		if strings.TrimSpace(text) != "" {
+			response.Content = append(response.Content, text)
+		}
+	}
 
 	tokens := html.NewTokenizer(bytes.NewReader(page))
 
@@ -84,17 +242,54 @@ func ParseHTML(domain *url.URL, page []byte) (Response, error) {
 				continue
 			}
 
-			clean := strings.ToLower(strings.Join(strings.Fields(t.Data), " "))
-			if clean != "" {
-				response.Content = append(response.Content, clean)
-			}
+			block.WriteString(t.Data)
 			continue
 		}
-		if tn == html.StartTagToken {
+		if tn == html.StartTagToken || tn == html.SelfClosingTagToken {
 			t := tokens.Token()
 
-			if t.Data == "p" && t.DataAtom == atom.P {
+			if t.DataAtom == atom.P || t.DataAtom == atom.Pre {
+				flush()
 				skip = false
+				pre = t.DataAtom == atom.Pre
+				continue
+			}
+
+			if t.DataAtom == atom.Html {
+				for _, attr := range t.Attr {
+					if attr.Key == "lang" {
+						response.Lang = attr.Val
+					}
+				}
+				continue
+			}
+
+			if t.DataAtom == atom.Link {
+				rel, kind, href := "", "", ""
+				for _, attr := range t.Attr {
+					switch attr.Key {
+					case "rel":
+						rel = strings.ToLower(attr.Val)
+					case "type":
+						kind = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "href":
+						href = attr.Val
+					}
+				}
+
+				if !slices.Contains(strings.Fields(rel), "alternate") || (kind != "application/rss+xml" && kind != "application/atom+xml") {
+					continue
+				}
+
+				structure, err := url.Parse(href)
+				if err != nil || href == "" {
+					continue
+				}
+
+				feedURL := domain.ResolveReference(structure).String()
+				if comp := slices.Contains(response.Feeds, feedURL); !comp {
+					response.Feeds = append(response.Feeds, feedURL)
+				}
 				continue
 			}
 
@@ -107,16 +302,14 @@ func ParseHTML(domain *url.URL, page []byte) (Response, error) {
 							continue
 						}
 
+						link := attr.Val
 						if structure.Hostname() == "" {
-							fullURL := domain.ResolveReference(structure).String()
-
-							if comp := slices.Contains(response.Links, fullURL); !comp {
-								response.Links = append(response.Links, fullURL)
-							}
-						} else {
-							if comp := slices.Contains(response.Links, attr.Val); !comp {
-								response.Links = append(response.Links, attr.Val)
-							}
+							link = domain.ResolveReference(structure).String()
+						}
+
+						if _, ok := links[link]; !ok {
+							links[link] = struct{}{}
+							response.Links = append(response.Links, link)
 						}
 					}
 				}
@@ -125,12 +318,15 @@ func ParseHTML(domain *url.URL, page []byte) (Response, error) {
 		if tn == html.EndTagToken {
 			t := tokens.Token()
 
-			if t.Data == "p" && t.DataAtom == atom.P {
+			if t.DataAtom == atom.P || t.DataAtom == atom.Pre {
+				flush()
 				skip = true
+				pre = false
 				continue
 			}
 		}
 	}
+	flush()
 
 	return response, nil
 }
@@ -171,6 +367,7 @@ type Rules struct {
 	Allowed    []string
 	Disallowed []string
 	Delay      int
+	Sitemaps   []string
 }
 
 func ParseRobots(normURL string, textFile []byte) (Rules, error) {
@@ -184,9 +381,17 @@ func ParseRobots(normURL string, textFile []byte) (Rules, error) {
 			continue
 		}
 
-		line := strings.Split(scanner.Text(), ":")
-		key := strings.TrimSpace(line[0])
-		value := strings.TrimSpace(line[1])
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if key == "Sitemap" {
+			rules.Sitemaps = append(rules.Sitemaps, value)
+			continue
+		}
 
 		if key == "User-agent" {
 			if value == "*" {
@@ -224,13 +429,15 @@ func ParseRobots(normURL string, textFile []byte) (Rules, error) {
 	return rules, nil
 }
 
-func CheckAbility(visited map[string]struct{}, rules Rules, normURL string) bool {
-	if _, ok := visited[normURL]; ok {
+func CheckAbility(visited Visited, rules Rules, normURL string) bool {
+	if ok := visited.Visit(normURL); !ok {
 		return false
-	} else {
-		visited[normURL] = struct{}{}
 	}
 
+	return CheckRobots(rules, normURL)
+}
+
+func CheckRobots(rules Rules, normURL string) bool {
 	green := true
 	disallowedOn := ""
 	allowedOn := ""
@@ -302,6 +509,50 @@ type QueueOps interface {
 	Size() int
 }
 
+// InfoQueueOps is implemented by queues that keep crawl metadata such as
+// depth alongside each URL.
+type InfoQueueOps interface {
+	QueueOps
+	EnqueueWith(URLInfo)
+	DequeueInfo() (URLInfo, error)
+}
+
+// AckQueueOps is implemented by queues that hold on to a dequeued URL until
+// it's acknowledged as crawled, so one lost partway can be dequeued again.
+type AckQueueOps interface {
+	InfoQueueOps
+	Ack(url string) error
+}
+
+var _ QueueOps = (*Queue)(nil)
+
+func EnqueueInfo(q QueueOps, info URLInfo) {
+	if iq, ok := q.(InfoQueueOps); ok {
+		iq.EnqueueWith(info)
+		return
+	}
+
+	q.Enqueue(info.URL)
+}
+
+func DequeueInfo(q QueueOps) (URLInfo, error) {
+	if iq, ok := q.(InfoQueueOps); ok {
+		return iq.DequeueInfo()
+	}
+
+	url, err := q.Dequeue()
+	return URLInfo{URL: url}, err
+}
+
+// Ack acknowledges a dequeued URL on queues that wait for it.
+func Ack(q QueueOps, url string) error {
+	if aq, ok := q.(AckQueueOps); ok {
+		return aq.Ack(url)
+	}
+
+	return nil
+}
+
 func (q *Queue) Enqueue(url string) {
 	*q = append(*q, url)
 }
@@ -325,7 +576,7 @@ func (q *Queue) Peek() (string, error) {
 	return (*q)[0], nil
 }
 
-func (q *Queue) CheckEmpty() bool {
+func (q *Queue) Empty() bool {
 	return len(*q) == 0
 }
 
@@ -95,26 +95,13 @@ func TestParseHTML(t *testing.T) {
 		page:   page,
 		expected: Response{
 			Content: []string{
-				"home",
-				"|",
-				"services",
-				"|",
-				"github",
-				"this site has a mix of internal and external links for demonstration purposes.",
-				"learn more",
-				"about us",
-				"or check out our",
-				"portfolio",
-				".",
-				"visit our",
-				"documentation",
-				"or read the latest",
-				"tech news",
-				".",
-				"questions? reach out via our",
-				"contact page",
-				".",
-				"© 2025 mixed link example",
+				"Home | Services | GitHub",
+				"This site has a mix of internal and external links for demonstration purposes.",
+				"Learn more about us or check out our portfolio.",
+				"Visit our documentation or read the latest tech news.",
+				"func main() {\n\tfmt.Println(\"Hello, World\")\n}",
+				"Questions? Reach out via our contact page.",
+				"© 2025 Mixed Link Example",
 			},
 			Links: []string{
 				"https://www.google.com/",
@@ -126,6 +113,11 @@ func TestParseHTML(t *testing.T) {
 				"https://news.ycombinator.com",
 				"https://www.google.com/contact",
 			},
+			Feeds: []string{
+				"https://www.google.com/feed.xml",
+				"https://feeds.example.com/atom",
+			},
+			Lang: "en",
 		},
 	}
 
@@ -140,6 +132,12 @@ func TestParseHTML(t *testing.T) {
 		if comp := slices.Equal(result.Links, testCase.expected.Links); !comp {
 			t.Errorf("%s failed, %v != %v", testCase.name, result.Links, testCase.expected.Links)
 		}
+		if comp := slices.Equal(result.Feeds, testCase.expected.Feeds); !comp {
+			t.Errorf("%s failed, %v != %v", testCase.name, result.Feeds, testCase.expected.Feeds)
+		}
+		if result.Lang != testCase.expected.Lang {
+			t.Errorf("%s failed, %s != %s", testCase.name, result.Lang, testCase.expected.Lang)
+		}
 	})
 }
 
@@ -199,6 +197,9 @@ func TestParseRobots(t *testing.T) {
 				"www.google.com/show-email",
 			},
 			Delay: 15,
+			Sitemaps: []string{
+				"https://arxiv.org/sitemap.xml",
+			},
 		},
 	}
 
@@ -216,20 +217,23 @@ func TestParseRobots(t *testing.T) {
 		if result.Delay != testCase.expected.Delay {
 			t.Errorf("%s failed, %v != %v", testCase.name, result.Delay, testCase.expected.Delay)
 		}
+		if comp := slices.Equal(result.Sitemaps, testCase.expected.Sitemaps); !comp {
+			t.Errorf("%s failed, %v != %v", testCase.name, result.Sitemaps, testCase.expected.Sitemaps)
+		}
 	})
 }
 
 func TestCheckAbility(t *testing.T) {
 	testCases := []struct {
 		name     string
-		visited  map[string]struct{}
+		visited  VisitedMap
 		rules    Rules
 		normURL  string
 		expected bool
 	}{
 		{
 			name: "F4: test case 1",
-			visited: map[string]struct{}{
+			visited: VisitedMap{
 				"www.google.com/places": {},
 			},
 			rules:    Rules{},
@@ -238,14 +242,14 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:     "F4: test case 2",
-			visited:  map[string]struct{}{},
+			visited:  VisitedMap{},
 			rules:    Rules{},
 			normURL:  "www.google.com/places",
 			expected: true,
 		},
 		{
 			name:    "F4: test case 3",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps",
@@ -256,7 +260,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 4",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps/",
@@ -267,14 +271,14 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:     "F4: test case 5",
-			visited:  map[string]struct{}{},
+			visited:  VisitedMap{},
 			rules:    Rules{},
 			normURL:  "www.google.com/maps",
 			expected: true,
 		},
 		{
 			name:    "F4: test case 6",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/*world",
@@ -285,7 +289,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 7",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/hello*",
@@ -296,7 +300,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 8",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps/",
@@ -310,7 +314,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 9",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps/places",
@@ -324,7 +328,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 10",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps/",
@@ -338,7 +342,7 @@ func TestCheckAbility(t *testing.T) {
 		},
 		{
 			name:    "F4: test case 11",
-			visited: map[string]struct{}{},
+			visited: VisitedMap{},
 			rules: Rules{
 				Disallowed: []string{
 					"www.google.com/maps/places/",
@@ -451,7 +455,7 @@ func TestQueue(t *testing.T) {
 		queue.Dequeue()
 	}
 
-	empty := queue.CheckEmpty()
+	empty := queue.Empty()
 	if !empty {
 		t.Errorf("F6: test case 9 failed, %v != %v", empty, true)
 	}
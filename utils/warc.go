@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultWARCSize = 1 << 30
+
+// WARCWriter appends request and response records to gzipped WARC 1.1 files
+// in a directory, one gzip member per record, starting a new file once the
+// current one passes maxSize.
+type WARCWriter struct {
+	mu      sync.Mutex
+	dir     string
+	prefix  string
+	maxSize int64
+	file    *os.File
+	written int64
+	serial  int
+	now     func() time.Time
+}
+
+func NewWARCWriter(dir, prefix string, maxSize int64) (*WARCWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &WARCWriter{
+		dir:     dir,
+		prefix:  prefix,
+		maxSize: max(maxSize, 1),
+		now:     time.Now,
+	}, nil
+}
+
+// SetNow sets the clock records are dated by, time.Now by default.
+func (w *WARCWriter) SetNow(now func() time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.now = now
+}
+
+// WriteExchange archives req and the response it got. body is the payload as
+// it came off the wire, before any Content-Encoding was undone.
+func (w *WARCWriter) WriteExchange(req *http.Request, res *http.Response, body []byte) error {
+	request, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		return err
+	}
+
+	response := dumpResponse(res, body)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.rotate(); err != nil {
+		return err
+	}
+
+	date := w.now().UTC().Format(time.RFC3339)
+	target := req.URL.String()
+	responseID := recordID()
+
+	err = w.write([]warcField{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"Content-Type", "application/http;msgtype=response"},
+		{"WARC-Payload-Digest", digest(body)},
+	}, response)
+	if err != nil {
+		return err
+	}
+
+	return w.write([]warcField{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http;msgtype=request"},
+	}, request)
+}
+
+// rotate must be called with w.mu held.
+func (w *WARCWriter) rotate() error {
+	if w.file != nil && w.written < w.maxSize {
+		return nil
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, w.now().UTC().Format("20060102150405"), w.serial)
+	w.serial++
+
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.written = 0
+
+	return w.write([]warcField{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Date", w.now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", name},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte("software: github.com/junwei890/crawler\r\nformat: WARC File Format 1.1\r\n"))
+}
+
+type warcField struct {
+	name  string
+	value string
+}
+
+// write must be called with w.mu held.
+func (w *WARCWriter) write(fields []warcField, block []byte) error {
+	fields = append(fields,
+		warcField{"WARC-Block-Digest", digest(block)},
+		warcField{"Content-Length", strconv.Itoa(len(block))},
+	)
+
+	counter := &countingWriter{writer: w.file}
+	compressed := gzip.NewWriter(counter)
+
+	record := bufio.NewWriter(compressed)
+	record.WriteString("WARC/1.1\r\n")
+	for _, field := range fields {
+		fmt.Fprintf(record, "%s: %s\r\n", field.name, field.value)
+	}
+	record.WriteString("\r\n")
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+
+	if err := record.Flush(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	w.written += counter.count
+
+	return nil
+}
+
+func (w *WARCWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.close()
+}
+
+func (w *WARCWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+func digest(block []byte) string {
+	sum := sha1.Sum(block)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func recordID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+type WARCRecord struct {
+	Header textproto.MIMEHeader
+	Block  []byte
+}
+
+func (r WARCRecord) Type() string {
+	return r.Header.Get("WARC-Type")
+}
+
+func (r WARCRecord) TargetURI() string {
+	return r.Header.Get("WARC-Target-URI")
+}
+
+// Response parses the HTTP response held by a response record.
+func (r WARCRecord) Response() (*http.Response, error) {
+	if r.Type() != "response" {
+		return nil, fmt.Errorf("not a response record: %s", r.Type())
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Block)), nil)
+}
+
+// WARCReader reads records from a WARC file, gzipped or not.
+type WARCReader struct {
+	reader *bufio.Reader
+}
+
+func NewWARCReader(file io.Reader) (*WARCReader, error) {
+	buffered := bufio.NewReader(file)
+
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReader(decompressed)
+	}
+
+	return &WARCReader{reader: buffered}, nil
+}
+
+// Next returns the next record, or io.EOF once there are none left.
+func (r *WARCReader) Next() (WARCRecord, error) {
+	version := ""
+	for version == "" {
+		line, err := r.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && strings.TrimSpace(line) == "" {
+				return WARCRecord{}, io.EOF
+			}
+			return WARCRecord{}, err
+		}
+		version = strings.TrimSpace(line)
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return WARCRecord{}, fmt.Errorf("invalid warc record version line: %q", version)
+	}
+
+	header, err := textproto.NewReader(r.reader).ReadMIMEHeader()
+	if err != nil {
+		return WARCRecord{}, err
+	}
+
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return WARCRecord{}, fmt.Errorf("invalid warc content length: %w", err)
+	}
+
+	block := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, block); err != nil {
+		return WARCRecord{}, err
+	}
+
+	return WARCRecord{Header: header, Block: block}, nil
+}
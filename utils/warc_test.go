@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readWARC(t *testing.T, path string) []WARCRecord {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	reader, err := NewWARCReader(file)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	records := []WARCRecord{}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("error setting up test, unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestWARC(t *testing.T) {
+	content := []byte("<html><body><p>Archived paragraph</p></body></html>")
+	compressed := compress(t, "gzip", content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	archive, err := NewWARCWriter(dir, "test", 1)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+
+	for range 2 {
+		page, err := FetchArchived(server.URL+"/page", archive)
+		if err != nil {
+			t.Fatalf("F28: test case 1 failed, unexpected error: %v", err)
+		}
+		if !bytes.Equal(page.Body, content) {
+			t.Errorf("F28: test case 1 failed, %s != %s", page.Body, content)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("F28: test case 1 failed, unexpected error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*.warc.gz"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("F28: test case 2 failed, expected a file per exchange once over the size limit, got %v", files)
+	}
+
+	records := readWARC(t, files[0])
+	types := []string{}
+	for _, record := range records {
+		types = append(types, record.Type())
+	}
+	if len(types) != 3 || types[0] != "warcinfo" || types[1] != "response" || types[2] != "request" {
+		t.Fatalf("F28: test case 3 failed, unexpected record types %v", types)
+	}
+
+	response := records[1]
+	if target := response.TargetURI(); target != server.URL+"/page" {
+		t.Errorf("F28: test case 4 failed, %s != %s", target, server.URL+"/page")
+	}
+	if date := response.Header.Get("WARC-Date"); date == "" {
+		t.Errorf("F28: test case 4 failed, missing WARC-Date")
+	}
+	if payload := response.Header.Get("WARC-Payload-Digest"); payload != digest(compressed) {
+		t.Errorf("F28: test case 4 failed, %s != %s", payload, digest(compressed))
+	}
+	if block := response.Header.Get("WARC-Block-Digest"); block != digest(response.Block) {
+		t.Errorf("F28: test case 4 failed, %s != %s", block, digest(response.Block))
+	}
+	if concurrent := records[2].Header.Get("WARC-Concurrent-To"); concurrent != response.Header.Get("WARC-Record-ID") {
+		t.Errorf("F28: test case 4 failed, %s != %s", concurrent, response.Header.Get("WARC-Record-ID"))
+	}
+
+	res, err := response.Response()
+	if err != nil {
+		t.Fatalf("F28: test case 5 failed, unexpected error: %v", err)
+	}
+	page, err := ReadPage(res)
+	if err != nil {
+		t.Fatalf("F28: test case 5 failed, unexpected error: %v", err)
+	}
+	if !bytes.Equal(page.Body, content) || page.MediaType != "text/html" {
+		t.Errorf("F28: test case 5 failed, %s (%s) != %s (text/html)", page.Body, page.MediaType, content)
+	}
+
+	if _, err := records[0].Response(); err == nil {
+		t.Errorf("F28: test case 6 failed, expected an error reading a warcinfo record as a response")
+	}
+
+	// A decoder that stops early leaves the rest of the body, which is only
+	// drained up to the size limit.
+	padded := append(compress(t, "deflate", content), make([]byte, MaxBodySize+1)...)
+	padding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Encoding", "deflate")
+		w.Write(padded)
+	}))
+	defer padding.Close()
+
+	archive, err = NewWARCWriter(t.TempDir(), "test", 1<<30)
+	if err != nil {
+		t.Fatalf("error setting up test, unexpected error: %v", err)
+	}
+	defer archive.Close()
+	page, err = FetchArchived(padding.URL, archive)
+	if !errors.Is(err, ErrArchive) {
+		t.Errorf("F28: test case 7 failed, expected ErrArchive, got %v", err)
+	}
+	if !bytes.Equal(page.Body, content) {
+		t.Errorf("F28: test case 7 failed, %s != %s", page.Body, content)
+	}
+
+	// A kept response reads back the same as the page it was fetched as.
+	page, err = FetchRaw(server.URL+"/page", nil)
+	if err != nil {
+		t.Fatalf("F28: test case 8 failed, unexpected error: %v", err)
+	}
+	if !bytes.HasPrefix(page.Raw, []byte("HTTP/1.1 200 OK\r\n")) || !bytes.HasSuffix(page.Raw, compressed) {
+		t.Errorf("F28: test case 8 failed, %q isn't the response as it came off the wire", page.Raw)
+	}
+	kept, err := ReadRawPage(page.Raw)
+	if err != nil {
+		t.Fatalf("F28: test case 8 failed, unexpected error: %v", err)
+	}
+	if !bytes.Equal(kept.Body, content) || kept.Header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("F28: test case 8 failed, %s (%v) != %s", kept.Body, kept.Header, content)
+	}
+}